@@ -48,8 +48,52 @@ func main() {
 		klog.FlushAndExit(klog.ExitFlushTimeout, 1)
 	}
 
+	controllerInitializers := app.DefaultInitFuncConstructors
+	controllerInitializers[proxmox.NodeIPAMControllerName] = app.ControllerInitFuncConstructor{
+		InitContext: app.ControllerInitContext{
+			ClientName: proxmox.NodeIPAMControllerClientName,
+		},
+		Constructor: proxmox.StartNodeIPAMControllerWrapper,
+	}
+
+	controllerInitializers[proxmox.NodeMigrationControllerName] = app.ControllerInitFuncConstructor{
+		InitContext: app.ControllerInitContext{
+			ClientName: proxmox.NodeMigrationControllerClientName,
+		},
+		Constructor: proxmox.StartNodeMigrationControllerWrapper,
+	}
+
+	controllerInitializers[proxmox.NodeGCControllerName] = app.ControllerInitFuncConstructor{
+		InitContext: app.ControllerInitContext{
+			ClientName: proxmox.NodeGCControllerClientName,
+		},
+		Constructor: proxmox.StartNodeGCControllerWrapper,
+	}
+
+	controllerInitializers[proxmox.HAMaintenanceControllerName] = app.ControllerInitFuncConstructor{
+		InitContext: app.ControllerInitContext{
+			ClientName: proxmox.HAMaintenanceControllerClientName,
+		},
+		Constructor: proxmox.StartHAMaintenanceControllerWrapper,
+	}
+
+	controllerInitializers[proxmox.HostHealthControllerName] = app.ControllerInitFuncConstructor{
+		InitContext: app.ControllerInitContext{
+			ClientName: proxmox.HostHealthControllerClientName,
+		},
+		Constructor: proxmox.StartHostHealthControllerWrapper,
+	}
+
+	app.ControllersDisabledByDefault.Insert(
+		proxmox.NodeIPAMControllerName,
+		proxmox.NodeMigrationControllerName,
+		proxmox.NodeGCControllerName,
+		proxmox.HAMaintenanceControllerName,
+		proxmox.HostHealthControllerName,
+	)
+
 	fss := cliflag.NamedFlagSets{}
-	command := app.NewCloudControllerManagerCommand(ccmOptions, cloudInitializer, app.DefaultInitFuncConstructors, names.CCMControllerAliases(), fss, wait.NeverStop)
+	command := app.NewCloudControllerManagerCommand(ccmOptions, cloudInitializer, controllerInitializers, names.CCMControllerAliases(), fss, wait.NeverStop)
 
 	command.Flags().VisitAll(func(flag *pflag.Flag) {
 		if flag.Name == "cloud-provider" {
@@ -79,6 +123,10 @@ func cloudInitializer(config *config.CompletedConfig) cloudprovider.Interface {
 		klog.FlushAndExit(klog.ExitFlushTimeout, 1)
 	}
 
+	if c, ok := cloud.(interface{ SetClusterName(name string) }); ok {
+		c.SetClusterName(config.ComponentConfig.KubeCloudShared.ClusterName)
+	}
+
 	if !cloud.HasClusterID() {
 		if config.ComponentConfig.KubeCloudShared.AllowUntaggedCloud {
 			klog.InfoS("detected a cluster without a ClusterID. A ClusterID will be required in the future. Please tag your cluster to avoid any future issues")
@@ -20,6 +20,7 @@ require (
 	k8s.io/client-go v0.36.0
 	k8s.io/cloud-provider v0.36.0
 	k8s.io/component-base v0.36.0
+	k8s.io/controller-manager v0.36.0
 	k8s.io/klog/v2 v2.140.0
 )
 
@@ -111,7 +112,6 @@ require (
 	gopkg.in/natefinch/lumberjack.v2 v2.2.1 // indirect
 	k8s.io/apiserver v0.36.0 // indirect
 	k8s.io/component-helpers v0.36.0 // indirect
-	k8s.io/controller-manager v0.36.0 // indirect
 	k8s.io/kms v0.36.0 // indirect
 	k8s.io/kube-openapi v0.0.0-20260414162039-ec9c827d403f // indirect
 	k8s.io/streaming v0.36.0 // indirect
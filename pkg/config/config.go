@@ -23,12 +23,17 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"regexp"
 	"slices"
 	"strings"
+	"text/template"
+	"time"
 
 	yaml "gopkg.in/yaml.v3"
 
 	"github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/proxmoxpool"
+
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 // Provider specifies the provider. Can be 'default' or 'capmox'
@@ -63,6 +68,244 @@ type NetworkOpts struct {
 	Mode                NetworkMode `yaml:"mode,omitempty"`
 }
 
+// LoadBalancerPool defines an IP address pool for LoadBalancer services.
+type LoadBalancerPool struct {
+	// Name is the pool name, used in the service annotation to request the pool.
+	Name string `yaml:"name"`
+	// Region is the Proxmox region where the pool addresses are routed.
+	Region string `yaml:"region"`
+	// Zone limits the pool to a single zone of the region, optional.
+	Zone string `yaml:"zone,omitempty"`
+	// Addresses is a comma-separated list of CIDRs or IP ranges (first-last).
+	Addresses string `yaml:"addresses"`
+}
+
+// LoadBalancerOpts specifies the load balancer options for the cloud provider.
+type LoadBalancerOpts struct {
+	// Pools is a list of IP address pools, the order defines the pool priority.
+	Pools []LoadBalancerPool `yaml:"pools,omitempty"`
+}
+
+// SDNOpts specifies the Proxmox SDN zone and vnet.
+type SDNOpts struct {
+	// Zone is the SDN zone name of the vnet, optional.
+	Zone string `yaml:"zone,omitempty"`
+	// VNet is the SDN vnet name.
+	VNet string `yaml:"vnet,omitempty"`
+}
+
+// NodeIPAMOpts specifies the Proxmox SDN vnet used to allocate the node pod CIDRs.
+type NodeIPAMOpts struct {
+	// Zone is the SDN zone name of the vnet, optional.
+	Zone string `yaml:"zone,omitempty"`
+	// VNet is the SDN vnet name, its subnets are split into the node pod CIDRs.
+	VNet string `yaml:"vnet,omitempty"`
+	// NodeMaskSizeIPv4 is the mask size of the node IPv4 pod CIDR.
+	// Default is 24.
+	NodeMaskSizeIPv4 int `yaml:"node_mask_size_ipv4,omitempty"`
+	// NodeMaskSizeIPv6 is the mask size of the node IPv6 pod CIDR.
+	// Default is 64.
+	NodeMaskSizeIPv6 int `yaml:"node_mask_size_ipv6,omitempty"`
+}
+
+// NodeGCAction is the action of the node garbage collector on the VM of the deleted node.
+type NodeGCAction string
+
+const (
+	// NodeGCActionStop stops the VM.
+	NodeGCActionStop NodeGCAction = "stop"
+	// NodeGCActionDelete stops and deletes the VM with its disks.
+	NodeGCActionDelete NodeGCAction = "delete"
+)
+
+// ValidNodeGCActions is a list of valid node garbage collector actions.
+var ValidNodeGCActions = []NodeGCAction{NodeGCActionStop, NodeGCActionDelete}
+
+// NodeGCOpts specifies the garbage collection of the VMs of the deleted Kubernetes nodes.
+type NodeGCOpts struct {
+	// Action is the default action, stop or delete.
+	// Default is stop.
+	Action NodeGCAction `yaml:"action,omitempty"`
+	// Selector is the label selector of the nodes, in addition to the nodes with the annotation.
+	Selector string `yaml:"selector,omitempty"`
+	// GracePeriod is the delay after the node deletion before the action.
+	// Default is 1m.
+	GracePeriod time.Duration `yaml:"grace_period,omitempty"`
+	// DryRun only records the events, the VMs are not changed.
+	DryRun bool `yaml:"dry_run,omitempty"`
+}
+
+// HAMaintenanceOpts specifies the node taint of the VMs on the Proxmox nodes in the HA maintenance mode.
+type HAMaintenanceOpts struct {
+	// Taint is the node taint, the key is optional.
+	// Default is proxmox.sinextra.dev/ha-maintenance:NoSchedule.
+	Taint TagTaint `yaml:"taint,omitempty"`
+	// Cordon marks the nodes unschedulable in addition to the taint.
+	Cordon bool `yaml:"cordon,omitempty"`
+}
+
+// HostHealthOpts specifies the node condition and the taint of the VMs on the unhealthy Proxmox nodes.
+type HostHealthOpts struct {
+	// TaintAfter is the delay after the Proxmox node became not ready before the NoExecute taint is applied.
+	// The taint is not used if it is zero.
+	TaintAfter time.Duration `yaml:"taint_after,omitempty"`
+}
+
+// HAGroupZoneOpts specifies how the zone is chosen from the HA groups of the VM.
+type HAGroupZoneOpts struct {
+	// Preference is the list of the preferred HA groups, the first listed group of the VM is the zone.
+	Preference []string `yaml:"preference,omitempty"`
+	// PreferRestricted prefers the restricted HA groups, or the strict node affinity rules on Proxmox VE 9,
+	// over the node priority.
+	PreferRestricted bool `yaml:"prefer_restricted,omitempty"`
+	// ByPriority prefers the HA group with the highest priority of the Proxmox node over the group name.
+	ByPriority bool `yaml:"by_priority,omitempty"`
+}
+
+// ZoneStrategy specifies how the zone of the node is derived.
+type ZoneStrategy string
+
+const (
+	// ZoneStrategyNode uses the Proxmox node name as the zone.
+	ZoneStrategyNode ZoneStrategy = "node"
+	// ZoneStrategyHAGroup uses the HA group of the VM as the zone.
+	ZoneStrategyHAGroup ZoneStrategy = "ha_group"
+	// ZoneStrategyPool uses the resource pool of the VM as the zone.
+	ZoneStrategyPool ZoneStrategy = "pool"
+	// ZoneStrategyStatic maps the Proxmox node name to the zone.
+	ZoneStrategyStatic ZoneStrategy = "static"
+	// ZoneStrategyRegex extracts the zone from the Proxmox node name with the regular expression.
+	ZoneStrategyRegex ZoneStrategy = "regex"
+	// ZoneStrategyHostDescription reads the zone from the Proxmox node description.
+	ZoneStrategyHostDescription ZoneStrategy = "host_description"
+	// ZoneStrategyCeph uses the Ceph CRUSH failure domain of the Proxmox node as the zone.
+	ZoneStrategyCeph ZoneStrategy = "ceph"
+)
+
+// ValidZoneStrategies is a list of valid zone strategies.
+var ValidZoneStrategies = []ZoneStrategy{
+	ZoneStrategyNode, ZoneStrategyHAGroup, ZoneStrategyPool, ZoneStrategyStatic,
+	ZoneStrategyRegex, ZoneStrategyHostDescription, ZoneStrategyCeph,
+}
+
+// ZoneOpts specifies how the zone of the node is derived.
+type ZoneOpts struct {
+	// Strategy is one of node, ha_group, pool, static, regex, host_description or ceph.
+	// Default is ha_group if HAGroup is enabled, pool if PoolAsZone is enabled, or node.
+	Strategy ZoneStrategy `yaml:"strategy,omitempty"`
+	// Static maps the Proxmox node names to the zones.
+	Static map[string]string `yaml:"static,omitempty"`
+	// Regex is the regular expression over the Proxmox node name, the first capture group is the zone.
+	// The whole match is the zone if the expression does not have capture groups.
+	Regex string `yaml:"regex,omitempty"`
+	// DescriptionKey is the key of the zone in the Proxmox node description, as key=value or key: value.
+	// Default is zone.
+	DescriptionKey string `yaml:"description_key,omitempty"`
+	// CephFailureDomain is the CRUSH bucket type of the Proxmox node used as the zone.
+	// Default is rack.
+	CephFailureDomain string `yaml:"ceph_failure_domain,omitempty"`
+}
+
+// InstanceState is the instance state reported to the node lifecycle controller.
+type InstanceState string
+
+const (
+	// InstanceStateRunning reports the instance as existing and running.
+	InstanceStateRunning InstanceState = "running"
+	// InstanceStateShutdown reports the instance as shutdown, the node gets the shutdown taint.
+	InstanceStateShutdown InstanceState = "shutdown"
+	// InstanceStateAbsent reports the instance as not existing, the node is deleted.
+	InstanceStateAbsent InstanceState = "absent"
+)
+
+// ValidInstanceStates is a list of valid instance states.
+var ValidInstanceStates = []InstanceState{InstanceStateRunning, InstanceStateShutdown, InstanceStateAbsent}
+
+// PowerStateOpts maps the Proxmox VM states to the instance state.
+// The lock has the highest priority, then the HA state, the QEMU status and the VM status.
+type PowerStateOpts struct {
+	// Status maps the VM status or the QEMU status: running, stopped, paused, prelaunch, suspended, unknown.
+	Status map[string]InstanceState `yaml:"status,omitempty"`
+	// HA maps the HA resource state: started, stopped, request_stop, fence, error, ...
+	HA map[string]InstanceState `yaml:"ha,omitempty"`
+	// Lock maps the VM lock: backup, migrate, suspending, suspended (hibernated), ...
+	Lock map[string]InstanceState `yaml:"lock,omitempty"`
+}
+
+// ClusterTagOpts scopes the VM discovery to the VMs with the Proxmox tag of the Kubernetes cluster.
+type ClusterTagOpts struct {
+	// Enabled specifies if only the VMs with the tag are considered.
+	// Default is false.
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Name is the Proxmox tag of the Kubernetes cluster VMs.
+	// Default is k8s-<cluster-name>, the cluster name is the --cluster-name flag.
+	Name string `yaml:"name,omitempty"`
+}
+
+// TagMatch specifies how the VM tag is matched by the tag rule.
+type TagMatch string
+
+const (
+	// TagMatchExact matches the VM tag equal to the rule tag.
+	TagMatchExact TagMatch = "exact"
+	// TagMatchPrefix matches the VM tag which starts with the rule tag.
+	TagMatchPrefix TagMatch = "prefix"
+	// TagMatchRegex matches the VM tag with the regular expression of the rule tag.
+	TagMatchRegex TagMatch = "regex"
+)
+
+// InstanceTypeNameRegexp is the valid instance type name.
+var InstanceTypeNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// ValidTagMatches is a list of valid tag rule matches.
+var ValidTagMatches = []TagMatch{TagMatchExact, TagMatchPrefix, TagMatchRegex}
+
+// ValidTaintEffects is a list of valid taint effects of the tag rules.
+var ValidTaintEffects = []string{"NoSchedule", "PreferNoSchedule", "NoExecute"}
+
+// TagTaint is the node taint of the tag rule.
+type TagTaint struct {
+	Key    string `yaml:"key"`
+	Value  string `yaml:"value,omitempty"`
+	Effect string `yaml:"effect"`
+}
+
+// TagRule maps the VM tag to the node labels and taints.
+type TagRule struct {
+	// Tag is the VM tag, the tag prefix or the regular expression.
+	Tag string `yaml:"tag"`
+	// Match is one of exact, prefix or regex.
+	// Default is exact.
+	Match TagMatch `yaml:"match,omitempty"`
+	// Labels are the node labels, the regex capture groups can be used in the keys and values as ${1}.
+	Labels map[string]string `yaml:"labels,omitempty"`
+	// Taints are the node taints, the regex capture groups can be used in the values as ${1}.
+	Taints []TagTaint `yaml:"taints,omitempty"`
+}
+
+// InstanceTypeEntry maps the VM shape to the named instance type.
+type InstanceTypeEntry struct {
+	// Name is the instance type name, for example m.large.
+	Name string `yaml:"name"`
+	// CPUs is the number of vCPUs.
+	CPUs int `yaml:"cpus"`
+	// Memory is the VM memory in MiB.
+	Memory uint64 `yaml:"memory"`
+	// Sockets is the number of CPU sockets, optional.
+	Sockets int `yaml:"sockets,omitempty"`
+	// CPUType is the CPU model, optional.
+	CPUType string `yaml:"cpu_type,omitempty"`
+}
+
+// InstanceTypeOpts specifies the instance type naming.
+type InstanceTypeOpts struct {
+	// Template is the Go template of the instance type name.
+	// Default is {{ .CPUs }}VCPU-<memory in GiB>GB.
+	Template string `yaml:"template,omitempty"`
+	// Catalogue maps the VM shapes to the named instance types, the first matching entry wins.
+	Catalogue []InstanceTypeEntry `yaml:"catalogue,omitempty"`
+}
+
 // ClustersFeatures specifies the features for the cloud provider.
 type ClustersFeatures struct {
 	// HAGroup specifies if the provider should use HA groups to determine node zone.
@@ -70,6 +313,15 @@ type ClustersFeatures struct {
 	// If disabled, the provider will use the node's cluster name as the zone name.
 	// Default is false.
 	HAGroup bool `yaml:"ha_group,omitempty"`
+	// HAGroupZone specifies how the zone is chosen from the HA groups of the VM.
+	// Default is the first HA group by name.
+	HAGroupZone HAGroupZoneOpts `yaml:"ha_group_zone,omitempty"`
+	// PoolAsZone specifies if the provider should use the Proxmox resource pool name as the zone name.
+	// It cannot be used together with HAGroup.
+	// Default is false.
+	PoolAsZone bool `yaml:"pool_as_zone,omitempty"`
+	// Zone specifies how the zone of the node is derived, it replaces HAGroup and PoolAsZone.
+	Zone ZoneOpts `yaml:"zone,omitempty"`
 	// Provider specifies the provider to use. Can be 'default' or 'capmox'.
 	// Default is 'default'.
 	Provider Provider `yaml:"provider,omitempty"`
@@ -80,6 +332,47 @@ type ClustersFeatures struct {
 	// a VM is migrated to a different zone within the Proxmox cluster.
 	// Default is false.
 	ForceUpdateLabels bool `yaml:"force_update_labels,omitempty"`
+	// LoadBalancer specifies the IP address pools for LoadBalancer services.
+	// The load balancer interface is enabled only if at least one pool is defined.
+	LoadBalancer LoadBalancerOpts `yaml:"load_balancer,omitempty"`
+	// Routes specifies the SDN vnet where the node pod CIDRs are created as subnets.
+	// The routes interface is enabled only if the vnet is defined.
+	Routes SDNOpts `yaml:"routes,omitempty"`
+	// NodeIPAM specifies the SDN vnet where the node pod CIDRs are allocated from.
+	// The node-ipam controller is enabled only if the vnet is defined.
+	NodeIPAM NodeIPAMOpts `yaml:"node_ipam,omitempty"`
+	// InventoryTTL specifies the lifetime of the VM inventory cache.
+	// Default is 1m.
+	InventoryTTL time.Duration `yaml:"inventory_ttl,omitempty"`
+	// PowerState overrides the default mapping of the Proxmox VM states to the instance state.
+	PowerState PowerStateOpts `yaml:"power_state,omitempty"`
+	// NameMatching specifies how the node name is matched with the VM name.
+	// Default is the prefix strategy, the VM name starts with the node name.
+	NameMatching proxmoxpool.NameMatchingOpts `yaml:"name_matching,omitempty"`
+	// ClusterTag scopes the VM discovery to the VMs with the Proxmox tag.
+	ClusterTag ClusterTagOpts `yaml:"cluster_tag,omitempty"`
+	// TagRules map the VM tags to the node labels and taints.
+	TagRules []TagRule `yaml:"tag_rules,omitempty"`
+	// InstanceType specifies the instance type naming.
+	// The VM SMBIOS SKU has the priority over the catalogue and the template.
+	InstanceType InstanceTypeOpts `yaml:"instance_type,omitempty"`
+	// HardwareLabels specifies if the provider should label the nodes with the VM hardware configuration,
+	// CPU type, machine type, BIOS, NUMA, hugepages and PCI passthrough devices.
+	// Default is false.
+	HardwareLabels bool `yaml:"hardware_labels,omitempty"`
+	// HostLabels specifies if the provider should label the nodes with the Proxmox node information,
+	// host name, PVE version, CPU model, kernel, KSM and Ceph.
+	// Default is false.
+	HostLabels bool `yaml:"host_labels,omitempty"`
+	// NodeGC specifies the garbage collection of the VMs of the deleted nodes.
+	// The node-gc controller is disabled by default, it must be enabled with the --controllers flag.
+	NodeGC NodeGCOpts `yaml:"node_gc,omitempty"`
+	// HAMaintenance specifies the node taint of the VMs on the Proxmox nodes in the HA maintenance mode.
+	// The ha-maintenance controller is disabled by default, it must be enabled with the --controllers flag.
+	HAMaintenance HAMaintenanceOpts `yaml:"ha_maintenance,omitempty"`
+	// HostHealth specifies the node condition and the taint of the VMs on the unhealthy Proxmox nodes.
+	// The host-health controller is disabled by default, it must be enabled with the --controllers flag.
+	HostHealth HostHealthOpts `yaml:"host_health,omitempty"`
 }
 
 // ClustersConfig is proxmox multi-cluster cloud config.
@@ -90,12 +383,24 @@ type ClustersConfig struct {
 
 // Errors for Reading Cloud Config
 var (
-	ErrMissingPVERegion       = errors.New("missing PVE region in cloud config")
-	ErrMissingPVEAPIURL       = errors.New("missing PVE API URL in cloud config")
-	ErrAuthCredentialsMissing = errors.New("user, token or file credentials are required")
-	ErrInvalidAuthCredentials = errors.New("must specify one of user, token or file credentials, not multiple")
-	ErrInvalidCloudConfig     = errors.New("invalid cloud config")
-	ErrInvalidNetworkMode     = fmt.Errorf("invalid network mode, valid modes are %v", ValidNetworkModes)
+	ErrMissingPVERegion        = errors.New("missing PVE region in cloud config")
+	ErrInvalidPVERegion        = errors.New("region and region_from_cluster_name cannot be used together in cloud config")
+	ErrDuplicatePVERegion      = errors.New("duplicate PVE region in cloud config")
+	ErrMissingPVEAPIURL        = errors.New("missing PVE API URL in cloud config")
+	ErrAuthCredentialsMissing  = errors.New("user, token or file credentials are required")
+	ErrInvalidAuthCredentials  = errors.New("must specify one of user, token or file credentials, not multiple")
+	ErrInvalidCloudConfig      = errors.New("invalid cloud config")
+	ErrInvalidNetworkMode      = fmt.Errorf("invalid network mode, valid modes are %v", ValidNetworkModes)
+	ErrInvalidLoadBalancerPool = errors.New("invalid load balancer pool, name, region and addresses are required")
+	ErrInvalidNodeIPAM         = errors.New("invalid node ipam mask size")
+	ErrInvalidPowerState       = fmt.Errorf("invalid power state, valid states are %v", ValidInstanceStates)
+	ErrInvalidNameMatching     = errors.New("invalid name matching")
+	ErrInvalidTagRule          = errors.New("invalid tag rule, tag, valid match and taint effect are required")
+	ErrInvalidInstanceType     = errors.New("invalid instance type, valid name, cpus and memory are required")
+	ErrInvalidZone             = fmt.Errorf("invalid zone, valid strategies are %v, ha_group and pool_as_zone cannot be used together", ValidZoneStrategies)
+	ErrInvalidNodeGC           = fmt.Errorf("invalid node gc, valid actions are %v", ValidNodeGCActions)
+	ErrInvalidHAMaintenance    = fmt.Errorf("invalid ha maintenance taint, valid effects are %v", ValidTaintEffects)
+	ErrInvalidHostHealth       = errors.New("invalid host health, taint delay must not be negative")
 )
 
 // ReadCloudConfig reads cloud config from a reader.
@@ -121,11 +426,20 @@ func ReadCloudConfig(config io.Reader) (ClustersConfig, error) {
 			return ClustersConfig{}, fmt.Errorf("cluster #%d: %w", idx+1, ErrAuthCredentialsMissing)
 		}
 
-		if c.Region == "" {
+		if c.Region == "" && !c.RegionFromClusterName {
 			return ClustersConfig{}, fmt.Errorf("cluster #%d: %w", idx+1, ErrMissingPVERegion)
 		}
 
-		if c.URL == "" || !strings.HasPrefix(c.URL, "http") {
+		if c.Region != "" && c.RegionFromClusterName {
+			return ClustersConfig{}, fmt.Errorf("cluster #%d: %w", idx+1, ErrInvalidPVERegion)
+		}
+
+		if c.Region != "" && slices.ContainsFunc(cfg.Clusters[:idx], func(p *proxmoxpool.ProxmoxCluster) bool { return p.Region == c.Region }) {
+			return ClustersConfig{}, fmt.Errorf("cluster #%d: %w", idx+1, ErrDuplicatePVERegion)
+		}
+
+		urls := c.Endpoints()
+		if len(urls) == 0 || slices.ContainsFunc(urls, func(u string) bool { return !strings.HasPrefix(u, "http") }) {
 			return ClustersConfig{}, fmt.Errorf("cluster #%d: %w", idx+1, ErrMissingPVEAPIURL)
 		}
 	}
@@ -143,9 +457,159 @@ func ReadCloudConfig(config io.Reader) (ClustersConfig, error) {
 		return ClustersConfig{}, ErrInvalidNetworkMode
 	}
 
+	if err := validateZone(&cfg.Features); err != nil {
+		return ClustersConfig{}, err
+	}
+
+	pools := map[string]bool{}
+
+	for idx, p := range cfg.Features.LoadBalancer.Pools {
+		if p.Name == "" || p.Region == "" || strings.TrimSpace(p.Addresses) == "" || pools[p.Name] {
+			return ClustersConfig{}, fmt.Errorf("load balancer pool #%d: %w", idx+1, ErrInvalidLoadBalancerPool)
+		}
+
+		pools[p.Name] = true
+	}
+
+	if cfg.Features.NodeIPAM.VNet != "" {
+		if cfg.Features.NodeIPAM.NodeMaskSizeIPv4 == 0 {
+			cfg.Features.NodeIPAM.NodeMaskSizeIPv4 = 24
+		}
+
+		if cfg.Features.NodeIPAM.NodeMaskSizeIPv6 == 0 {
+			cfg.Features.NodeIPAM.NodeMaskSizeIPv6 = 64
+		}
+
+		if cfg.Features.NodeIPAM.NodeMaskSizeIPv4 > 32 || cfg.Features.NodeIPAM.NodeMaskSizeIPv6 > 128 {
+			return ClustersConfig{}, ErrInvalidNodeIPAM
+		}
+	}
+
+	if cfg.Features.NodeGC.Action == "" {
+		cfg.Features.NodeGC.Action = NodeGCActionStop
+	}
+
+	if cfg.Features.NodeGC.GracePeriod == 0 {
+		cfg.Features.NodeGC.GracePeriod = time.Minute
+	}
+
+	if !slices.Contains(ValidNodeGCActions, cfg.Features.NodeGC.Action) || cfg.Features.NodeGC.GracePeriod < 0 {
+		return ClustersConfig{}, ErrInvalidNodeGC
+	}
+
+	if _, err := labels.Parse(cfg.Features.NodeGC.Selector); err != nil {
+		return ClustersConfig{}, errors.Join(ErrInvalidNodeGC, err)
+	}
+
+	if cfg.Features.HAMaintenance.Taint.Effect == "" {
+		cfg.Features.HAMaintenance.Taint.Effect = "NoSchedule"
+	}
+
+	if !slices.Contains(ValidTaintEffects, cfg.Features.HAMaintenance.Taint.Effect) {
+		return ClustersConfig{}, ErrInvalidHAMaintenance
+	}
+
+	if cfg.Features.HostHealth.TaintAfter < 0 {
+		return ClustersConfig{}, ErrInvalidHostHealth
+	}
+
+	for _, states := range []map[string]InstanceState{cfg.Features.PowerState.Status, cfg.Features.PowerState.HA, cfg.Features.PowerState.Lock} {
+		for state, instanceState := range states {
+			if !slices.Contains(ValidInstanceStates, instanceState) {
+				return ClustersConfig{}, fmt.Errorf("power state %s: %w", state, ErrInvalidPowerState)
+			}
+		}
+	}
+
+	if _, err := proxmoxpool.NewNameMatcher(cfg.Features.NameMatching); err != nil {
+		return ClustersConfig{}, errors.Join(ErrInvalidNameMatching, err)
+	}
+
+	if cfg.Features.InstanceType.Template != "" {
+		if _, err := template.New("instance-type").Parse(cfg.Features.InstanceType.Template); err != nil {
+			return ClustersConfig{}, errors.Join(ErrInvalidInstanceType, err)
+		}
+	}
+
+	for idx, e := range cfg.Features.InstanceType.Catalogue {
+		if !InstanceTypeNameRegexp.MatchString(e.Name) || e.CPUs <= 0 || e.Memory == 0 {
+			return ClustersConfig{}, fmt.Errorf("instance type #%d: %w", idx+1, ErrInvalidInstanceType)
+		}
+	}
+
+	for idx := range cfg.Features.TagRules {
+		r := &cfg.Features.TagRules[idx]
+		if r.Match == "" {
+			r.Match = TagMatchExact
+		}
+
+		if r.Tag == "" || !slices.Contains(ValidTagMatches, r.Match) {
+			return ClustersConfig{}, fmt.Errorf("tag rule #%d: %w", idx+1, ErrInvalidTagRule)
+		}
+
+		if r.Match == TagMatchRegex {
+			if _, err := regexp.Compile(r.Tag); err != nil {
+				return ClustersConfig{}, fmt.Errorf("tag rule #%d: %w", idx+1, errors.Join(ErrInvalidTagRule, err))
+			}
+		}
+
+		for _, t := range r.Taints {
+			if t.Key == "" || !slices.Contains(ValidTaintEffects, t.Effect) {
+				return ClustersConfig{}, fmt.Errorf("tag rule #%d: %w", idx+1, ErrInvalidTagRule)
+			}
+		}
+	}
+
 	return cfg, nil
 }
 
+func validateZone(features *ClustersFeatures) error {
+	zone := &features.Zone
+
+	switch {
+	case features.HAGroup && features.PoolAsZone:
+		return ErrInvalidZone
+	case features.HAGroup && zone.Strategy != "" && zone.Strategy != ZoneStrategyHAGroup,
+		features.PoolAsZone && zone.Strategy != "" && zone.Strategy != ZoneStrategyPool:
+		return ErrInvalidZone
+	case zone.Strategy == "" && features.HAGroup:
+		zone.Strategy = ZoneStrategyHAGroup
+	case zone.Strategy == "" && features.PoolAsZone:
+		zone.Strategy = ZoneStrategyPool
+	case zone.Strategy == "":
+		zone.Strategy = ZoneStrategyNode
+	}
+
+	if zone.DescriptionKey == "" {
+		zone.DescriptionKey = "zone"
+	}
+
+	if zone.CephFailureDomain == "" {
+		zone.CephFailureDomain = "rack"
+	}
+
+	switch zone.Strategy {
+	case ZoneStrategyStatic:
+		if len(zone.Static) == 0 {
+			return fmt.Errorf("zone strategy %s requires the static map: %w", zone.Strategy, ErrInvalidZone)
+		}
+	case ZoneStrategyRegex:
+		if zone.Regex == "" {
+			return fmt.Errorf("zone strategy %s requires the regex: %w", zone.Strategy, ErrInvalidZone)
+		}
+
+		if _, err := regexp.Compile(zone.Regex); err != nil {
+			return errors.Join(ErrInvalidZone, err)
+		}
+	default:
+		if !slices.Contains(ValidZoneStrategies, zone.Strategy) {
+			return ErrInvalidZone
+		}
+	}
+
+	return nil
+}
+
 // ReadCloudConfigFromFile reads cloud config from a file.
 func ReadCloudConfigFromFile(file string) (ClustersConfig, error) {
 	f, err := os.Open(filepath.Clean(file))
@@ -19,6 +19,7 @@ package config_test
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -175,6 +176,66 @@ clusters:
 	assert.NotNil(t, err)
 	assert.ErrorIs(t, err, providerconfig.ErrMissingPVERegion)
 
+	// Region from the cluster name
+	cfg, err = providerconfig.ReadCloudConfig(strings.NewReader(`
+clusters:
+  - url: https://example.com
+    username: "user@pam"
+    password: "secret"
+    region_from_cluster_name: true
+`))
+	assert.Nil(t, err)
+	assert.True(t, cfg.Clusters[0].RegionFromClusterName)
+
+	// Several API endpoints
+	cfg, err = providerconfig.ReadCloudConfig(strings.NewReader(`
+clusters:
+  - urls:
+      - https://pve-1.example.com:8006/api2/json
+      - https://pve-2.example.com:8006/api2/json
+    username: "user@pam"
+    password: "secret"
+    region: cluster-1
+`))
+	assert.Nil(t, err)
+	assert.Len(t, cfg.Clusters[0].Endpoints(), 2)
+
+	_, err = providerconfig.ReadCloudConfig(strings.NewReader(`
+clusters:
+  - url: https://pve-1.example.com:8006/api2/json
+    urls:
+      - pve-2.example.com
+    username: "user@pam"
+    password: "secret"
+    region: cluster-1
+`))
+	assert.ErrorIs(t, err, providerconfig.ErrMissingPVEAPIURL)
+
+	// Errors when region and region_from_cluster_name are set
+	_, err = providerconfig.ReadCloudConfig(strings.NewReader(`
+clusters:
+  - url: https://example.com
+    username: "user@pam"
+    password: "secret"
+    region: cluster-1
+    region_from_cluster_name: true
+`))
+	assert.ErrorIs(t, err, providerconfig.ErrInvalidPVERegion)
+
+	// Errors when the region is duplicated
+	_, err = providerconfig.ReadCloudConfig(strings.NewReader(`
+clusters:
+  - url: https://example.com
+    username: "user@pam"
+    password: "secret"
+    region: cluster-1
+  - url: https://example.org
+    username: "user@pam"
+    password: "secret"
+    region: cluster-1
+`))
+	assert.ErrorIs(t, err, providerconfig.ErrDuplicatePVERegion)
+
 	// Errors when empty url
 	_, err = providerconfig.ReadCloudConfig(strings.NewReader(`
 features:
@@ -220,6 +281,294 @@ features:
 	assert.NotNil(t, err)
 }
 
+func TestLoadBalancerConfig(t *testing.T) {
+	cfg, err := providerconfig.ReadCloudConfig(strings.NewReader(`
+features:
+  load_balancer:
+    pools:
+      - name: default
+        region: cluster-1
+        addresses: 10.0.0.0/24
+      - name: zone-a
+        region: cluster-1
+        zone: pve-1
+        addresses: 10.0.1.10-10.0.1.20
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(cfg.Features.LoadBalancer.Pools))
+	assert.Equal(t, "pve-1", cfg.Features.LoadBalancer.Pools[1].Zone)
+
+	// Duplicate pool name
+	_, err = providerconfig.ReadCloudConfig(strings.NewReader(`
+features:
+  load_balancer:
+    pools:
+      - name: default
+        region: cluster-1
+        addresses: 10.0.0.0/24
+      - name: default
+        region: cluster-2
+        addresses: 10.0.1.0/24
+`))
+	assert.ErrorIs(t, err, providerconfig.ErrInvalidLoadBalancerPool)
+
+	// Missing addresses
+	_, err = providerconfig.ReadCloudConfig(strings.NewReader(`
+features:
+  load_balancer:
+    pools:
+      - name: default
+        region: cluster-1
+`))
+	assert.ErrorIs(t, err, providerconfig.ErrInvalidLoadBalancerPool)
+}
+
+func TestNodeIPAMConfig(t *testing.T) {
+	cfg, err := providerconfig.ReadCloudConfig(strings.NewReader(`
+features:
+  node_ipam:
+    vnet: pods
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, "pods", cfg.Features.NodeIPAM.VNet)
+	assert.Equal(t, 24, cfg.Features.NodeIPAM.NodeMaskSizeIPv4)
+	assert.Equal(t, 64, cfg.Features.NodeIPAM.NodeMaskSizeIPv6)
+
+	_, err = providerconfig.ReadCloudConfig(strings.NewReader(`
+features:
+  node_ipam:
+    vnet: pods
+    node_mask_size_ipv4: 33
+`))
+	assert.ErrorIs(t, err, providerconfig.ErrInvalidNodeIPAM)
+}
+
+func TestZoneConfig(t *testing.T) {
+	cfg, err := providerconfig.ReadCloudConfig(strings.NewReader(`
+features:
+  pool_as_zone: true
+`))
+	assert.Nil(t, err)
+	assert.True(t, cfg.Features.PoolAsZone)
+	assert.Equal(t, providerconfig.ZoneStrategyPool, cfg.Features.Zone.Strategy)
+
+	cfg, err = providerconfig.ReadCloudConfig(strings.NewReader(`
+features:
+  ha_group: true
+  ha_group_zone:
+    preference: [rnd, dev]
+    prefer_restricted: true
+    by_priority: true
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"rnd", "dev"}, cfg.Features.HAGroupZone.Preference)
+	assert.True(t, cfg.Features.HAGroupZone.PreferRestricted)
+	assert.True(t, cfg.Features.HAGroupZone.ByPriority)
+	assert.Equal(t, providerconfig.ZoneStrategyHAGroup, cfg.Features.Zone.Strategy)
+
+	cfg, err = providerconfig.ReadCloudConfig(strings.NewReader(`
+features:
+  zone:
+    strategy: static
+    static:
+      pve-1: zone-a
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, providerconfig.ZoneStrategyStatic, cfg.Features.Zone.Strategy)
+	assert.Equal(t, "zone", cfg.Features.Zone.DescriptionKey)
+	assert.Equal(t, "rack", cfg.Features.Zone.CephFailureDomain)
+
+	cfg, err = providerconfig.ReadCloudConfig(strings.NewReader(`
+features: {}
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, providerconfig.ZoneStrategyNode, cfg.Features.Zone.Strategy)
+
+	for _, zone := range []string{
+		`{strategy: unknown}`,
+		`{strategy: static}`,
+		`{strategy: regex}`,
+		`{strategy: regex, regex: "^(pve"}`,
+	} {
+		_, err = providerconfig.ReadCloudConfig(strings.NewReader("features:\n  zone: " + zone + "\n"))
+		assert.ErrorIs(t, err, providerconfig.ErrInvalidZone, zone)
+	}
+
+	_, err = providerconfig.ReadCloudConfig(strings.NewReader(`
+features:
+  ha_group: true
+  zone:
+    strategy: ceph
+`))
+	assert.ErrorIs(t, err, providerconfig.ErrInvalidZone)
+
+	_, err = providerconfig.ReadCloudConfig(strings.NewReader(`
+features:
+  ha_group: true
+  pool_as_zone: true
+`))
+	assert.ErrorIs(t, err, providerconfig.ErrInvalidZone)
+}
+
+func TestPowerStateConfig(t *testing.T) {
+	cfg, err := providerconfig.ReadCloudConfig(strings.NewReader(`
+features:
+  power_state:
+    status:
+      paused: running
+    lock:
+      backup: shutdown
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, providerconfig.InstanceStateRunning, cfg.Features.PowerState.Status["paused"])
+	assert.Equal(t, providerconfig.InstanceStateShutdown, cfg.Features.PowerState.Lock["backup"])
+
+	_, err = providerconfig.ReadCloudConfig(strings.NewReader(`
+features:
+  power_state:
+    ha:
+      request_stop: stopped
+`))
+	assert.ErrorIs(t, err, providerconfig.ErrInvalidPowerState)
+}
+
+func TestNameMatchingConfig(t *testing.T) {
+	cfg, err := providerconfig.ReadCloudConfig(strings.NewReader(`
+features:
+  name_matching:
+    strategy: regex
+    regex: '^k8s-(.+)$'
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, "regex", cfg.Features.NameMatching.Strategy)
+
+	_, err = providerconfig.ReadCloudConfig(strings.NewReader(`
+features:
+  name_matching:
+    strategy: suffix
+`))
+	assert.ErrorIs(t, err, providerconfig.ErrInvalidNameMatching)
+
+	_, err = providerconfig.ReadCloudConfig(strings.NewReader(`
+features:
+  name_matching:
+    strategy: template
+    template: '{{ .NodeName'
+`))
+	assert.ErrorIs(t, err, providerconfig.ErrInvalidNameMatching)
+}
+
+func TestTagRulesConfig(t *testing.T) {
+	cfg, err := providerconfig.ReadCloudConfig(strings.NewReader(`
+features:
+  tag_rules:
+    - tag: gpu
+      labels:
+        node-role.kubernetes.io/gpu: ""
+      taints:
+        - key: nvidia.com/gpu
+          effect: NoSchedule
+    - tag: '^zone-(.+)$'
+      match: regex
+      labels:
+        example.com/zone: '${1}'
+`))
+	assert.Nil(t, err)
+	assert.Len(t, cfg.Features.TagRules, 2)
+	assert.Equal(t, providerconfig.TagMatchExact, cfg.Features.TagRules[0].Match)
+	assert.Equal(t, providerconfig.TagMatchRegex, cfg.Features.TagRules[1].Match)
+
+	for _, rules := range []string{
+		"[{tag: gpu, match: suffix}]",
+		"[{tag: '^gpu-(.+$', match: regex}]",
+		"[{tag: gpu, taints: [{key: gpu, effect: Never}]}]",
+		"[{labels: {gpu: ''}}]",
+	} {
+		_, err = providerconfig.ReadCloudConfig(strings.NewReader("features:\n  tag_rules: " + rules))
+		assert.ErrorIs(t, err, providerconfig.ErrInvalidTagRule, rules)
+	}
+}
+
+func TestInstanceTypeConfig(t *testing.T) {
+	cfg, err := providerconfig.ReadCloudConfig(strings.NewReader(`
+features:
+  instance_type:
+    template: '{{ .CPUs }}VCPU-{{ .MemoryMiB }}MB'
+    catalogue:
+      - name: m.large
+        cpus: 4
+        memory: 8192
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, []providerconfig.InstanceTypeEntry{{Name: "m.large", CPUs: 4, Memory: 8192}}, cfg.Features.InstanceType.Catalogue)
+
+	for _, opts := range []string{
+		"{template: '{{ .CPUs '}",
+		"{catalogue: [{name: 'm large', cpus: 4, memory: 8192}]}",
+		"{catalogue: [{name: m.large, memory: 8192}]}",
+	} {
+		_, err = providerconfig.ReadCloudConfig(strings.NewReader("features:\n  instance_type: " + opts))
+		assert.ErrorIs(t, err, providerconfig.ErrInvalidInstanceType, opts)
+	}
+}
+
+func TestNodeGCConfig(t *testing.T) {
+	cfg, err := providerconfig.ReadCloudConfig(strings.NewReader(`
+features:
+  node_gc:
+    selector: node-role.kubernetes.io/worker
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, providerconfig.NodeGCActionStop, cfg.Features.NodeGC.Action)
+	assert.Equal(t, time.Minute, cfg.Features.NodeGC.GracePeriod)
+
+	for _, data := range []string{
+		"action: destroy",
+		"grace_period: -1m",
+		"selector: 'role in (worker'",
+	} {
+		_, err = providerconfig.ReadCloudConfig(strings.NewReader("features:\n  node_gc:\n    " + data + "\n"))
+		assert.ErrorIs(t, err, providerconfig.ErrInvalidNodeGC, data)
+	}
+}
+
+func TestHAMaintenanceConfig(t *testing.T) {
+	cfg, err := providerconfig.ReadCloudConfig(strings.NewReader(`
+features:
+  ha_maintenance:
+    cordon: true
+`))
+	assert.Nil(t, err)
+	assert.True(t, cfg.Features.HAMaintenance.Cordon)
+	assert.Equal(t, providerconfig.TagTaint{Effect: "NoSchedule"}, cfg.Features.HAMaintenance.Taint)
+
+	_, err = providerconfig.ReadCloudConfig(strings.NewReader(`
+features:
+  ha_maintenance:
+    taint:
+      key: maintenance
+      effect: NoRun
+`))
+	assert.ErrorIs(t, err, providerconfig.ErrInvalidHAMaintenance)
+}
+
+func TestHostHealthConfig(t *testing.T) {
+	cfg, err := providerconfig.ReadCloudConfig(strings.NewReader(`
+features:
+  host_health:
+    taint_after: 2m
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, 2*time.Minute, cfg.Features.HostHealth.TaintAfter)
+
+	_, err = providerconfig.ReadCloudConfig(strings.NewReader(`
+features:
+  host_health:
+    taint_after: -1m
+`))
+	assert.ErrorIs(t, err, providerconfig.ErrInvalidHostHealth)
+}
+
 func TestReadCloudConfigFromFile(t *testing.T) {
 	cfg, err := providerconfig.ReadCloudConfigFromFile("testdata/cloud-config.yaml")
 	assert.NotNil(t, err)
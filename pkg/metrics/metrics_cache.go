@@ -0,0 +1,55 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+// CacheMetrics contains the metrics for the CCM caches.
+type CacheMetrics struct {
+	Requests *metrics.CounterVec
+}
+
+var cacheMetrics = registerCacheMetrics()
+
+// CacheHit counts the cache hit.
+func CacheHit(cache string) {
+	cacheMetrics.Requests.WithLabelValues(cache, "hit").Inc()
+}
+
+// CacheMiss counts the cache miss.
+func CacheMiss(cache string) {
+	cacheMetrics.Requests.WithLabelValues(cache, "miss").Inc()
+}
+
+func registerCacheMetrics() *CacheMetrics {
+	m := &CacheMetrics{
+		Requests: metrics.NewCounterVec(
+			&metrics.CounterOpts{
+				Name: "proxmox_cache_requests_total",
+				Help: "Total number of the cache lookups",
+			}, []string{"cache", "result"}),
+	}
+
+	legacyregistry.MustRegister(
+		m.Requests,
+	)
+
+	return m
+}
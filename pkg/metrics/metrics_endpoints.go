@@ -0,0 +1,82 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+// EndpointMetrics contains the metrics for the Proxmox API endpoints.
+type EndpointMetrics struct {
+	Healthy   *metrics.GaugeVec
+	Active    *metrics.GaugeVec
+	Failovers *metrics.CounterVec
+}
+
+var endpointMetrics = registerEndpointMetrics()
+
+// EndpointHealth sets the health of the Proxmox API endpoint in the region.
+func EndpointHealth(region, endpoint string, healthy bool) {
+	endpointMetrics.Healthy.WithLabelValues(region, endpoint).Set(boolToFloat(healthy))
+}
+
+// EndpointActive sets the Proxmox API endpoint which is used for the requests in the region.
+func EndpointActive(region, endpoint string, active bool) {
+	endpointMetrics.Active.WithLabelValues(region, endpoint).Set(boolToFloat(active))
+}
+
+// EndpointFailover counts the failover from the Proxmox API endpoint in the region.
+func EndpointFailover(region, endpoint string) {
+	endpointMetrics.Failovers.WithLabelValues(region, endpoint).Inc()
+}
+
+func boolToFloat(v bool) float64 {
+	if v {
+		return 1
+	}
+
+	return 0
+}
+
+func registerEndpointMetrics() *EndpointMetrics {
+	m := &EndpointMetrics{
+		Healthy: metrics.NewGaugeVec(
+			&metrics.GaugeOpts{
+				Name: "proxmox_api_endpoint_healthy",
+				Help: "Health of the Proxmox API endpoint, 1 if it is healthy",
+			}, []string{"region", "endpoint"}),
+		Active: metrics.NewGaugeVec(
+			&metrics.GaugeOpts{
+				Name: "proxmox_api_endpoint_active",
+				Help: "Proxmox API endpoint used for the requests, 1 if it is active",
+			}, []string{"region", "endpoint"}),
+		Failovers: metrics.NewCounterVec(
+			&metrics.CounterOpts{
+				Name: "proxmox_api_endpoint_failovers_total",
+				Help: "Total number of the failovers from the Proxmox API endpoint",
+			}, []string{"region", "endpoint"}),
+	}
+
+	legacyregistry.MustRegister(
+		m.Healthy,
+		m.Active,
+		m.Failovers,
+	)
+
+	return m
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+// NodeGCMetrics contains the metrics for the node garbage collector.
+type NodeGCMetrics struct {
+	Actions *metrics.CounterVec
+}
+
+var nodeGCMetrics = registerNodeGCMetrics()
+
+// NodeGCAction counts the action of the node garbage collector on the VM, with the result:
+// success, error, dry-run or protected.
+func NodeGCAction(action, result string) {
+	nodeGCMetrics.Actions.WithLabelValues(action, result).Inc()
+}
+
+func registerNodeGCMetrics() *NodeGCMetrics {
+	m := &NodeGCMetrics{
+		Actions: metrics.NewCounterVec(
+			&metrics.CounterOpts{
+				Name: "proxmox_node_gc_actions_total",
+				Help: "Total number of the node garbage collector actions on the VMs",
+			}, []string{"action", "result"}),
+	}
+
+	legacyregistry.MustRegister(
+		m.Actions,
+	)
+
+	return m
+}
@@ -27,15 +27,23 @@ import (
 const (
 	// ProviderName is the name of the Proxmox provider.
 	ProviderName = "proxmox"
+
+	// ContainerType is the providerID path element of the LXC containers.
+	ContainerType = "lxc"
 )
 
-var providerIDRegexp = regexp.MustCompile(`^` + ProviderName + `://([^/]*)/([^/]+)$`)
+var providerIDRegexp = regexp.MustCompile(`^` + ProviderName + `://([^/]*)/(?:(` + ContainerType + `)/)?([^/]+)$`)
 
 // GetProviderIDFromID returns the magic providerID for kubernetes node.
 func GetProviderIDFromID(region string, vmID int) string {
 	return fmt.Sprintf("%s://%s/%d", ProviderName, region, vmID)
 }
 
+// GetProviderIDFromContainerID returns the magic providerID for kubernetes node running in LXC container.
+func GetProviderIDFromContainerID(region string, vmID int) string {
+	return fmt.Sprintf("%s://%s/%s/%d", ProviderName, region, ContainerType, vmID)
+}
+
 // GetProviderIDFromUUID returns the magic providerID for kubernetes node.
 func GetProviderIDFromUUID(uuid string) string {
 	return fmt.Sprintf("%s://%s", ProviderName, uuid)
@@ -48,13 +56,13 @@ func GetVMID(providerID string) (int, error) {
 	}
 
 	matches := providerIDRegexp.FindStringSubmatch(providerID)
-	if len(matches) != 3 {
+	if len(matches) != 4 {
 		return 0, fmt.Errorf("providerID \"%s\" didn't match expected format \"%s://region/InstanceID\"", providerID, ProviderName)
 	}
 
-	vmID, err := strconv.Atoi(matches[2])
+	vmID, err := strconv.Atoi(matches[3])
 	if err != nil {
-		return 0, fmt.Errorf("InstanceID have to be a number, but got \"%s\"", matches[2])
+		return 0, fmt.Errorf("InstanceID have to be a number, but got \"%s\"", matches[3])
 	}
 
 	return vmID, nil
@@ -67,14 +75,21 @@ func ParseProviderID(providerID string) (int, string, error) {
 	}
 
 	matches := providerIDRegexp.FindStringSubmatch(providerID)
-	if len(matches) != 3 {
+	if len(matches) != 4 {
 		return 0, "", fmt.Errorf("providerID \"%s\" didn't match expected format \"%s://region/InstanceID\"", providerID, ProviderName)
 	}
 
-	vmID, err := strconv.Atoi(matches[2])
+	vmID, err := strconv.Atoi(matches[3])
 	if err != nil {
-		return 0, "", fmt.Errorf("InstanceID have to be a number, but got \"%s\"", matches[2])
+		return 0, "", fmt.Errorf("InstanceID have to be a number, but got \"%s\"", matches[3])
 	}
 
 	return vmID, matches[1], nil
 }
+
+// IsContainer returns true if the providerID belongs to the LXC container.
+func IsContainer(providerID string) bool {
+	matches := providerIDRegexp.FindStringSubmatch(providerID)
+
+	return len(matches) == 4 && matches[2] == ContainerType
+}
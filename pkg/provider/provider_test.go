@@ -145,6 +145,13 @@ func TestParseProviderID(t *testing.T) {
 			expectedvmID:   123,
 			expectedRegion: "",
 		},
+		{
+			msg:            "Valid container ID",
+			providerID:     "proxmox://region/lxc/123",
+			expectedError:  nil,
+			expectedvmID:   123,
+			expectedRegion: "region",
+		},
 		{
 			msg:           "Invalid providerID format",
 			providerID:    "proxmox://123",
@@ -183,3 +190,15 @@ func TestParseProviderID(t *testing.T) {
 		})
 	}
 }
+
+func TestContainerProviderID(t *testing.T) {
+	t.Parallel()
+
+	providerID := provider.GetProviderIDFromContainerID("region", 123)
+	assert.Equal(t, "proxmox://region/lxc/123", providerID)
+	assert.True(t, provider.IsContainer(providerID))
+
+	assert.False(t, provider.IsContainer("proxmox://region/123"))
+	assert.False(t, provider.IsContainer("proxmox://region/lxc"))
+	assert.False(t, provider.IsContainer("cloud://region/lxc/123"))
+}
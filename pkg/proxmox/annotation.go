@@ -19,4 +19,26 @@ package proxmox
 const (
 	// AnnotationProxmoxInstanceID is the annotation used to store the Proxmox node virtual machine ID.
 	AnnotationProxmoxInstanceID = Group + "/instance-id"
+
+	// AnnotationProxmoxTagLabels is the annotation used to store the node label keys managed by the tag rules.
+	AnnotationProxmoxTagLabels = Group + "/tag-labels"
+
+	// AnnotationProxmoxTagTaints is the annotation used to store the node taints (key:effect) managed by the tag rules.
+	AnnotationProxmoxTagTaints = Group + "/tag-taints"
+
+	// AnnotationDeleteOnNodeRemoval is the node annotation used to enable the node garbage collector: true, false, stop or delete.
+	AnnotationDeleteOnNodeRemoval = Group + "/delete-on-node-removal"
+
+	// FinalizerNodeGC is the node finalizer of the node garbage collector.
+	FinalizerNodeGC = Group + "/node-gc"
+
+	// AnnotationHAMaintenanceCordon is the node annotation used to store the Proxmox node in the HA maintenance mode,
+	// when the node was cordoned by the ha-maintenance controller.
+	AnnotationHAMaintenanceCordon = Group + "/ha-maintenance-cordon"
+
+	// AnnotationLoadBalancerPool is the service annotation used to request and store the load balancer IP pool name.
+	AnnotationLoadBalancerPool = Group + "/load-balancer-pool"
+
+	// AnnotationLoadBalancerIPs is the service annotation used to store the allocated load balancer IP addresses.
+	AnnotationLoadBalancerIPs = Group + "/load-balancer-ips"
 )
@@ -27,6 +27,7 @@ import (
 	pxpool "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/proxmoxpool"
 
 	clientkubernetes "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	cloudprovider "k8s.io/cloud-provider"
 	"k8s.io/klog/v2"
 )
@@ -47,15 +48,27 @@ const (
 type cloud struct {
 	client *client
 
-	instancesV2 cloudprovider.InstancesV2
+	instancesV2  cloudprovider.InstancesV2
+	zones        cloudprovider.Zones
+	loadBalancer cloudprovider.LoadBalancer
+	routes       cloudprovider.Routes
+
+	nodeIPAM      *nodeIPAM
+	nodeMigration *nodeMigration
+	nodeGC        *nodeGC
+	haMaintenance *haMaintenance
+	hostHealth    *hostHealth
+
+	clusterTag ccmConfig.ClusterTagOpts
 
 	ctx  context.Context //nolint:containedctx
 	stop func()
 }
 
 type client struct {
-	pxpool  *pxpool.ProxmoxPool
-	kclient clientkubernetes.Interface
+	pxpool   *pxpool.ProxmoxPool
+	kclient  clientkubernetes.Interface
+	recorder record.EventRecorder
 }
 
 func init() {
@@ -81,18 +94,59 @@ func newCloud(config *ccmConfig.ClustersConfig) (cloudprovider.Interface, error)
 		return nil, err
 	}
 
+	if config.Features.InventoryTTL > 0 {
+		px.SetInventoryTTL(config.Features.InventoryTTL)
+	}
+
+	nameMatcher, err := pxpool.NewNameMatcher(config.Features.NameMatching)
+	if err != nil {
+		cancel()
+
+		return nil, err
+	}
+
+	px.SetNameMatcher(nameMatcher)
+
+	if config.Features.ClusterTag.Enabled && config.Features.ClusterTag.Name != "" {
+		px.SetClusterTag(config.Features.ClusterTag.Name)
+	}
+
 	client := &client{
 		pxpool: px,
 	}
 
 	instancesInterface := newInstances(client, config.Features)
 
-	return &cloud{
-		client:      client,
-		instancesV2: instancesInterface,
-		ctx:         ctx,
-		stop:        cancel,
-	}, nil
+	cloud := &cloud{
+		client:        client,
+		instancesV2:   instancesInterface,
+		zones:         newZones(instancesInterface),
+		nodeIPAM:      newNodeIPAM(client, config.Features),
+		nodeMigration: newNodeMigration(instancesInterface),
+		nodeGC:        newNodeGC(client, config.Features),
+		haMaintenance: newHAMaintenance(client, config.Features),
+		hostHealth:    newHostHealth(client, config.Features),
+		clusterTag:    config.Features.ClusterTag,
+		ctx:           ctx,
+		stop:          cancel,
+	}
+
+	lb, err := newLoadBalancer(client, config.Features)
+	if err != nil {
+		cancel()
+
+		return nil, err
+	}
+
+	if lb != nil {
+		cloud.loadBalancer = lb
+	}
+
+	if r := newRoutes(client, config.Features); r != nil {
+		cloud.routes = r
+	}
+
+	return cloud, nil
 }
 
 // Initialize provides the cloud with a kubernetes client builder and may spawn goroutines
@@ -108,11 +162,16 @@ func (c *cloud) Initialize(clientBuilder cloudprovider.ControllerClientBuilder,
 
 	klog.InfoS("clientset initialized")
 
+	c.client.recorder = newEventRecorder(c.ctx, c.client.kclient, serviceAccountName)
+
 	err := c.client.pxpool.CheckClusters(c.ctx)
 	if err != nil {
 		klog.ErrorS(err, "failed to check proxmox cluster")
 	}
 
+	go c.client.pxpool.RunInventoryRefresh(c.ctx)
+	go c.client.pxpool.RunEndpointProbes(c.ctx)
+
 	// Broadcast the upstream stop signal to all provider-level goroutines
 	// watching the provider's context for cancellation.
 	go func(provider *cloud) {
@@ -124,10 +183,20 @@ func (c *cloud) Initialize(clientBuilder cloudprovider.ControllerClientBuilder,
 	klog.InfoS("proxmox initialized")
 }
 
+// SetClusterName sets the Kubernetes cluster name from the --cluster-name flag.
+// The cluster name is the default of the Proxmox cluster tag, k8s-<cluster-name>.
+func (c *cloud) SetClusterName(name string) {
+	if c.clusterTag.Enabled && c.clusterTag.Name == "" && name != "" {
+		c.client.pxpool.SetClusterTag("k8s-" + name)
+
+		klog.InfoS("proxmox VM discovery is scoped by the cluster tag", "tag", "k8s-"+name)
+	}
+}
+
 // LoadBalancer returns a balancer interface.
 // Also returns true if the interface is supported, false otherwise.
 func (c *cloud) LoadBalancer() (cloudprovider.LoadBalancer, bool) {
-	return nil, false
+	return c.loadBalancer, c.loadBalancer != nil
 }
 
 // Instances returns an instances interface.
@@ -147,7 +216,7 @@ func (c *cloud) InstancesV2() (cloudprovider.InstancesV2, bool) {
 // Zones returns a zones interface.
 // Also returns true if the interface is supported, false otherwise.
 func (c *cloud) Zones() (cloudprovider.Zones, bool) {
-	return nil, false
+	return c.zones, c.zones != nil
 }
 
 // Clusters is not implemented.
@@ -155,9 +224,10 @@ func (c *cloud) Clusters() (cloudprovider.Clusters, bool) {
 	return nil, false
 }
 
-// Routes is not implemented.
+// Routes returns a routes interface.
+// Also returns true if the interface is supported, false otherwise.
 func (c *cloud) Routes() (cloudprovider.Routes, bool) {
-	return nil, false
+	return c.routes, c.routes != nil
 }
 
 // ProviderName returns the cloud provider ID.
@@ -63,8 +63,8 @@ clusters:
 	assert.Equal(t, res, true)
 
 	zone, res := cloud.Zones()
-	assert.Nil(t, zone)
-	assert.Equal(t, res, false)
+	assert.NotNil(t, zone)
+	assert.Equal(t, res, true)
 
 	cl, res := cloud.Clusters()
 	assert.Nil(t, cl)
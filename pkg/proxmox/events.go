@@ -0,0 +1,104 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmox
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	clientkubernetes "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	// EventReasonNodeMigrated is the event reason of the VM migration to another Proxmox node.
+	EventReasonNodeMigrated = "ProxmoxMigrated"
+	// EventReasonSystemUUIDMismatch is the event reason of the node SystemUUID which does not match the VM UUID.
+	EventReasonSystemUUIDMismatch = "ProxmoxSystemUUIDMismatch"
+	// EventReasonNameMismatch is the event reason of the node name which does not match the VM name or the container hostname.
+	EventReasonNameMismatch = "ProxmoxNameMismatch"
+	// EventReasonNodeUnreachable is the event reason of the unreachable Proxmox node or cluster.
+	EventReasonNodeUnreachable = "ProxmoxUnreachable"
+	// EventReasonUntagged is the event reason of the VM without the Proxmox tag of the Kubernetes cluster.
+	EventReasonUntagged = "ProxmoxUntagged"
+	// EventReasonHAGroupZone is the event reason of the zone which cannot be set from the HA group.
+	EventReasonHAGroupZone = "ProxmoxHAGroupZoneFailed"
+	// EventReasonPoolZone is the event reason of the zone which cannot be set from the resource pool.
+	EventReasonPoolZone = "ProxmoxPoolZoneFailed"
+	// EventReasonZone is the event reason of the zone which cannot be derived with the zone strategy.
+	EventReasonZone = "ProxmoxZoneFailed"
+	// EventReasonTagRuleInvalid is the event reason of the tag rule label or taint, which is not valid after the tag expansion.
+	EventReasonTagRuleInvalid = "ProxmoxTagRuleInvalid"
+	// EventReasonVMStopped is the event reason of the VM stopped by the node garbage collector.
+	EventReasonVMStopped = "ProxmoxVMStopped"
+	// EventReasonVMDeleted is the event reason of the VM deleted by the node garbage collector.
+	EventReasonVMDeleted = "ProxmoxVMDeleted"
+	// EventReasonVMGCDryRun is the event reason of the node garbage collector action in the dry-run mode.
+	EventReasonVMGCDryRun = "ProxmoxVMGCDryRun"
+	// EventReasonVMProtected is the event reason of the VM with the Proxmox protection flag, which is not removed.
+	EventReasonVMProtected = "ProxmoxVMProtected"
+	// EventReasonVMGCSkipped is the event reason of the VM which does not belong to the node anymore.
+	EventReasonVMGCSkipped = "ProxmoxVMGCSkipped"
+	// EventReasonVMGCFailed is the event reason of the failed node garbage collector action.
+	EventReasonVMGCFailed = "ProxmoxVMGCFailed"
+	// EventReasonHostMaintenance is the event reason of the Proxmox node which entered the HA maintenance mode.
+	EventReasonHostMaintenance = "ProxmoxHostMaintenance"
+	// EventReasonHostMaintenanceEnded is the event reason of the Proxmox node which left the HA maintenance mode.
+	EventReasonHostMaintenanceEnded = "ProxmoxHostMaintenanceEnded"
+	// EventReasonHostNotReady is the event reason of the Proxmox node which is offline, fenced or unknown.
+	EventReasonHostNotReady = "ProxmoxHostNotReady"
+
+	// eventBurstSize and eventQPS limit the identical events of the same object,
+	// the CCM calls InstanceMetadata every sync period of the node controllers.
+	eventBurstSize = 3
+	eventQPS       = 1. / 300.
+)
+
+func newEventRecorder(ctx context.Context, kclient clientkubernetes.Interface, component string) record.EventRecorder {
+	broadcaster := record.NewBroadcaster(
+		record.WithContext(ctx),
+		record.WithCorrelatorOptions(record.CorrelatorOptions{
+			BurstSize: eventBurstSize,
+			QPS:       eventQPS,
+		}),
+	)
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kclient.CoreV1().Events("")})
+
+	return broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: component})
+}
+
+// nodeWarningf records a warning event on the node with the region and the VM ID of the instance, if they are known.
+func (c *client) nodeWarningf(node *v1.Node, reason string, region string, vmID int, messageFmt string, args ...any) {
+	c.nodeEventf(node, v1.EventTypeWarning, reason, region, vmID, messageFmt, args...)
+}
+
+// nodeEventf records an event on the node with the region and the VM ID of the instance, if they are known.
+func (c *client) nodeEventf(node *v1.Node, eventType string, reason string, region string, vmID int, messageFmt string, args ...any) {
+	if c.recorder == nil {
+		return
+	}
+
+	message := fmt.Sprintf(messageFmt, args...)
+	if region != "" && vmID != 0 {
+		message = fmt.Sprintf("%s (region=%s, vmID=%d)", message, region, vmID)
+	}
+
+	c.recorder.Event(node, eventType, reason, message)
+}
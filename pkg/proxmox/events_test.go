@@ -0,0 +1,166 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmox
+
+import (
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+
+	providerconfig "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/config"
+	"github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/proxmoxpool"
+	testcluster "github.com/sergelogvinov/proxmox-cloud-controller-manager/test/cluster"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	cloudprovider "k8s.io/cloud-provider"
+)
+
+func TestNodeWarningf(t *testing.T) {
+	t.Parallel()
+
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+
+	(&client{}).nodeWarningf(node, EventReasonNameMismatch, "cluster-1", 100, "no recorder")
+
+	recorder := record.NewFakeRecorder(10)
+	c := &client{recorder: recorder}
+
+	c.nodeWarningf(node, EventReasonNameMismatch, "cluster-1", 100, "Node name does not match VM name %s", "vm-1")
+	c.nodeWarningf(node, EventReasonNodeUnreachable, "", 0, "Cannot find the VM")
+
+	assert.Equal(t, "Warning ProxmoxNameMismatch Node name does not match VM name vm-1 (region=cluster-1, vmID=100)", <-recorder.Events)
+	assert.Equal(t, "Warning ProxmoxUnreachable Cannot find the VM", <-recorder.Events)
+}
+
+func TestInstanceInfoEvents(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	cfg, err := providerconfig.ReadCloudConfigFromFile("../../test/config/cluster-config-1.yaml")
+	assert.Nil(t, err)
+
+	px, err := proxmoxpool.NewProxmoxPool(cfg.Clusters)
+	assert.Nil(t, err)
+
+	recorder := record.NewFakeRecorder(10)
+	i := newInstances(&client{pxpool: px, kclient: fake.NewClientset(), recorder: recorder}, providerconfig.ClustersFeatures{})
+
+	tests := []struct {
+		msg      string
+		node     *v1.Node
+		expected string
+	}{
+		{
+			msg: "SystemUUIDMismatch",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster-1-node-1"},
+				Spec:       v1.NodeSpec{ProviderID: "proxmox://cluster-1/100"},
+				Status: v1.NodeStatus{
+					NodeInfo: v1.NodeSystemInfo{SystemUUID: "11833f4c-341f-4bd3-aad7-f7abed999999"},
+				},
+			},
+			expected: "Warning ProxmoxSystemUUIDMismatch Node SystemUUID 11833f4c-341f-4bd3-aad7-f7abed999999 does not match VM UUID 11833f4c-341f-4bd3-aad7-f7abed000000 (region=cluster-1, vmID=100)",
+		},
+		{
+			msg: "NameMismatch",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster-1-node-3"},
+				Spec:       v1.NodeSpec{ProviderID: "proxmox://cluster-1/100"},
+				Status: v1.NodeStatus{
+					NodeInfo: v1.NodeSystemInfo{SystemUUID: "11833f4c-341f-4bd3-aad7-f7abed000000"},
+				},
+			},
+			expected: "Warning ProxmoxNameMismatch Node name does not match VM name cluster-1-node-1 (region=cluster-1, vmID=100)",
+		},
+		{
+			msg: "ContainerNameMismatch",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster-1-node-3"},
+				Spec:       v1.NodeSpec{ProviderID: "proxmox://cluster-1/lxc/105"},
+			},
+			expected: "Warning ProxmoxNameMismatch Node name does not match container hostname cluster-1-node-5 (region=cluster-1, vmID=105)",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.msg, func(t *testing.T) {
+			_, err := i.getInstanceInfo(t.Context(), testCase.node)
+			assert.ErrorIs(t, err, cloudprovider.InstanceNotFound)
+
+			assert.Len(t, recorder.Events, 1)
+			assert.Equal(t, testCase.expected, <-recorder.Events)
+		})
+	}
+}
+
+func TestInstanceClusterTag(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	cfg, err := providerconfig.ReadCloudConfigFromFile("../../test/config/cluster-config-1.yaml")
+	assert.Nil(t, err)
+
+	px, err := proxmoxpool.NewProxmoxPool(cfg.Clusters)
+	assert.Nil(t, err)
+
+	px.SetClusterTag("k8s-kubernetes")
+
+	recorder := record.NewFakeRecorder(10)
+	i := newInstances(&client{pxpool: px, kclient: fake.NewClientset(), recorder: recorder}, providerconfig.ClustersFeatures{})
+
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-1-node-2"},
+		Spec:       v1.NodeSpec{ProviderID: "proxmox://cluster-1/101"},
+		Status: v1.NodeStatus{
+			NodeInfo: v1.NodeSystemInfo{SystemUUID: "11833f4c-341f-4bd3-aad7-f7abed000001"},
+		},
+	}
+
+	// The VM of another Kubernetes cluster does not exist for this cluster
+	exists, err := i.InstanceExists(t.Context(), node)
+	assert.Nil(t, err)
+	assert.False(t, exists)
+	assert.Equal(t, "Warning ProxmoxUntagged VM does not have the cluster tag (region=cluster-1, vmID=101)", <-recorder.Events)
+
+	shutdown, err := i.InstanceShutdown(t.Context(), node)
+	assert.Nil(t, err)
+	assert.False(t, shutdown)
+	assert.Equal(t, "Warning ProxmoxUntagged VM does not have the cluster tag (region=cluster-1, vmID=101)", <-recorder.Events)
+
+	_, err = i.InstanceMetadata(t.Context(), node)
+	assert.ErrorIs(t, err, proxmoxpool.ErrInstanceUntagged)
+
+	node = &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-1-node-1"},
+		Spec:       v1.NodeSpec{ProviderID: "proxmox://cluster-1/100"},
+		Status: v1.NodeStatus{
+			NodeInfo: v1.NodeSystemInfo{SystemUUID: "11833f4c-341f-4bd3-aad7-f7abed000000"},
+		},
+	}
+
+	exists, err = i.InstanceExists(t.Context(), node)
+	assert.Nil(t, err)
+	assert.True(t, exists)
+}
@@ -0,0 +1,263 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	proxmox "github.com/luthermonson/go-proxmox"
+
+	providerconfig "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/config"
+	metrics "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/metrics"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/cloud-provider/app"
+	cloudcontrollerconfig "k8s.io/cloud-provider/app/config"
+	cloudnodeutil "k8s.io/cloud-provider/node/helpers"
+	genericcontrollermanager "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// HAMaintenanceControllerName is the name of the HA maintenance controller.
+	HAMaintenanceControllerName = "ha-maintenance"
+	// HAMaintenanceControllerClientName is the client name of the HA maintenance controller.
+	HAMaintenanceControllerClientName = "ha-maintenance-controller"
+
+	haMaintenanceSyncPeriod = 30 * time.Second
+)
+
+// haMaintenance taints the nodes whose VMs run on the Proxmox nodes in the HA maintenance mode,
+// and optionally cordons them. The taint is removed when the maintenance ends or the VM was migrated away.
+type haMaintenance struct {
+	c *client
+
+	taint  v1.Taint
+	cordon bool
+
+	nodeLister  corelisters.NodeLister
+	nodesSynced cache.InformerSynced
+}
+
+func newHAMaintenance(client *client, features providerconfig.ClustersFeatures) *haMaintenance {
+	opts := features.HAMaintenance
+
+	taint := v1.Taint{
+		Key:    opts.Taint.Key,
+		Value:  opts.Taint.Value,
+		Effect: v1.TaintEffect(opts.Taint.Effect),
+	}
+
+	if taint.Key == "" {
+		taint.Key = TaintHAMaintenance
+	}
+
+	if taint.Effect == "" {
+		taint.Effect = v1.TaintEffectNoSchedule
+	}
+
+	return &haMaintenance{
+		c:      client,
+		taint:  taint,
+		cordon: opts.Cordon,
+	}
+}
+
+// StartHAMaintenanceControllerWrapper is used to take cloud config as input and start the HA maintenance controller.
+func StartHAMaintenanceControllerWrapper(_ app.ControllerInitContext, completedConfig *cloudcontrollerconfig.CompletedConfig, ccm cloudprovider.Interface) app.InitFunc {
+	return func(ctx context.Context, _ genericcontrollermanager.ControllerContext) (controller.Interface, bool, error) {
+		c, ok := ccm.(*cloud)
+		if !ok || c.haMaintenance == nil {
+			return nil, false, nil
+		}
+
+		c.haMaintenance.setInformer(completedConfig.SharedInformers.Core().V1().Nodes())
+
+		go c.haMaintenance.Run(ctx)
+
+		return nil, true, nil
+	}
+}
+
+func (m *haMaintenance) setInformer(informer coreinformers.NodeInformer) {
+	m.nodeLister = informer.Lister()
+	m.nodesSynced = informer.Informer().HasSynced
+}
+
+// Run starts the HA maintenance controller, it blocks until the context is done.
+func (m *haMaintenance) Run(ctx context.Context) {
+	defer utilruntime.HandleCrash()
+
+	klog.InfoS("starting ha-maintenance controller", "taint", m.taint.ToString(), "cordon", m.cordon)
+	defer klog.InfoS("shutting down ha-maintenance controller")
+
+	if !cache.WaitForNamedCacheSync(HAMaintenanceControllerName, ctx.Done(), m.nodesSynced) {
+		return
+	}
+
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := m.sync(ctx); err != nil {
+			klog.ErrorS(err, "ha-maintenance failed to sync nodes")
+		}
+	}, haMaintenanceSyncPeriod)
+}
+
+// sync reads the HA manager status of each region, and updates the taint of the nodes.
+func (m *haMaintenance) sync(ctx context.Context) error {
+	nodes, err := m.nodeLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	byRegion := map[string][]*v1.Node{}
+
+	for _, node := range nodes {
+		if region := getNodeRegion(node); region != "" && !hasUninitializedTaint(node) {
+			byRegion[region] = append(byRegion[region], node)
+		}
+	}
+
+	for region, nodes := range byRegion {
+		if err := m.syncRegion(ctx, region, nodes); err != nil {
+			klog.ErrorS(err, "ha-maintenance failed to sync region", "region", region)
+		}
+	}
+
+	return nil
+}
+
+func (m *haMaintenance) syncRegion(ctx context.Context, region string, nodes []*v1.Node) error {
+	mc := metrics.NewMetricContext("getHAManagerStatus")
+
+	status, err := m.c.pxpool.GetHAManagerStatus(ctx, region)
+	if mc.ObserveRequest(err) != nil {
+		return err
+	}
+
+	maintenance := status.MaintenanceNodes()
+
+	// The VM placement must be recent, the HA manager migrates the VMs away from the Proxmox node in maintenance
+	vms, err := m.c.pxpool.GetRecentInventoryVMs(ctx, region, placementMaxAge)
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[int]*proxmox.ClusterResource, len(vms))
+	for _, vm := range vms {
+		byID[int(vm.Resource.VMID)] = vm.Resource
+	}
+
+	for _, node := range nodes {
+		vmID, ok := getNodeVMID(node)
+		if !ok {
+			continue
+		}
+
+		rs := byID[vmID]
+		if rs == nil || rs.Status == "unknown" {
+			continue
+		}
+
+		if err := m.syncNode(ctx, node, region, rs, slices.Contains(maintenance, rs.Node)); err != nil {
+			klog.ErrorS(err, "ha-maintenance failed to sync node", "node", klog.KObj(node), "region", region, "vmID", vmID)
+		}
+	}
+
+	return nil
+}
+
+func (m *haMaintenance) syncNode(ctx context.Context, node *v1.Node, region string, rs *proxmox.ClusterResource, maintenance bool) error {
+	vmID := int(rs.VMID) //nolint: gosec
+	tainted := slices.ContainsFunc(node.Spec.Taints, func(t v1.Taint) bool { return t.MatchTaint(&m.taint) })
+	_, cordoned := node.Annotations[AnnotationHAMaintenanceCordon]
+
+	if maintenance {
+		if !tainted {
+			if err := cloudnodeutil.AddOrUpdateTaintOnNode(m.c.kclient, node.Name, &m.taint); err != nil {
+				return fmt.Errorf("failed to add taint to node %s: %w", node.Name, err)
+			}
+
+			klog.InfoS("ha-maintenance tainted node", "node", klog.KObj(node), "region", region, "vmID", vmID, "host", rs.Node)
+			m.c.nodeEventf(node, v1.EventTypeWarning, EventReasonHostMaintenance, region, vmID, "Proxmox node %s is in the HA maintenance mode", rs.Node)
+		}
+
+		// The node was cordoned by the user, it stays cordoned after the maintenance
+		if m.cordon && !cordoned && !node.Spec.Unschedulable {
+			return m.patchCordon(ctx, node, rs.Node)
+		}
+
+		return nil
+	}
+
+	if tainted {
+		if err := cloudnodeutil.RemoveTaintOffNode(m.c.kclient, node.Name, node, &m.taint); err != nil {
+			return fmt.Errorf("failed to remove taint of node %s: %w", node.Name, err)
+		}
+
+		klog.InfoS("ha-maintenance removed node taint", "node", klog.KObj(node), "region", region, "vmID", vmID, "host", rs.Node)
+		m.c.nodeEventf(node, v1.EventTypeNormal, EventReasonHostMaintenanceEnded, region, vmID, "Proxmox node %s is not in the HA maintenance mode", rs.Node)
+	}
+
+	if cordoned {
+		return m.patchCordon(ctx, node, "")
+	}
+
+	return nil
+}
+
+// patchCordon cordons the node and stores the Proxmox node in the annotation,
+// or uncordons the node and removes the annotation if the host is empty.
+func (m *haMaintenance) patchCordon(ctx context.Context, node *v1.Node, host string) error {
+	var annotation any
+	if host != "" {
+		annotation = host
+	}
+
+	data, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"annotations": map[string]any{AnnotationHAMaintenanceCordon: annotation},
+		},
+		"spec": map[string]any{
+			"unschedulable": host != "",
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal the patch: %w", err)
+	}
+
+	if _, err := m.c.kclient.CoreV1().Nodes().Patch(ctx, node.Name, types.MergePatchType, data, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("failed to patch node %s: %w", node.Name, err)
+	}
+
+	klog.InfoS("ha-maintenance updated node", "node", klog.KObj(node), "unschedulable", host != "")
+
+	return nil
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmox
+
+import (
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+
+	providerconfig "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/config"
+	"github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/proxmoxpool"
+	testcluster "github.com/sergelogvinov/proxmox-cloud-controller-manager/test/cluster"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestHAMaintenanceSync(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	cfg, err := providerconfig.ReadCloudConfigFromFile("../../test/config/cluster-config-1.yaml")
+	assert.Nil(t, err)
+
+	px, err := proxmoxpool.NewProxmoxPool(cfg.Clusters)
+	assert.Nil(t, err)
+
+	taint := v1.Taint{Key: TaintHAMaintenance, Effect: v1.TaintEffectNoSchedule}
+	nodes := []*v1.Node{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "cluster-1-node-1",
+				Labels:      map[string]string{LabelTopologyRegion: "cluster-1"},
+				Annotations: map[string]string{AnnotationHAMaintenanceCordon: "pve-1"},
+			},
+			Spec: v1.NodeSpec{
+				ProviderID:    "proxmox://cluster-1/100",
+				Unschedulable: true,
+				Taints:        []v1.Taint{taint},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "cluster-1-node-2",
+				Labels: map[string]string{LabelTopologyRegion: "cluster-1"},
+			},
+			Spec: v1.NodeSpec{ProviderID: "proxmox://cluster-1/101"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "cluster-1-node-5",
+				Labels: map[string]string{LabelTopologyRegion: "cluster-1"},
+			},
+			Spec: v1.NodeSpec{ProviderID: "proxmox://cluster-1/lxc/105", Unschedulable: true},
+		},
+	}
+
+	kclient := fake.NewClientset()
+	informer := informers.NewSharedInformerFactory(kclient, 0).Core().V1().Nodes()
+
+	for _, node := range nodes {
+		_, err := kclient.CoreV1().Nodes().Create(t.Context(), node, metav1.CreateOptions{})
+		assert.Nil(t, err)
+		assert.Nil(t, informer.Informer().GetIndexer().Add(node))
+	}
+
+	recorder := record.NewFakeRecorder(10)
+
+	m := newHAMaintenance(&client{pxpool: px, kclient: kclient, recorder: recorder}, providerconfig.ClustersFeatures{
+		HAMaintenance: providerconfig.HAMaintenanceOpts{Cordon: true},
+	})
+	m.setInformer(informer)
+
+	assert.Nil(t, m.sync(t.Context()))
+
+	node, err := kclient.CoreV1().Nodes().Get(t.Context(), "cluster-1-node-1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Empty(t, node.Spec.Taints)
+	assert.False(t, node.Spec.Unschedulable)
+	assert.NotContains(t, node.Annotations, AnnotationHAMaintenanceCordon)
+
+	node, err = kclient.CoreV1().Nodes().Get(t.Context(), "cluster-1-node-2", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, node.Spec.Taints, 1)
+	assert.True(t, node.Spec.Taints[0].MatchTaint(&taint))
+	assert.True(t, node.Spec.Unschedulable)
+	assert.Equal(t, "pve-2", node.Annotations[AnnotationHAMaintenanceCordon])
+
+	// The node was cordoned by the user
+	node, err = kclient.CoreV1().Nodes().Get(t.Context(), "cluster-1-node-5", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, node.Spec.Taints, 1)
+	assert.True(t, node.Spec.Unschedulable)
+	assert.NotContains(t, node.Annotations, AnnotationHAMaintenanceCordon)
+
+	events := []string{}
+	for len(recorder.Events) > 0 {
+		events = append(events, <-recorder.Events)
+	}
+
+	assert.ElementsMatch(t, []string{
+		"Normal ProxmoxHostMaintenanceEnded Proxmox node pve-1 is not in the HA maintenance mode (region=cluster-1, vmID=100)",
+		"Warning ProxmoxHostMaintenance Proxmox node pve-2 is in the HA maintenance mode (region=cluster-1, vmID=101)",
+		"Warning ProxmoxHostMaintenance Proxmox node pve-2 is in the HA maintenance mode (region=cluster-1, vmID=105)",
+	}, events)
+}
+
+func TestNewHAMaintenance(t *testing.T) {
+	t.Parallel()
+
+	m := newHAMaintenance(&client{}, providerconfig.ClustersFeatures{})
+	assert.Equal(t, v1.Taint{Key: TaintHAMaintenance, Effect: v1.TaintEffectNoSchedule}, m.taint)
+	assert.False(t, m.cordon)
+
+	m = newHAMaintenance(&client{}, providerconfig.ClustersFeatures{
+		HAMaintenance: providerconfig.HAMaintenanceOpts{
+			Taint: providerconfig.TagTaint{Key: "maintenance", Value: "proxmox", Effect: "NoExecute"},
+		},
+	})
+	assert.Equal(t, v1.Taint{Key: "maintenance", Value: "proxmox", Effect: v1.TaintEffectNoExecute}, m.taint)
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmox
+
+import (
+	"context"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	proxmox "github.com/luthermonson/go-proxmox"
+
+	"github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/proxmoxpool"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+var labelValueInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// hostPCI is the PCI passthrough device of the VM, hostpciN config option.
+type hostPCI struct {
+	// Hosts are the PCI addresses of the device functions, 0000:01:00.0 or 0000:01:00 for all functions.
+	Hosts []string
+	// Mapping is the cluster-wide PCI resource mapping name.
+	Mapping string
+	// VendorID and DeviceID override the IDs of the device.
+	VendorID string
+	DeviceID string
+}
+
+// parseHostPCI parses the hostpciN config option, for example 0000:01:00.0,pcie=1 or mapping=gpu,pcie=1.
+func parseHostPCI(value string) hostPCI {
+	dev := hostPCI{}
+
+	for idx, opt := range strings.Split(value, ",") {
+		k, v, ok := strings.Cut(opt, "=")
+		if !ok {
+			if idx == 0 {
+				k, v = "host", opt
+			} else {
+				continue
+			}
+		}
+
+		switch k {
+		case "host":
+			for _, h := range strings.Split(v, ";") {
+				if strings.Count(h, ":") == 1 {
+					h = "0000:" + h
+				}
+
+				dev.Hosts = append(dev.Hosts, strings.ToLower(h))
+			}
+		case "mapping":
+			dev.Mapping = v
+		case "vendor-id":
+			dev.VendorID = v
+		case "device-id":
+			dev.DeviceID = v
+		}
+	}
+
+	return dev
+}
+
+// pciID formats the vendor and the device ID as the label key suffix, for example 10de-1db6.
+func pciID(vendor, device string) string {
+	return strings.TrimPrefix(strings.ToLower(vendor), "0x") + "-" + strings.TrimPrefix(strings.ToLower(device), "0x")
+}
+
+// labelValue converts the string to the valid label value.
+func labelValue(s string) string {
+	s = labelValueInvalidChars.ReplaceAllString(s, "-")
+	if len(s) > 63 {
+		s = s[:63]
+	}
+
+	return strings.Trim(s, "-_.")
+}
+
+// machineType returns the base machine type, for example q35 for pc-q35-9.0+pve0.
+func machineType(machine string) string {
+	machine, _, _ = strings.Cut(machine, ",")
+
+	switch {
+	case machine == "" || strings.Contains(machine, "i440fx") || machine == "pc":
+		return "i440fx"
+	case strings.Contains(machine, "q35"):
+		return "q35"
+	default:
+		return labelValue(machine)
+	}
+}
+
+// hardwareLabels returns the node labels of the VM hardware configuration.
+func (i *instances) hardwareLabels(ctx context.Context, region string, vm *proxmox.VirtualMachine) map[string]string {
+	cfg := vm.VirtualMachineConfig
+	if cfg == nil {
+		return nil
+	}
+
+	cpuType, _, _ := strings.Cut(cfg.CPU, ",")
+	if cpuType == "" {
+		cpuType = "kvm64"
+	}
+
+	bios := cfg.Bios
+	if bios == "" {
+		bios = "seabios"
+	}
+
+	labels := map[string]string{
+		LabelHardwareCPUType:        labelValue(cpuType),
+		LabelHardwareMachine:        machineType(cfg.Machine),
+		LabelHardwareBIOS:           labelValue(bios),
+		LabelHardwareNUMA:           strconv.FormatBool(cfg.Numa == 1),
+		LabelHardwarePCIPassthrough: "false",
+	}
+
+	if cfg.Hugepages != "" {
+		labels[LabelHardwareHugepages] = labelValue(cfg.Hugepages)
+	}
+
+	hostPCIs := cfg.MergeHostPCIs()
+	if len(hostPCIs) == 0 {
+		return labels
+	}
+
+	labels[LabelHardwarePCIPassthrough] = "true"
+
+	var nodeDevices []*proxmoxpool.PCIDevice
+
+	devices := map[string]int{}
+
+	for _, key := range slices.Sorted(maps.Keys(hostPCIs)) {
+		dev := parseHostPCI(hostPCIs[key])
+
+		switch {
+		case dev.VendorID != "" && dev.DeviceID != "":
+			devices[pciID(dev.VendorID, dev.DeviceID)]++
+		case dev.Mapping != "":
+			mapping, err := i.c.pxpool.GetPCIMapping(ctx, region, dev.Mapping)
+			if err != nil {
+				klog.ErrorS(err, "instances.hardwareLabels() failed to get PCI mapping", "region", region, "vmID", vm.VMID, "mapping", dev.Mapping)
+
+				continue
+			}
+
+			if id, ok := mapping.NodeDeviceID(vm.Node); ok {
+				vendor, device, _ := strings.Cut(id, ":")
+				devices[pciID(vendor, device)]++
+			}
+		default:
+			if nodeDevices == nil {
+				var err error
+
+				nodeDevices, err = i.c.pxpool.GetNodePCIDevices(ctx, region, vm.Node)
+				if err != nil {
+					klog.ErrorS(err, "instances.hardwareLabels() failed to get PCI devices", "region", region, "node", vm.Node)
+
+					return labels
+				}
+			}
+
+			for _, h := range dev.Hosts {
+				for _, d := range nodeDevices {
+					if d.ID == h || strings.HasPrefix(d.ID, h+".") {
+						devices[pciID(d.Vendor, d.Device)]++
+
+						break
+					}
+				}
+			}
+		}
+	}
+
+	for id, count := range devices {
+		labels[LabelHardwarePCIPrefix+labelValue(id)] = strconv.Itoa(count)
+	}
+
+	return labels
+}
+
+// staleHardwareLabels returns the hardware labels of the node which are not in the labels anymore.
+func staleHardwareLabels(node *v1.Node, labels map[string]string) []string {
+	var keys []string
+
+	for k := range node.Labels {
+		if !strings.HasPrefix(k, LabelHardwarePrefix) && !strings.HasPrefix(k, LabelHardwarePCIPrefix) {
+			continue
+		}
+
+		if _, ok := labels[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+
+	return keys
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmox
+
+import (
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+
+	providerconfig "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/config"
+	"github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/proxmoxpool"
+	testcluster "github.com/sergelogvinov/proxmox-cloud-controller-manager/test/cluster"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseHostPCI(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value    string
+		expected hostPCI
+	}{
+		{value: "0000:01:00.0,pcie=1", expected: hostPCI{Hosts: []string{"0000:01:00.0"}}},
+		{value: "01:00,x-vga=1", expected: hostPCI{Hosts: []string{"0000:01:00"}}},
+		{value: "host=0000:01:00.0;0000:01:00.1", expected: hostPCI{Hosts: []string{"0000:01:00.0", "0000:01:00.1"}}},
+		{value: "mapping=gpu,pcie=1", expected: hostPCI{Mapping: "gpu"}},
+		{value: "0000:01:00.0,vendor-id=0x10DE,device-id=0x1db6", expected: hostPCI{Hosts: []string{"0000:01:00.0"}, VendorID: "0x10DE", DeviceID: "0x1db6"}},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.value, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, testCase.expected, parseHostPCI(testCase.value))
+		})
+	}
+}
+
+func TestMachineType(t *testing.T) {
+	t.Parallel()
+
+	for machine, expected := range map[string]string{
+		"":                   "i440fx",
+		"pc-i440fx-9.0+pve0": "i440fx",
+		"q35":                "q35",
+		"pc-q35-9.0+pve0":    "q35",
+		"q35,viommu=intel":   "q35",
+		"virt":               "virt",
+	} {
+		assert.Equal(t, expected, machineType(machine), machine)
+	}
+}
+
+func TestHardwareLabels(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	cfg, err := providerconfig.ReadCloudConfigFromFile("../../test/config/cluster-config-1.yaml")
+	assert.Nil(t, err)
+
+	px, err := proxmoxpool.NewProxmoxPool(cfg.Clusters)
+	assert.Nil(t, err)
+
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster-1-node-1",
+			Labels: map[string]string{
+				LabelHardwarePCIPrefix + "8086-7a06": "1",
+			},
+		},
+		Spec: v1.NodeSpec{ProviderID: "proxmox://cluster-1/100"},
+		Status: v1.NodeStatus{
+			NodeInfo: v1.NodeSystemInfo{SystemUUID: "11833f4c-341f-4bd3-aad7-f7abed000000"},
+		},
+	}
+
+	kclient := fake.NewClientset(node)
+	i := newInstances(&client{pxpool: px, kclient: kclient}, providerconfig.ClustersFeatures{HardwareLabels: true})
+
+	expected := map[string]string{
+		LabelHardwareCPUType:                 "host",
+		LabelHardwareMachine:                 "q35",
+		LabelHardwareBIOS:                    "ovmf",
+		LabelHardwareNUMA:                    "true",
+		LabelHardwareHugepages:               "2",
+		LabelHardwarePCIPassthrough:          "true",
+		LabelHardwarePCIPrefix + "10de-1db6": "1",
+		LabelHardwarePCIPrefix + "10de-2236": "1",
+	}
+
+	meta, err := i.InstanceMetadata(t.Context(), node)
+	assert.Nil(t, err)
+
+	for k, v := range expected {
+		assert.Equal(t, v, meta.AdditionalLabels[k], k)
+	}
+
+	node, err = kclient.CoreV1().Nodes().Get(t.Context(), "cluster-1-node-1", metav1.GetOptions{})
+	assert.Nil(t, err)
+
+	for k, v := range expected {
+		assert.Equal(t, v, node.Labels[k], k)
+	}
+
+	assert.NotContains(t, node.Labels, LabelHardwarePCIPrefix+"8086-7a06")
+
+	// Containers do not have hardware labels
+	meta, err = i.InstanceMetadata(t.Context(), &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-1-node-5"},
+		Spec:       v1.NodeSpec{ProviderID: "proxmox://cluster-1/lxc/105"},
+	})
+	assert.Nil(t, err)
+	assert.NotContains(t, meta.AdditionalLabels, LabelHardwareCPUType)
+}
@@ -0,0 +1,271 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	proxmox "github.com/luthermonson/go-proxmox"
+
+	providerconfig "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/config"
+	metrics "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/metrics"
+	"github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/proxmoxpool"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/cloud-provider/app"
+	cloudcontrollerconfig "k8s.io/cloud-provider/app/config"
+	cloudnodeutil "k8s.io/cloud-provider/node/helpers"
+	genericcontrollermanager "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// HostHealthControllerName is the name of the host health controller.
+	HostHealthControllerName = "host-health"
+	// HostHealthControllerClientName is the client name of the host health controller.
+	HostHealthControllerClientName = "host-health-controller"
+
+	// NodeConditionProxmoxHostReady is the node condition of the Proxmox node where the VM is running,
+	// the reason is the Proxmox node state: Online, Offline, Fenced or Unknown.
+	NodeConditionProxmoxHostReady v1.NodeConditionType = "ProxmoxHostReady"
+
+	hostHealthSyncPeriod = 15 * time.Second
+)
+
+// hostHealth sets the ProxmoxHostReady condition on the nodes from the state of the Proxmox nodes,
+// and optionally taints the nodes on the not ready Proxmox nodes with the NoExecute taint.
+type hostHealth struct {
+	c *client
+
+	taintAfter time.Duration
+
+	nodeLister  corelisters.NodeLister
+	nodesSynced cache.InformerSynced
+}
+
+func newHostHealth(client *client, features providerconfig.ClustersFeatures) *hostHealth {
+	return &hostHealth{
+		c:          client,
+		taintAfter: features.HostHealth.TaintAfter,
+	}
+}
+
+// StartHostHealthControllerWrapper is used to take cloud config as input and start the host health controller.
+func StartHostHealthControllerWrapper(_ app.ControllerInitContext, completedConfig *cloudcontrollerconfig.CompletedConfig, ccm cloudprovider.Interface) app.InitFunc {
+	return func(ctx context.Context, _ genericcontrollermanager.ControllerContext) (controller.Interface, bool, error) {
+		c, ok := ccm.(*cloud)
+		if !ok || c.hostHealth == nil {
+			return nil, false, nil
+		}
+
+		c.hostHealth.setInformer(completedConfig.SharedInformers.Core().V1().Nodes())
+
+		go c.hostHealth.Run(ctx)
+
+		return nil, true, nil
+	}
+}
+
+func (h *hostHealth) setInformer(informer coreinformers.NodeInformer) {
+	h.nodeLister = informer.Lister()
+	h.nodesSynced = informer.Informer().HasSynced
+}
+
+// Run starts the host health controller, it blocks until the context is done.
+func (h *hostHealth) Run(ctx context.Context) {
+	defer utilruntime.HandleCrash()
+
+	klog.InfoS("starting host-health controller", "taintAfter", h.taintAfter)
+	defer klog.InfoS("shutting down host-health controller")
+
+	if !cache.WaitForNamedCacheSync(HostHealthControllerName, ctx.Done(), h.nodesSynced) {
+		return
+	}
+
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := h.sync(ctx); err != nil {
+			klog.ErrorS(err, "host-health failed to sync nodes")
+		}
+	}, hostHealthSyncPeriod)
+}
+
+// sync reads the state of the Proxmox nodes of each region, and updates the node conditions.
+func (h *hostHealth) sync(ctx context.Context) error {
+	nodes, err := h.nodeLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	byRegion := map[string][]*v1.Node{}
+
+	for _, node := range nodes {
+		if region := getNodeRegion(node); region != "" && !hasUninitializedTaint(node) {
+			byRegion[region] = append(byRegion[region], node)
+		}
+	}
+
+	for region, nodes := range byRegion {
+		if err := h.syncRegion(ctx, region, nodes); err != nil {
+			klog.ErrorS(err, "host-health failed to sync region", "region", region)
+		}
+	}
+
+	return nil
+}
+
+func (h *hostHealth) syncRegion(ctx context.Context, region string, nodes []*v1.Node) error {
+	mc := metrics.NewMetricContext("getHostStates")
+
+	states, err := h.c.pxpool.GetHostStates(ctx, region)
+	if mc.ObserveRequest(err) != nil {
+		return err
+	}
+
+	// The VM placement must be recent, the HA manager recovers the VMs of the fenced Proxmox node
+	vms, err := h.c.pxpool.GetRecentInventoryVMs(ctx, region, placementMaxAge)
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[int]*proxmox.ClusterResource, len(vms))
+	for _, vm := range vms {
+		byID[int(vm.Resource.VMID)] = vm.Resource
+	}
+
+	for _, node := range nodes {
+		vmID, ok := getNodeVMID(node)
+		if !ok {
+			continue
+		}
+
+		rs := byID[vmID]
+		if rs == nil {
+			continue
+		}
+
+		state, ok := states[rs.Node]
+		if !ok {
+			state = proxmoxpool.HostStateUnknown
+		}
+
+		if err := h.syncNode(ctx, node, region, rs, state); err != nil {
+			klog.ErrorS(err, "host-health failed to sync node", "node", klog.KObj(node), "region", region, "vmID", vmID)
+		}
+	}
+
+	return nil
+}
+
+func (h *hostHealth) syncNode(ctx context.Context, node *v1.Node, region string, rs *proxmox.ClusterResource, state proxmoxpool.HostState) error {
+	vmID := int(rs.VMID) //nolint: gosec
+	now := metav1.Now()
+
+	condition := v1.NodeCondition{
+		Type:               NodeConditionProxmoxHostReady,
+		Status:             hostConditionStatus(state),
+		Reason:             string(state),
+		Message:            fmt.Sprintf("Proxmox node %s is %s", rs.Node, strings.ToLower(string(state))),
+		LastHeartbeatTime:  now,
+		LastTransitionTime: now,
+	}
+
+	idx := slices.IndexFunc(node.Status.Conditions, func(c v1.NodeCondition) bool { return c.Type == NodeConditionProxmoxHostReady })
+	if idx >= 0 && node.Status.Conditions[idx].Status == condition.Status {
+		condition.LastTransitionTime = node.Status.Conditions[idx].LastTransitionTime
+	}
+
+	if idx < 0 || node.Status.Conditions[idx].Status != condition.Status ||
+		node.Status.Conditions[idx].Reason != condition.Reason || node.Status.Conditions[idx].Message != condition.Message {
+		if err := h.patchCondition(ctx, node, condition); err != nil {
+			return err
+		}
+
+		klog.InfoS("host-health updated node condition", "node", klog.KObj(node), "region", region, "vmID", vmID, "host", rs.Node, "state", state)
+
+		if condition.Status != v1.ConditionTrue {
+			h.c.nodeWarningf(node, EventReasonHostNotReady, region, vmID, "%s", condition.Message)
+		}
+	}
+
+	taint := v1.Taint{Key: TaintHostNotReady, Effect: v1.TaintEffectNoExecute}
+	tainted := slices.ContainsFunc(node.Spec.Taints, func(t v1.Taint) bool { return t.MatchTaint(&taint) })
+
+	switch {
+	case h.taintAfter > 0 && condition.Status != v1.ConditionTrue:
+		if !tainted && now.Sub(condition.LastTransitionTime.Time) >= h.taintAfter {
+			taint.TimeAdded = &now
+
+			if err := cloudnodeutil.AddOrUpdateTaintOnNode(h.c.kclient, node.Name, &taint); err != nil {
+				return fmt.Errorf("failed to add taint to node %s: %w", node.Name, err)
+			}
+
+			klog.InfoS("host-health tainted node", "node", klog.KObj(node), "region", region, "vmID", vmID, "host", rs.Node, "state", state)
+		}
+	case tainted:
+		if err := cloudnodeutil.RemoveTaintOffNode(h.c.kclient, node.Name, node, &taint); err != nil {
+			return fmt.Errorf("failed to remove taint of node %s: %w", node.Name, err)
+		}
+
+		klog.InfoS("host-health removed node taint", "node", klog.KObj(node), "region", region, "vmID", vmID, "host", rs.Node)
+	}
+
+	return nil
+}
+
+// patchCondition updates the node condition, the conditions are merged by the type.
+func (h *hostHealth) patchCondition(ctx context.Context, node *v1.Node, condition v1.NodeCondition) error {
+	data, err := json.Marshal(map[string]any{
+		"status": map[string]any{
+			"conditions": []v1.NodeCondition{condition},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal the patch: %w", err)
+	}
+
+	if _, err := h.c.kclient.CoreV1().Nodes().Patch(ctx, node.Name, types.StrategicMergePatchType, data, metav1.PatchOptions{}, "status"); err != nil {
+		return fmt.Errorf("failed to patch node %s status: %w", node.Name, err)
+	}
+
+	return nil
+}
+
+func hostConditionStatus(state proxmoxpool.HostState) v1.ConditionStatus {
+	switch state {
+	case proxmoxpool.HostStateOnline:
+		return v1.ConditionTrue
+	case proxmoxpool.HostStateUnknown:
+		return v1.ConditionUnknown
+	default:
+		return v1.ConditionFalse
+	}
+}
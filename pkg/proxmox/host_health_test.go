@@ -0,0 +1,167 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmox
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+
+	providerconfig "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/config"
+	"github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/proxmoxpool"
+	testcluster "github.com/sergelogvinov/proxmox-cloud-controller-manager/test/cluster"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestHostConditionStatus(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, v1.ConditionTrue, hostConditionStatus(proxmoxpool.HostStateOnline))
+	assert.Equal(t, v1.ConditionFalse, hostConditionStatus(proxmoxpool.HostStateOffline))
+	assert.Equal(t, v1.ConditionFalse, hostConditionStatus(proxmoxpool.HostStateFenced))
+	assert.Equal(t, v1.ConditionUnknown, hostConditionStatus(proxmoxpool.HostStateUnknown))
+}
+
+func TestHostHealthSync(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	httpmock.RegisterResponder(http.MethodGet, `=~/cluster/ha/status/manager_status$`,
+		httpmock.NewJsonResponderOrPanic(200, map[string]any{
+			"data": map[string]any{
+				"manager_status": map[string]any{
+					"node_status": map[string]string{"pve-1": "online", "pve-2": "fence"},
+				},
+			},
+		}))
+
+	cfg, err := providerconfig.ReadCloudConfigFromFile("../../test/config/cluster-config-1.yaml")
+	assert.Nil(t, err)
+
+	px, err := proxmoxpool.NewProxmoxPool(cfg.Clusters)
+	assert.Nil(t, err)
+
+	taint := v1.Taint{Key: TaintHostNotReady, Effect: v1.TaintEffectNoExecute}
+	offline := metav1.NewTime(time.Now().Add(-10 * time.Minute))
+	nodes := []*v1.Node{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "cluster-1-node-1",
+				Labels: map[string]string{LabelTopologyRegion: "cluster-1"},
+			},
+			Spec: v1.NodeSpec{
+				ProviderID: "proxmox://cluster-1/100",
+				Taints:     []v1.Taint{taint},
+			},
+			Status: v1.NodeStatus{
+				Conditions: []v1.NodeCondition{
+					{Type: v1.NodeReady, Status: v1.ConditionTrue},
+					{Type: NodeConditionProxmoxHostReady, Status: v1.ConditionFalse, Reason: "Offline", Message: "Proxmox node pve-1 is offline"},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "cluster-1-node-2",
+				Labels: map[string]string{LabelTopologyRegion: "cluster-1"},
+			},
+			Spec: v1.NodeSpec{ProviderID: "proxmox://cluster-1/101"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "cluster-1-node-4",
+				Labels: map[string]string{LabelTopologyRegion: "cluster-1"},
+			},
+			Spec: v1.NodeSpec{ProviderID: "proxmox://cluster-1/104"},
+			Status: v1.NodeStatus{
+				Conditions: []v1.NodeCondition{
+					{
+						Type:               NodeConditionProxmoxHostReady,
+						Status:             v1.ConditionFalse,
+						Reason:             "Offline",
+						Message:            "Proxmox node pve-4 is offline",
+						LastTransitionTime: offline,
+					},
+				},
+			},
+		},
+	}
+
+	kclient := fake.NewClientset()
+	informer := informers.NewSharedInformerFactory(kclient, 0).Core().V1().Nodes()
+
+	for _, node := range nodes {
+		_, err := kclient.CoreV1().Nodes().Create(t.Context(), node, metav1.CreateOptions{})
+		assert.Nil(t, err)
+		assert.Nil(t, informer.Informer().GetIndexer().Add(node))
+	}
+
+	recorder := record.NewFakeRecorder(10)
+
+	h := newHostHealth(&client{pxpool: px, kclient: kclient, recorder: recorder}, providerconfig.ClustersFeatures{
+		HostHealth: providerconfig.HostHealthOpts{TaintAfter: time.Minute},
+	})
+	h.setInformer(informer)
+
+	assert.Nil(t, h.sync(t.Context()))
+
+	condition := func(node *v1.Node) *v1.NodeCondition {
+		for idx := range node.Status.Conditions {
+			if node.Status.Conditions[idx].Type == NodeConditionProxmoxHostReady {
+				return &node.Status.Conditions[idx]
+			}
+		}
+
+		return nil
+	}
+
+	// The Proxmox node is online again
+	node, err := kclient.CoreV1().Nodes().Get(t.Context(), "cluster-1-node-1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, node.Status.Conditions, 2)
+	assert.Equal(t, v1.ConditionTrue, condition(node).Status)
+	assert.Equal(t, "Online", condition(node).Reason)
+	assert.Empty(t, node.Spec.Taints)
+
+	// The Proxmox node was fenced, the taint is delayed
+	node, err = kclient.CoreV1().Nodes().Get(t.Context(), "cluster-1-node-2", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, v1.ConditionFalse, condition(node).Status)
+	assert.Equal(t, "Fenced", condition(node).Reason)
+	assert.Equal(t, "Proxmox node pve-2 is fenced", condition(node).Message)
+	assert.Empty(t, node.Spec.Taints)
+
+	// The Proxmox node is offline longer than the taint delay
+	node, err = kclient.CoreV1().Nodes().Get(t.Context(), "cluster-1-node-4", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, offline.Unix(), condition(node).LastTransitionTime.Unix())
+	assert.Len(t, node.Spec.Taints, 1)
+	assert.True(t, node.Spec.Taints[0].MatchTaint(&taint))
+
+	assert.Len(t, recorder.Events, 1)
+	assert.Equal(t, "Warning ProxmoxHostNotReady Proxmox node pve-2 is fenced (region=cluster-1, vmID=101)", <-recorder.Events)
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmox
+
+import (
+	"context"
+	"strconv"
+)
+
+var hostLabelKeys = []string{
+	LabelHostName,
+	LabelHostPVEVersion,
+	LabelHostCPUModel,
+	LabelHostKernel,
+	LabelHostKSM,
+	LabelHostCeph,
+}
+
+// hostLabels returns the node labels of the Proxmox node where the instance is running.
+// If the node status cannot be fetched, only the host name is returned with the error.
+func (i *instances) hostLabels(ctx context.Context, info *instanceInfo) (map[string]string, error) {
+	labels := map[string]string{
+		LabelHostName: labelValue(info.Node),
+	}
+
+	status, err := i.c.pxpool.GetNodeStatus(ctx, info.Region, info.Node)
+	if err != nil {
+		return labels, err
+	}
+
+	for k, v := range map[string]string{
+		LabelHostPVEVersion: status.Version(),
+		LabelHostCPUModel:   status.CPUInfo.Model,
+		LabelHostKernel:     status.Kernel(),
+	} {
+		if v = labelValue(v); v != "" {
+			labels[k] = v
+		}
+	}
+
+	labels[LabelHostKSM] = strconv.FormatBool(status.KSM.Shared > 0)
+	labels[LabelHostCeph] = strconv.FormatBool(status.Ceph)
+
+	return labels, nil
+}
+
+// staleHostLabels returns the host label keys which are not in the labels.
+func staleHostLabels(labels map[string]string) []string {
+	var keys []string
+
+	for _, k := range hostLabelKeys {
+		if _, ok := labels[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+
+	return keys
+}
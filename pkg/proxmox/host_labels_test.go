@@ -0,0 +1,95 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmox
+
+import (
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+
+	providerconfig "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/config"
+	"github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/proxmoxpool"
+	testcluster "github.com/sergelogvinov/proxmox-cloud-controller-manager/test/cluster"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestHostLabels(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	cfg, err := providerconfig.ReadCloudConfigFromFile("../../test/config/cluster-config-1.yaml")
+	assert.Nil(t, err)
+
+	px, err := proxmoxpool.NewProxmoxPool(cfg.Clusters)
+	assert.Nil(t, err)
+
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster-1-node-1",
+			Labels: map[string]string{
+				LabelHostName:       "pve-2",
+				LabelHostPVEVersion: "8.1.0",
+			},
+		},
+		Spec: v1.NodeSpec{ProviderID: "proxmox://cluster-1/100"},
+		Status: v1.NodeStatus{
+			NodeInfo: v1.NodeSystemInfo{SystemUUID: "11833f4c-341f-4bd3-aad7-f7abed000000"},
+		},
+	}
+
+	kclient := fake.NewClientset(node)
+	i := newInstances(&client{pxpool: px, kclient: kclient}, providerconfig.ClustersFeatures{HAGroup: true, HostLabels: true})
+
+	expected := map[string]string{
+		LabelHostName:       "pve-1",
+		LabelHostPVEVersion: "8.2.4",
+		LabelHostCPUModel:   "AMD-EPYC-7543-32-Core-Processor",
+		LabelHostKernel:     "6.8.12-1-pve",
+		LabelHostKSM:        "true",
+		LabelHostCeph:       "true",
+	}
+
+	meta, err := i.InstanceMetadata(t.Context(), node)
+	assert.Nil(t, err)
+	assert.Equal(t, "rnd", meta.Zone)
+
+	for k, v := range expected {
+		assert.Equal(t, v, meta.AdditionalLabels[k], k)
+	}
+
+	node, err = kclient.CoreV1().Nodes().Get(t.Context(), "cluster-1-node-1", metav1.GetOptions{})
+	assert.Nil(t, err)
+
+	for k, v := range expected {
+		assert.Equal(t, v, node.Labels[k], k)
+	}
+
+	// The node status is not available, the stale labels are kept
+	labels, err := i.hostLabels(t.Context(), &instanceInfo{Region: "cluster-1", Node: "pve-4"})
+	assert.NotNil(t, err)
+	assert.Equal(t, map[string]string{LabelHostName: "pve-4"}, labels)
+
+	labels, err = i.hostLabels(t.Context(), &instanceInfo{Region: "cluster-1", Node: "pve-2"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{LabelHostPVEVersion, LabelHostCPUModel, LabelHostKernel}, staleHostLabels(labels))
+}
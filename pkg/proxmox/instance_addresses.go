@@ -20,7 +20,9 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"maps"
 	"net"
+	"net/netip"
 	"slices"
 	"sort"
 	"strings"
@@ -29,6 +31,7 @@ import (
 
 	providerconfig "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/config"
 	metrics "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/metrics"
+	"github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/proxmoxpool"
 
 	v1 "k8s.io/api/core/v1"
 	cloudproviderapi "k8s.io/cloud-provider/api"
@@ -83,7 +86,12 @@ func (i *instances) addresses(ctx context.Context, node *v1.Node, info *instance
 	}
 
 	if i.networkOpts.Mode == providerconfig.NetworkModeOnlyQemu || i.networkOpts.Mode == providerconfig.NetworkModeAuto {
-		newAddresses, err := i.retrieveQemuAddresses(ctx, info)
+		retrieveAddresses := i.retrieveQemuAddresses
+		if info.GuestType == proxmoxpool.GuestTypeContainer {
+			retrieveAddresses = i.retrieveContainerAddresses
+		}
+
+		newAddresses, err := retrieveAddresses(ctx, info)
 		if err != nil {
 			klog.ErrorS(err, "Failed to retrieve host addresses")
 		}
@@ -122,8 +130,7 @@ func (i *instances) retrieveQemuAddresses(ctx context.Context, info *instanceInf
 	}
 
 	for _, nic := range nics {
-		if slices.Contains([]string{"lo", "cilium_net", "cilium_host"}, nic.Name) ||
-			strings.HasPrefix(nic.Name, "dummy") {
+		if isIgnoredInterface(nic.Name) {
 			continue
 		}
 
@@ -135,6 +142,61 @@ func (i *instances) retrieveQemuAddresses(ctx context.Context, info *instanceInf
 	return addresses, nil
 }
 
+// retrieveContainerAddresses retrieves the addresses from the LXC container network config and interfaces
+func (i *instances) retrieveContainerAddresses(ctx context.Context, info *instanceInfo) ([]v1.NodeAddress, error) {
+	var addresses []v1.NodeAddress
+
+	ct, err := i.c.pxpool.GetContainerConfig(ctx, info.Region, info.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Static addresses of the network config, net[n]: name=eth0,bridge=vmbr0,ip=10.0.0.1/24,ip6=fd00::1/64
+	if ct.ContainerConfig != nil {
+		nets := ct.ContainerConfig.MergeNets()
+
+		for _, key := range slices.Sorted(maps.Keys(nets)) {
+			for opt := range strings.SplitSeq(nets[key], ",") {
+				k, v, _ := strings.Cut(opt, "=")
+				if k != "ip" && k != "ip6" {
+					continue
+				}
+
+				if prefix, err := netip.ParsePrefix(v); err == nil {
+					i.processIP(ctx, &addresses, prefix.Addr().String())
+				}
+			}
+		}
+	}
+
+	mc := metrics.NewMetricContext("getContainerInterfaces")
+
+	ifaces, err := ct.Interfaces(ctx)
+	if mc.ObserveRequest(err) != nil {
+		return addresses, err
+	}
+
+	klog.V(4).InfoS("retrieveContainerAddresses() retrieved interfaces", "interfaces", ifaces)
+
+	for _, iface := range ifaces {
+		if isIgnoredInterface(iface.Name) {
+			continue
+		}
+
+		for _, addr := range []string{iface.Inet, iface.Inet6} {
+			if ip, _, _ := strings.Cut(addr, "/"); ip != "" {
+				i.processIP(ctx, &addresses, ip)
+			}
+		}
+	}
+
+	return addresses, nil
+}
+
+func isIgnoredInterface(name string) bool {
+	return slices.Contains([]string{"lo", "cilium_net", "cilium_host"}, name) || strings.HasPrefix(name, "dummy")
+}
+
 func (i *instances) processIP(_ context.Context, addresses *[]v1.NodeAddress, addr string) {
 	ip := net.ParseIP(addr)
 	if ip == nil || ip.IsLoopback() {
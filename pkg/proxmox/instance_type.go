@@ -0,0 +1,133 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmox
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	proxmox "github.com/luthermonson/go-proxmox"
+
+	providerconfig "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/config"
+	"github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/proxmoxpool"
+
+	"k8s.io/klog/v2"
+)
+
+// instanceShape is the VM shape used by the instance type template and catalogue.
+type instanceShape struct {
+	// CPUs is the number of vCPUs.
+	CPUs int
+	// Sockets and Cores are the CPU topology of the VM.
+	Sockets int
+	Cores   int
+	// CPUType is the CPU model, for example host or x86-64-v2-AES.
+	CPUType string
+	// MemoryMiB and MemoryGiB are the maximum memory of the VM.
+	MemoryMiB uint64
+	MemoryGiB float64
+	// NUMA is true if NUMA is enabled.
+	NUMA bool
+	// Hugepages is the hugepages size: 2, 1024 or any.
+	Hugepages string
+	// GuestType is qemu or lxc.
+	GuestType string
+}
+
+func vmShape(vm *proxmox.VirtualMachine) *instanceShape {
+	shape := &instanceShape{
+		CPUs:      vm.CPUs,
+		MemoryMiB: vm.MaxMem / 1024 / 1024,
+		MemoryGiB: float64(vm.MaxMem) / 1024 / 1024 / 1024,
+		GuestType: proxmoxpool.GuestTypeVM,
+	}
+
+	if cfg := vm.VirtualMachineConfig; cfg != nil {
+		shape.Sockets = cfg.Sockets
+		shape.Cores = cfg.Cores
+		shape.CPUType, _, _ = strings.Cut(cfg.CPU, ",")
+		shape.NUMA = cfg.Numa == 1
+		shape.Hugepages = cfg.Hugepages
+	}
+
+	return shape
+}
+
+func containerShape(ct *proxmox.Container) *instanceShape {
+	shape := &instanceShape{
+		CPUs:      ct.CPUs,
+		Sockets:   1,
+		Cores:     ct.CPUs,
+		MemoryMiB: ct.MaxMem / 1024 / 1024,
+		MemoryGiB: float64(ct.MaxMem) / 1024 / 1024 / 1024,
+		GuestType: proxmoxpool.GuestTypeContainer,
+	}
+
+	return shape
+}
+
+// instanceTypes names the instance type of the VM shape.
+type instanceTypes struct {
+	tmpl      *template.Template
+	catalogue []providerconfig.InstanceTypeEntry
+}
+
+func newInstanceTypes(opts providerconfig.InstanceTypeOpts) *instanceTypes {
+	t := &instanceTypes{
+		catalogue: opts.Catalogue,
+	}
+
+	if opts.Template != "" {
+		tmpl, err := template.New("instance-type").Parse(opts.Template)
+		if err != nil {
+			klog.ErrorS(err, "Failed to parse the instance type template", "template", opts.Template)
+		} else {
+			t.tmpl = tmpl
+		}
+	}
+
+	return t
+}
+
+// name returns the instance type of the first matching catalogue entry, or the rendered template.
+// If the template output is not a valid instance type name, the default name <CPUs>VCPU-<Memory>GB is used.
+func (t *instanceTypes) name(shape *instanceShape) string {
+	for _, e := range t.catalogue {
+		if e.CPUs == shape.CPUs && e.Memory == shape.MemoryMiB &&
+			(e.Sockets == 0 || e.Sockets == shape.Sockets) &&
+			(e.CPUType == "" || e.CPUType == shape.CPUType) {
+			return e.Name
+		}
+	}
+
+	if t.tmpl != nil {
+		var name strings.Builder
+
+		err := t.tmpl.Execute(&name, shape)
+		switch {
+		case err != nil:
+			klog.ErrorS(err, "Failed to render the instance type template")
+		case !providerconfig.InstanceTypeNameRegexp.MatchString(strings.TrimSpace(name.String())):
+			klog.ErrorS(providerconfig.ErrInvalidInstanceType, "Instance type template output is not valid", "instanceType", name.String())
+		default:
+			return strings.TrimSpace(name.String())
+		}
+	}
+
+	return fmt.Sprintf("%dVCPU-%dGB", shape.CPUs, shape.MemoryMiB/1024)
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmox
+
+import (
+	"testing"
+
+	proxmox "github.com/luthermonson/go-proxmox"
+	"github.com/stretchr/testify/assert"
+
+	providerconfig "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/config"
+)
+
+func TestVMShape(t *testing.T) {
+	t.Parallel()
+
+	shape := vmShape(&proxmox.VirtualMachine{
+		CPUs:   4,
+		MaxMem: 1536 * 1024 * 1024,
+		VirtualMachineConfig: &proxmox.VirtualMachineConfig{
+			Sockets:   2,
+			Cores:     2,
+			CPU:       "x86-64-v2-AES,flags=+aes",
+			Numa:      1,
+			Hugepages: "2",
+		},
+	})
+
+	assert.Equal(t, &instanceShape{
+		CPUs:      4,
+		Sockets:   2,
+		Cores:     2,
+		CPUType:   "x86-64-v2-AES",
+		MemoryMiB: 1536,
+		MemoryGiB: 1.5,
+		NUMA:      true,
+		Hugepages: "2",
+		GuestType: "qemu",
+	}, shape)
+}
+
+func TestInstanceTypeName(t *testing.T) {
+	t.Parallel()
+
+	shape := &instanceShape{CPUs: 4, Sockets: 2, Cores: 2, CPUType: "host", MemoryMiB: 1536, MemoryGiB: 1.5, GuestType: "qemu"}
+
+	tests := []struct {
+		msg      string
+		opts     providerconfig.InstanceTypeOpts
+		expected string
+	}{
+		{
+			msg:      "Default",
+			expected: "4VCPU-1GB",
+		},
+		{
+			msg:      "Template",
+			opts:     providerconfig.InstanceTypeOpts{Template: `{{ .Sockets }}x{{ .Cores }}-{{ .CPUType }}-{{ printf "%.1f" .MemoryGiB }}G`},
+			expected: "2x2-host-1.5G",
+		},
+		{
+			msg:      "TemplateInvalidOutput",
+			opts:     providerconfig.InstanceTypeOpts{Template: `{{ .CPUs }} cpu`},
+			expected: "4VCPU-1GB",
+		},
+		{
+			msg:      "TemplateExecError",
+			opts:     providerconfig.InstanceTypeOpts{Template: `{{ .Unknown }}`},
+			expected: "4VCPU-1GB",
+		},
+		{
+			msg: "Catalogue",
+			opts: providerconfig.InstanceTypeOpts{
+				Template: `{{ .CPUs }}VCPU`,
+				Catalogue: []providerconfig.InstanceTypeEntry{
+					{Name: "m.small", CPUs: 2, Memory: 1536},
+					{Name: "m.large-epyc", CPUs: 4, Memory: 1536, CPUType: "EPYC"},
+					{Name: "m.large", CPUs: 4, Memory: 1536, Sockets: 2},
+				},
+			},
+			expected: "m.large",
+		},
+		{
+			msg: "CatalogueNoMatch",
+			opts: providerconfig.InstanceTypeOpts{
+				Template:  `{{ .CPUs }}VCPU`,
+				Catalogue: []providerconfig.InstanceTypeEntry{{Name: "m.large", CPUs: 4, Memory: 2048}},
+			},
+			expected: "4VCPU",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.msg, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, testCase.expected, newInstanceTypes(testCase.opts).name(shape))
+		})
+	}
+}
@@ -20,11 +20,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"net"
-	"regexp"
 	"strconv"
 	"strings"
 
+	proxmox "github.com/luthermonson/go-proxmox"
+
 	goproxmox "github.com/sergelogvinov/go-proxmox"
 	providerconfig "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/config"
 	metrics "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/metrics"
@@ -45,24 +47,33 @@ type instanceNetops struct {
 }
 
 type instanceInfo struct {
-	ID     int
-	UUID   string
-	Name   string
-	Type   string
-	Node   string
-	Region string
-	Zone   string
+	ID        int
+	UUID      string
+	Name      string
+	Type      string
+	Node      string
+	Region    string
+	Zone      string
+	GuestType string
+	Tags      []string
+	Pool      string
+	// HardwareLabels are the node labels of the VM hardware configuration.
+	HardwareLabels map[string]string
 }
 
 type instances struct {
 	c             *client
-	zoneAsHAGroup bool
+	zones         *zoneResolver
 	provider      providerconfig.Provider
 	networkOpts   instanceNetops
 	updateLabels  bool
-}
+	powerStates   *powerStates
+	tagRules      tagRules
+	instanceTypes *instanceTypes
 
-var instanceTypeNameRegexp = regexp.MustCompile(`(^[a-zA-Z0-9_.-]+)$`)
+	withHardwareLabels bool
+	withHostLabels     bool
+}
 
 func newInstances(client *client, features providerconfig.ClustersFeatures) *instances {
 	externalIPCIDRs := ParseCIDRList(features.Network.ExternalIPCIDRS)
@@ -89,10 +100,16 @@ func newInstances(client *client, features providerconfig.ClustersFeatures) *ins
 
 	return &instances{
 		c:             client,
-		zoneAsHAGroup: features.HAGroup,
+		zones:         newZoneResolver(features),
 		provider:      features.Provider,
 		networkOpts:   netOps,
 		updateLabels:  features.ForceUpdateLabels,
+		powerStates:   newPowerStates(features.PowerState),
+		tagRules:      newTagRules(features.TagRules),
+		instanceTypes: newInstanceTypes(features.InstanceType),
+
+		withHardwareLabels: features.HardwareLabels,
+		withHostLabels:     features.HostLabels,
 	}
 }
 
@@ -114,13 +131,22 @@ func (i *instances) InstanceExists(ctx context.Context, node *v1.Node) (bool, er
 	}
 
 	mc := metrics.NewMetricContext("getVmInfo")
-	if _, err := i.getInstanceInfo(ctx, node); mc.ObserveRequest(err) != nil {
+
+	info, err := i.getInstanceInfo(ctx, node)
+	if mc.ObserveRequest(err) != nil {
 		if errors.Is(err, cloudprovider.InstanceNotFound) {
 			klog.V(4).InfoS("instances.InstanceExists() instance not found", "node", klog.KObj(node), "providerID", node.Spec.ProviderID)
 
 			return false, nil
 		}
 
+		// The VM belongs to another Kubernetes cluster
+		if errors.Is(err, proxmoxpool.ErrInstanceUntagged) {
+			klog.V(4).InfoS("instances.InstanceExists() instance does not have the cluster tag", "node", klog.KObj(node), "providerID", node.Spec.ProviderID)
+
+			return false, nil
+		}
+
 		if errors.Is(err, proxmoxpool.ErrNodeInaccessible) {
 			klog.V(4).InfoS("instances.InstanceExists() proxmox node inaccessible, cannot define instance status", "node", klog.KObj(node), "providerID", node.Spec.ProviderID)
 
@@ -130,6 +156,21 @@ func (i *instances) InstanceExists(ctx context.Context, node *v1.Node) (bool, er
 		return false, err
 	}
 
+	if i.powerStates.hasAbsent() {
+		mc := metrics.NewMetricContext("getVmState")
+
+		rs, err := i.getInstanceResource(ctx, info.Region, info.ID, info.GuestType == proxmoxpool.GuestTypeContainer)
+		if mc.ObserveRequest(err) != nil {
+			return false, err
+		}
+
+		if i.getInstanceState(ctx, info.Region, rs) == providerconfig.InstanceStateAbsent {
+			klog.V(4).InfoS("instances.InstanceExists() instance state is absent", "node", klog.KObj(node), "providerID", node.Spec.ProviderID)
+
+			return false, nil
+		}
+	}
+
 	return true, nil
 }
 
@@ -164,8 +205,7 @@ func (i *instances) InstanceShutdown(ctx context.Context, node *v1.Node) (bool,
 		}
 	}
 
-	px, err := i.c.pxpool.GetProxmoxCluster(region)
-	if err != nil {
+	if _, err := i.c.pxpool.GetProxmoxCluster(region); err != nil {
 		klog.ErrorS(err, "instances.InstanceShutdown() failed to get Proxmox cluster", "region", region)
 
 		return false, nil
@@ -173,16 +213,28 @@ func (i *instances) InstanceShutdown(ctx context.Context, node *v1.Node) (bool,
 
 	mc := metrics.NewMetricContext("getVmState")
 
-	vm, err := px.GetVMByID(ctx, uint64(vmID))
+	rs, err := i.getInstanceResource(ctx, region, vmID, provider.IsContainer(node.Spec.ProviderID))
 	if mc.ObserveRequest(err) != nil {
 		return false, err
 	}
 
-	if vm.Status == "stopped" {
-		return true, nil
+	if !i.c.pxpool.HasClusterTag(rs) {
+		klog.V(4).InfoS("instances.InstanceShutdown() instance does not have the cluster tag", "node", klog.KObj(node), "providerID", node.Spec.ProviderID)
+		i.c.nodeWarningf(node, EventReasonUntagged, region, vmID, "VM does not have the cluster tag")
+
+		return false, nil
 	}
 
-	return false, nil
+	return i.getInstanceState(ctx, region, rs) == providerconfig.InstanceStateShutdown, nil
+}
+
+// getInstanceResource returns the cluster resource of the VM or LXC container.
+func (i *instances) getInstanceResource(ctx context.Context, region string, vmID int, container bool) (*proxmox.ClusterResource, error) {
+	if container {
+		return i.c.pxpool.GetContainerByIDInRegion(ctx, region, vmID)
+	}
+
+	return i.c.pxpool.GetVMByIDInRegion(ctx, region, uint64(vmID)) //nolint: gosec
 }
 
 // InstanceMetadata returns the instance's metadata. The values returned in InstanceMetadata are
@@ -231,10 +283,13 @@ func (i *instances) InstanceMetadata(ctx context.Context, node *v1.Node) (*cloud
 	}
 
 	if providerID == "" {
-		if i.provider == providerconfig.ProviderCapmox {
+		switch {
+		case info.GuestType == proxmoxpool.GuestTypeContainer:
+			providerID = provider.GetProviderIDFromContainerID(info.Region, info.ID)
+		case i.provider == providerconfig.ProviderCapmox:
 			providerID = provider.GetProviderIDFromUUID(info.UUID)
 			annotations[AnnotationProxmoxInstanceID] = fmt.Sprintf("%d", info.ID)
-		} else {
+		default:
 			providerID = provider.GetProviderIDFromID(info.Region, info.ID)
 		}
 	}
@@ -248,30 +303,74 @@ func (i *instances) InstanceMetadata(ctx context.Context, node *v1.Node) (*cloud
 		AdditionalLabels: labels,
 	}
 
-	haGroups, err := i.c.pxpool.GetNodeHAGroups(ctx, info.Region, info.Node)
+	zone, haGroups, err := i.getInstanceZone(ctx, info)
 	if err != nil {
-		if !errors.Is(err, proxmoxpool.ErrHAGroupNotFound) {
-			klog.ErrorS(err, "instances.InstanceMetadata() failed to get HA group for the node", "node", klog.KRef("", node.Name), "region", info.Region)
+		klog.ErrorS(err, "instances.InstanceMetadata() cannot set zone of the node", "node", klog.KRef("", node.Name), "strategy", i.zones.Strategy)
+
+		switch i.zones.Strategy {
+		case providerconfig.ZoneStrategyPool:
+			i.c.nodeWarningf(node, EventReasonPoolZone, info.Region, info.ID, "Cannot set zone from resource pool, the guest is not a member of any pool")
+		case providerconfig.ZoneStrategyHAGroup:
+			i.c.nodeWarningf(node, EventReasonHAGroupZone, info.Region, info.ID, "Cannot set zone from HA group of Proxmox node %s", info.Node)
+		default:
+			i.c.nodeWarningf(node, EventReasonZone, info.Region, info.ID, "Cannot set zone with the %s strategy: %v", i.zones.Strategy, err)
+		}
+
+		return nil, err
+	}
+
+	staleLabels := []string{}
+
+	if i.withHostLabels {
+		hostLabels, err := i.hostLabels(ctx, info)
+		maps.Copy(labels, hostLabels)
+
+		if err != nil {
+			klog.ErrorS(err, "instances.InstanceMetadata() failed to get Proxmox node status", "node", klog.KRef("", node.Name), "region", info.Region, "host", info.Node)
+		} else {
+			staleLabels = append(staleLabels, staleHostLabels(hostLabels)...)
 		}
 	}
 
+	if info.Pool != "" {
+		labels[LabelPool] = labelValue(info.Pool)
+	} else {
+		staleLabels = append(staleLabels, LabelPool)
+	}
+
 	for _, g := range haGroups {
-		labels[LabelTopologyHAGroupPrefix+g] = ""
+		labels[LabelTopologyHAGroupPrefix+g.Name] = strconv.Itoa(g.Priority)
 	}
 
-	if i.zoneAsHAGroup {
-		if len(haGroups) == 0 {
-			err := fmt.Errorf("cannot set zone as HA-Group")
-			klog.ErrorS(err, "instances.InstanceMetadata() no HA groups found for the node", "node", klog.KRef("", node.Name))
+	metadata.Zone = zone
+	labels[LabelTopologyZone] = zone
 
-			return nil, err
+	tagLabels, _, invalid := i.tagRules.resolve(info.Tags)
+	if len(invalid) > 0 {
+		i.c.nodeWarningf(node, EventReasonTagRuleInvalid, info.Region, info.ID, "Skipped invalid tag rule entries: %s", strings.Join(invalid, "; "))
+	}
+
+	for k, v := range tagLabels {
+		if _, ok := labels[k]; !ok {
+			labels[k] = v
 		}
+	}
 
-		metadata.Zone = haGroups[0]
-		labels[LabelTopologyZone] = haGroups[0]
+	if i.withHardwareLabels && info.GuestType == proxmoxpool.GuestTypeVM {
+		maps.Copy(labels, info.HardwareLabels)
+		staleLabels = append(staleLabels, staleHardwareLabels(node, info.HardwareLabels)...)
 	}
 
+	// The node controller applies the labels on initialization, the node is changed only after it.
 	if !hasUninitializedTaint(node) {
+		if err := removeNodeLabels(ctx, i.c.kclient, node, staleLabels...); err != nil {
+			klog.ErrorS(err, "error removing stale labels of the node", "node", klog.KRef("", node.Name))
+		}
+
+		if err := i.syncTagRules(ctx, node, info.Tags); err != nil {
+			klog.ErrorS(err, "error syncing tag rules for the node", "node", klog.KRef("", node.Name))
+		}
+
 		if i.updateLabels {
 			labels[v1.LabelTopologyZone] = metadata.Zone
 			labels[v1.LabelFailureDomainBetaZone] = metadata.Zone
@@ -301,9 +400,10 @@ func (i *instances) getInstanceInfo(ctx context.Context, node *v1.Node) (*instan
 	klog.V(4).InfoS("instances.getInstanceInfo() called", "node", klog.KRef("", node.Name), "provider", i.provider)
 
 	var (
-		vmID   int
-		region string
-		err    error
+		vmID      int
+		region    string
+		guestType string
+		err       error
 	)
 
 	providerID := node.Spec.ProviderID
@@ -318,6 +418,11 @@ func (i *instances) getInstanceInfo(ctx context.Context, node *v1.Node) (*instan
 		if err != nil {
 			klog.ErrorS(err, "instances.getInstanceInfo() failed to parse providerID from node", "node", klog.KObj(node))
 		}
+	} else {
+		guestType = proxmoxpool.GuestTypeVM
+		if provider.IsContainer(providerID) {
+			guestType = proxmoxpool.GuestTypeContainer
+		}
 	}
 
 	if vmID == 0 || region == "" {
@@ -335,25 +440,82 @@ func (i *instances) getInstanceInfo(ctx context.Context, node *v1.Node) (*instan
 					return nil, cloudprovider.InstanceNotFound
 				}
 
+				if errors.Is(err, proxmoxpool.ErrInstanceUntagged) {
+					i.c.nodeWarningf(node, EventReasonUntagged, region, vmID, "VM does not have the cluster tag: %v", err)
+				} else {
+					i.c.nodeWarningf(node, EventReasonNodeUnreachable, region, vmID, "Cannot find the VM in Proxmox clusters: %v", err)
+				}
+
 				return nil, err
 			}
 		}
 	}
 
+	if guestType == "" {
+		mc := metrics.NewMetricContext("getGuestType")
+
+		guestType, err = i.c.pxpool.GetGuestType(ctx, region, vmID)
+		if mc.ObserveRequest(err) != nil {
+			if errors.Is(err, proxmoxpool.ErrInstanceNotFound) {
+				return nil, cloudprovider.InstanceNotFound
+			}
+
+			return nil, err
+		}
+	}
+
+	if err := i.c.pxpool.CheckClusterTag(ctx, region, vmID); err != nil {
+		if errors.Is(err, proxmoxpool.ErrInstanceNotFound) {
+			return nil, cloudprovider.InstanceNotFound
+		}
+
+		if errors.Is(err, proxmoxpool.ErrInstanceUntagged) {
+			i.c.nodeWarningf(node, EventReasonUntagged, region, vmID, "VM does not have the cluster tag")
+		}
+
+		return nil, err
+	}
+
+	mc := metrics.NewMetricContext("getGuestPool")
+
+	pool, err := i.c.pxpool.GetGuestPool(ctx, region, vmID)
+	if mc.ObserveRequest(err) != nil {
+		if errors.Is(err, proxmoxpool.ErrInstanceNotFound) {
+			return nil, cloudprovider.InstanceNotFound
+		}
+
+		return nil, err
+	}
+
+	if guestType == proxmoxpool.GuestTypeContainer {
+		info, err := i.getContainerInfo(ctx, node, region, vmID)
+		if err != nil {
+			return nil, err
+		}
+
+		info.Pool = pool
+
+		return info, nil
+	}
+
 	px, err := i.c.pxpool.GetProxmoxCluster(region)
 	if err != nil {
 		return nil, err
 	}
 
-	mc := metrics.NewMetricContext("getVMConfig")
+	mc = metrics.NewMetricContext("getVMConfig")
 
 	vm, err := px.GetVMConfig(ctx, vmID)
 	if mc.ObserveRequest(err) != nil {
 		if strings.Contains(err.Error(), "not found") {
+			i.c.pxpool.InvalidateVM(region, vmID)
+
 			return nil, cloudprovider.InstanceNotFound
 		}
 
 		if errors.Is(err, goproxmox.ErrVirtualMachineUnreachable) {
+			i.c.nodeWarningf(node, EventReasonNodeUnreachable, region, vmID, "Proxmox node of the VM is unreachable")
+
 			return nil, proxmoxpool.ErrNodeInaccessible
 		}
 
@@ -361,34 +523,117 @@ func (i *instances) getInstanceInfo(ctx context.Context, node *v1.Node) (*instan
 	}
 
 	info := &instanceInfo{
-		ID:     vmID,
-		UUID:   goproxmox.GetVMUUID(vm),
-		Name:   vm.Name,
-		Node:   vm.Node,
-		Region: region,
-		Zone:   vm.Node,
+		ID:        vmID,
+		UUID:      goproxmox.GetVMUUID(vm),
+		Name:      vm.Name,
+		Node:      vm.Node,
+		Region:    region,
+		Zone:      vm.Node,
+		GuestType: proxmoxpool.GuestTypeVM,
+		Pool:      pool,
 	}
 
 	if !strings.EqualFold(info.UUID, node.Status.NodeInfo.SystemUUID) {
 		klog.Errorf("instances.getInstanceInfo() node %s does not match SystemUUID=%s", info.Name, node.Status.NodeInfo.SystemUUID)
 
+		i.c.nodeWarningf(node, EventReasonSystemUUIDMismatch, region, vmID, "Node SystemUUID %s does not match VM UUID %s", node.Status.NodeInfo.SystemUUID, info.UUID)
+
+		i.c.pxpool.InvalidateVM(region, vmID)
+
 		return nil, cloudprovider.InstanceNotFound
 	}
 
-	if !strings.HasPrefix(info.Name, node.Name) {
+	if !i.c.pxpool.MatchNodeName(info.Name, node.Name) {
 		klog.Errorf("instances.getInstanceInfo() node %s does not match VM name=%s", node.Name, info.Name)
 
+		i.c.nodeWarningf(node, EventReasonNameMismatch, region, vmID, "Node name does not match VM name %s", info.Name)
+
 		return nil, cloudprovider.InstanceNotFound
 	}
 
+	if vm.VirtualMachineConfig != nil {
+		info.Tags = proxmoxpool.ParseTags(vm.VirtualMachineConfig.Tags)
+	}
+
+	if i.withHardwareLabels {
+		info.HardwareLabels = i.hardwareLabels(ctx, region, vm)
+	}
+
 	info.Type = goproxmox.GetVMSKU(vm)
-	if !instanceTypeNameRegexp.MatchString(info.Type) {
-		info.Type = fmt.Sprintf("%dVCPU-%dGB", vm.CPUs, vm.MaxMem/1024/1024/1024)
+	if !providerconfig.InstanceTypeNameRegexp.MatchString(info.Type) {
+		info.Type = i.instanceTypes.name(vmShape(vm))
 	}
 
 	return info, nil
 }
 
+// getContainerInfo returns the instance info of the LXC container.
+// The container shares the SMBIOS UUID with the Proxmox node, so only the container hostname is checked.
+func (i *instances) getContainerInfo(ctx context.Context, node *v1.Node, region string, vmID int) (*instanceInfo, error) {
+	mc := metrics.NewMetricContext("getContainerConfig")
+
+	ct, err := i.c.pxpool.GetContainerConfig(ctx, region, vmID)
+	if mc.ObserveRequest(err) != nil {
+		if errors.Is(err, proxmoxpool.ErrInstanceNotFound) {
+			i.c.pxpool.InvalidateVM(region, vmID)
+
+			return nil, cloudprovider.InstanceNotFound
+		}
+
+		if errors.Is(err, proxmoxpool.ErrNodeInaccessible) {
+			i.c.nodeWarningf(node, EventReasonNodeUnreachable, region, vmID, "Proxmox node of the container is unreachable")
+		}
+
+		return nil, err
+	}
+
+	info := &instanceInfo{
+		ID:        vmID,
+		Name:      ct.Name,
+		Type:      i.instanceTypes.name(containerShape(ct)),
+		Node:      ct.Node,
+		Region:    region,
+		Zone:      ct.Node,
+		GuestType: proxmoxpool.GuestTypeContainer,
+	}
+
+	if ct.ContainerConfig != nil {
+		if ct.ContainerConfig.Hostname != "" {
+			info.Name = ct.ContainerConfig.Hostname
+		}
+
+		info.Tags = proxmoxpool.ParseTags(ct.ContainerConfig.Tags)
+	}
+
+	if !i.c.pxpool.MatchNodeName(info.Name, node.Name) {
+		klog.Errorf("instances.getContainerInfo() node %s does not match container hostname=%s", node.Name, info.Name)
+
+		i.c.nodeWarningf(node, EventReasonNameMismatch, region, vmID, "Node name does not match container hostname %s", info.Name)
+
+		return nil, cloudprovider.InstanceNotFound
+	}
+
+	return info, nil
+}
+
+// getInstanceZone returns the zone and the HA groups of the Proxmox node where the instance is running.
+// The zone is derived with the zone strategy, the Proxmox node name by default.
+func (i *instances) getInstanceZone(ctx context.Context, info *instanceInfo) (string, []proxmoxpool.HAGroup, error) {
+	haGroups, err := i.c.pxpool.GetGuestHAGroups(ctx, info.Region, info.Node, info.ID)
+	if err != nil {
+		if !errors.Is(err, proxmoxpool.ErrHAGroupNotFound) {
+			klog.ErrorS(err, "instances.getInstanceZone() failed to get HA group for the node", "node", info.Node, "region", info.Region)
+		}
+	}
+
+	zone, err := i.zones.zone(ctx, i.c.pxpool, info, haGroups)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return zone, haGroups, nil
+}
+
 func (i *instances) parseProviderIDFromNode(node *v1.Node) (vmID int, region string, err error) {
 	if node.Annotations[AnnotationProxmoxInstanceID] != "" {
 		region = node.Labels[LabelTopologyRegion]
@@ -22,6 +22,7 @@ import (
 
 	"github.com/jarcoal/httpmock"
 	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 
 	goproxmox "github.com/sergelogvinov/go-proxmox"
@@ -32,6 +33,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
 	cloudprovider "k8s.io/cloud-provider"
 	cloudproviderapi "k8s.io/cloud-provider/api"
 )
@@ -416,6 +418,18 @@ func (ts *configuredTestSuite) TestInstanceShutdown() {
 			},
 			expected: true,
 		},
+		{
+			msg: "ContainerExists",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "cluster-1-node-5",
+				},
+				Spec: v1.NodeSpec{
+					ProviderID: "proxmox://cluster-1/lxc/105",
+				},
+			},
+			expected: false,
+		},
 		{
 			msg: "NodeExistsWithDifferentName",
 			node: &v1.Node{
@@ -665,9 +679,10 @@ func (ts *configuredTestSuite) TestInstanceMetadata() {
 				Region:       "cluster-1",
 				Zone:         "pve-1",
 				AdditionalLabels: map[string]string{
-					"group.topology.proxmox.sinextra.dev/rnd": "",
+					"group.topology.proxmox.sinextra.dev/rnd": "2",
 					"topology.proxmox.sinextra.dev/region":    "cluster-1",
 					"topology.proxmox.sinextra.dev/zone":      "pve-1",
+					"proxmox.sinextra.dev/pool":               "team-a",
 				},
 			},
 		},
@@ -718,9 +733,10 @@ func (ts *configuredTestSuite) TestInstanceMetadata() {
 				Region:       "cluster-1",
 				Zone:         "pve-1",
 				AdditionalLabels: map[string]string{
-					"group.topology.proxmox.sinextra.dev/rnd": "",
+					"group.topology.proxmox.sinextra.dev/rnd": "2",
 					"topology.proxmox.sinextra.dev/region":    "cluster-1",
 					"topology.proxmox.sinextra.dev/zone":      "pve-1",
+					"proxmox.sinextra.dev/pool":               "team-a",
 				},
 			},
 		},
@@ -773,6 +789,52 @@ func (ts *configuredTestSuite) TestInstanceMetadata() {
 			},
 			expected: &cloudprovider.InstanceMetadata{},
 		},
+		{
+			msg: "ContainerExists",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "cluster-1-node-5",
+					Annotations: map[string]string{
+						cloudproviderapi.AnnotationAlphaProvidedIPAddr: "1.2.3.4",
+					},
+				},
+				Status: v1.NodeStatus{
+					NodeInfo: v1.NodeSystemInfo{
+						SystemUUID: "11833f4c-341f-4bd3-aad7-f7abed999999",
+					},
+				},
+				Spec: v1.NodeSpec{
+					Taints: []v1.Taint{
+						{
+							Key:    cloudproviderapi.TaintExternalCloudProvider,
+							Value:  "true",
+							Effect: v1.TaintEffectNoSchedule,
+						},
+					},
+				},
+			},
+			expected: &cloudprovider.InstanceMetadata{
+				ProviderID: "proxmox://cluster-1/lxc/105",
+				NodeAddresses: []v1.NodeAddress{
+					{
+						Type:    v1.NodeHostName,
+						Address: "cluster-1-node-5",
+					},
+					{
+						Type:    v1.NodeInternalIP,
+						Address: "1.2.3.4",
+					},
+				},
+				InstanceType: "2VCPU-2GB",
+				Region:       "cluster-1",
+				Zone:         "pve-2",
+				AdditionalLabels: map[string]string{
+					"group.topology.proxmox.sinextra.dev/rnd": "0",
+					"topology.proxmox.sinextra.dev/region":    "cluster-1",
+					"topology.proxmox.sinextra.dev/zone":      "pve-2",
+				},
+			},
+		},
 		{
 			msg: "NodeExistsCluster2",
 			node: &v1.Node{
@@ -837,3 +899,87 @@ func (ts *configuredTestSuite) TestInstanceMetadata() {
 		})
 	}
 }
+
+func TestContainerAddresses(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	cfg, err := providerconfig.ReadCloudConfigFromFile("../../test/config/cluster-config-1.yaml")
+	assert.Nil(t, err)
+
+	px, err := proxmoxpool.NewProxmoxPool(cfg.Clusters)
+	assert.Nil(t, err)
+
+	i := newInstances(&client{pxpool: px, kclient: fake.NewClientset()}, providerconfig.ClustersFeatures{
+		Network: providerconfig.NetworkOpts{Mode: providerconfig.NetworkModeAuto},
+	})
+
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster-1-node-5",
+			Annotations: map[string]string{
+				cloudproviderapi.AnnotationAlphaProvidedIPAddr: "172.16.0.105",
+			},
+		},
+		Spec: v1.NodeSpec{
+			ProviderID: "proxmox://cluster-1/lxc/105",
+		},
+	}
+
+	meta, err := i.InstanceMetadata(t.Context(), node)
+	assert.Nil(t, err)
+	assert.Equal(t, "proxmox://cluster-1/lxc/105", meta.ProviderID)
+	assert.Equal(t, []v1.NodeAddress{
+		{Type: v1.NodeHostName, Address: "cluster-1-node-5"},
+		{Type: v1.NodeInternalIP, Address: "172.16.0.105"},
+		{Type: v1.NodeInternalIP, Address: "2001:db8::105"},
+	}, meta.NodeAddresses)
+}
+
+func TestInstanceMetadataPoolAsZone(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	cfg, err := providerconfig.ReadCloudConfigFromFile("../../test/config/cluster-config-1.yaml")
+	assert.Nil(t, err)
+
+	px, err := proxmoxpool.NewProxmoxPool(cfg.Clusters)
+	assert.Nil(t, err)
+
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "cluster-1-node-1",
+			Labels: map[string]string{LabelPool: "team-b"},
+		},
+		Spec: v1.NodeSpec{ProviderID: "proxmox://cluster-1/100"},
+		Status: v1.NodeStatus{
+			NodeInfo: v1.NodeSystemInfo{SystemUUID: "11833f4c-341f-4bd3-aad7-f7abed000000"},
+		},
+	}
+
+	kclient := fake.NewClientset(node)
+	recorder := record.NewFakeRecorder(10)
+	i := newInstances(&client{pxpool: px, kclient: kclient, recorder: recorder}, providerconfig.ClustersFeatures{PoolAsZone: true})
+
+	meta, err := i.InstanceMetadata(t.Context(), node)
+	assert.Nil(t, err)
+	assert.Equal(t, "team-a", meta.Zone)
+	assert.Equal(t, "team-a", meta.AdditionalLabels[LabelPool])
+	assert.Equal(t, "team-a", meta.AdditionalLabels[LabelTopologyZone])
+
+	node, err = kclient.CoreV1().Nodes().Get(t.Context(), "cluster-1-node-1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "team-a", node.Labels[LabelPool])
+
+	// The container is not a member of any pool
+	_, err = i.InstanceMetadata(t.Context(), &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-1-node-5"},
+		Spec:       v1.NodeSpec{ProviderID: "proxmox://cluster-1/lxc/105"},
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, "Warning ProxmoxPoolZoneFailed Cannot set zone from resource pool, the guest is not a member of any pool (region=cluster-1, vmID=105)", <-recorder.Events)
+}
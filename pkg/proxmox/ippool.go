@@ -0,0 +1,143 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmox
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	providerconfig "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/config"
+)
+
+type ipRange struct {
+	first netip.Addr
+	last  netip.Addr
+}
+
+// ipPool is a set of address ranges which can be allocated to LoadBalancer services.
+type ipPool struct {
+	name   string
+	region string
+	zone   string
+	ranges []ipRange
+}
+
+func newIPPool(cfg providerconfig.LoadBalancerPool) (*ipPool, error) {
+	pool := &ipPool{
+		name:   cfg.Name,
+		region: cfg.Region,
+		zone:   cfg.Zone,
+	}
+
+	for _, item := range SplitTrim(cfg.Addresses, ',') {
+		r, err := parseIPRange(item)
+		if err != nil {
+			return nil, fmt.Errorf("pool %s: %w", cfg.Name, err)
+		}
+
+		pool.ranges = append(pool.ranges, r)
+	}
+
+	if len(pool.ranges) == 0 {
+		return nil, fmt.Errorf("pool %s has no addresses", cfg.Name)
+	}
+
+	return pool, nil
+}
+
+// parseIPRange parses a CIDR or a first-last range of addresses.
+// Network and broadcast addresses of IPv4 CIDRs are excluded.
+func parseIPRange(s string) (ipRange, error) {
+	if first, last, ok := strings.Cut(s, "-"); ok {
+		f, err := netip.ParseAddr(strings.TrimSpace(first))
+		if err != nil {
+			return ipRange{}, fmt.Errorf("invalid range %s: %w", s, err)
+		}
+
+		l, err := netip.ParseAddr(strings.TrimSpace(last))
+		if err != nil {
+			return ipRange{}, fmt.Errorf("invalid range %s: %w", s, err)
+		}
+
+		if f.Is4() != l.Is4() || l.Less(f) {
+			return ipRange{}, fmt.Errorf("invalid range %s", s)
+		}
+
+		return ipRange{first: f, last: l}, nil
+	}
+
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return ipRange{}, fmt.Errorf("invalid CIDR %s: %w", s, err)
+	}
+
+	prefix = prefix.Masked()
+	first := prefix.Addr()
+	last := lastAddr(prefix)
+
+	if first.Is4() && prefix.Bits() < 31 {
+		first = first.Next()
+		last = last.Prev()
+	}
+
+	return ipRange{first: first, last: last}, nil
+}
+
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().AsSlice()
+	for i := prefix.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 1 << (7 - uint(i%8))
+	}
+
+	addr, _ := netip.AddrFromSlice(b)
+
+	return addr
+}
+
+// Contains checks if the address belongs to the pool.
+func (p *ipPool) Contains(addr netip.Addr) bool {
+	for _, r := range p.ranges {
+		if r.first.Is4() == addr.Is4() && !addr.Less(r.first) && !r.last.Less(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Allocate returns the first address of the requested family which is not in use.
+func (p *ipPool) Allocate(used map[netip.Addr]bool, ipv6 bool) (netip.Addr, error) {
+	for _, r := range p.ranges {
+		if r.first.Is6() != ipv6 {
+			continue
+		}
+
+		for addr := r.first; addr.IsValid() && !r.last.Less(addr); addr = addr.Next() {
+			if !used[addr] {
+				return addr, nil
+			}
+		}
+	}
+
+	family := "IPv4"
+	if ipv6 {
+		family = "IPv6"
+	}
+
+	return netip.Addr{}, fmt.Errorf("pool %s has no free %s addresses", p.name, family)
+}
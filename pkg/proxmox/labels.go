@@ -23,6 +23,61 @@ const (
 	// LabelTopologyZone is the label used to store the Proxmox zone name.
 	LabelTopologyZone = "topology." + Group + "/zone"
 
-	// LabelTopologyHAGroupPrefix is the prefix for labels used to store Proxmox HA group information.
+	// LabelTopologyHAGroupPrefix is the prefix for labels used to store Proxmox HA group information,
+	// the label value is the priority of the Proxmox node in the group.
 	LabelTopologyHAGroupPrefix = "group.topology." + Group + "/"
+
+	// TaintHAMaintenance is the default taint key of the nodes on the Proxmox nodes in the HA maintenance mode.
+	TaintHAMaintenance = Group + "/ha-maintenance"
+
+	// TaintHostNotReady is the taint key of the nodes on the not ready Proxmox nodes.
+	TaintHostNotReady = Group + "/host-not-ready"
+
+	// LabelPool is the label used to store the Proxmox resource pool name.
+	LabelPool = Group + "/pool"
+
+	// LabelHostPrefix is the prefix for labels used to store the Proxmox node (hypervisor) information.
+	LabelHostPrefix = "host." + Group + "/"
+
+	// LabelHostName is the label used to store the Proxmox node name where the VM is running.
+	LabelHostName = LabelHostPrefix + "name"
+
+	// LabelHostPVEVersion is the label used to store the Proxmox VE version of the Proxmox node.
+	LabelHostPVEVersion = LabelHostPrefix + "pve-version"
+
+	// LabelHostCPUModel is the label used to store the CPU model of the Proxmox node.
+	LabelHostCPUModel = LabelHostPrefix + "cpu-model"
+
+	// LabelHostKernel is the label used to store the kernel release of the Proxmox node.
+	LabelHostKernel = LabelHostPrefix + "kernel"
+
+	// LabelHostKSM is the label used to store if the KSM shares memory pages on the Proxmox node.
+	LabelHostKSM = LabelHostPrefix + "ksm"
+
+	// LabelHostCeph is the label used to store if the Ceph storage is available on the Proxmox node.
+	LabelHostCeph = LabelHostPrefix + "ceph"
+
+	// LabelHardwarePrefix is the prefix for labels used to store the VM hardware configuration.
+	LabelHardwarePrefix = "hardware." + Group + "/"
+
+	// LabelHardwareCPUType is the label used to store the VM CPU type.
+	LabelHardwareCPUType = LabelHardwarePrefix + "cpu-type"
+
+	// LabelHardwareMachine is the label used to store the VM machine type, i440fx or q35.
+	LabelHardwareMachine = LabelHardwarePrefix + "machine"
+
+	// LabelHardwareBIOS is the label used to store the VM firmware, seabios or ovmf.
+	LabelHardwareBIOS = LabelHardwarePrefix + "bios"
+
+	// LabelHardwareNUMA is the label used to store if NUMA is enabled on the VM.
+	LabelHardwareNUMA = LabelHardwarePrefix + "numa"
+
+	// LabelHardwareHugepages is the label used to store the VM hugepages size.
+	LabelHardwareHugepages = LabelHardwarePrefix + "hugepages"
+
+	// LabelHardwarePCIPassthrough is the label used to store if the VM has PCI passthrough devices.
+	LabelHardwarePCIPassthrough = LabelHardwarePrefix + "pci-passthrough"
+
+	// LabelHardwarePCIPrefix is the prefix for labels used to store the number of PCI passthrough devices by vendor-device ID.
+	LabelHardwarePCIPrefix = "pci." + LabelHardwarePrefix
 )
@@ -0,0 +1,333 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+
+	providerconfig "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/config"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/klog/v2"
+)
+
+type loadbalancer struct {
+	c     *client
+	pools []*ipPool
+
+	// mu serializes allocations, the service list is the source of truth.
+	mu sync.Mutex
+}
+
+func newLoadBalancer(client *client, features providerconfig.ClustersFeatures) (*loadbalancer, error) {
+	if len(features.LoadBalancer.Pools) == 0 {
+		return nil, nil
+	}
+
+	pools := make([]*ipPool, 0, len(features.LoadBalancer.Pools))
+
+	for _, cfg := range features.LoadBalancer.Pools {
+		pool, err := newIPPool(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		pools = append(pools, pool)
+	}
+
+	return &loadbalancer{
+		c:     client,
+		pools: pools,
+	}, nil
+}
+
+// GetLoadBalancer returns whether the specified load balancer exists, and
+// if so, what its status is.
+func (l *loadbalancer) GetLoadBalancer(_ context.Context, _ string, service *v1.Service) (*v1.LoadBalancerStatus, bool, error) {
+	klog.V(4).InfoS("loadbalancer.GetLoadBalancer() called", "service", klog.KObj(service))
+
+	addrs := serviceAllocatedAddresses(service)
+	if len(addrs) == 0 {
+		return nil, false, nil
+	}
+
+	return loadBalancerStatus(addrs), true, nil
+}
+
+// GetLoadBalancerName returns the name of the load balancer.
+func (l *loadbalancer) GetLoadBalancerName(_ context.Context, _ string, service *v1.Service) string {
+	return cloudprovider.DefaultLoadBalancerName(service)
+}
+
+// EnsureLoadBalancer allocates the load balancer addresses from the IP pool,
+// stores them in the service annotations and returns the load balancer status.
+func (l *loadbalancer) EnsureLoadBalancer(ctx context.Context, _ string, service *v1.Service, nodes []*v1.Node) (*v1.LoadBalancerStatus, error) {
+	klog.V(4).InfoS("loadbalancer.EnsureLoadBalancer() called", "service", klog.KObj(service))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	pool, err := l.selectPool(service, nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	used, err := l.usedAddresses(ctx, service)
+	if err != nil {
+		return nil, err
+	}
+
+	current := serviceAllocatedAddresses(service)
+	addrs := []netip.Addr{}
+
+	for _, ipv6 := range serviceIPFamilies(service) {
+		addr, err := l.allocateAddress(pool, service, current, used, ipv6)
+		if err != nil {
+			return nil, err
+		}
+
+		used[addr] = true
+		addrs = append(addrs, addr)
+	}
+
+	annotations := map[string]string{
+		AnnotationLoadBalancerPool: pool.name,
+		AnnotationLoadBalancerIPs:  joinAddresses(addrs),
+	}
+
+	if err := patchServiceAnnotations(ctx, l.c, service, annotations); err != nil {
+		return nil, fmt.Errorf("failed to store load balancer addresses: %w", err)
+	}
+
+	klog.V(4).InfoS("loadbalancer.EnsureLoadBalancer() allocated addresses", "service", klog.KObj(service), "pool", pool.name, "addresses", addrs)
+
+	return loadBalancerStatus(addrs), nil
+}
+
+// UpdateLoadBalancer updates hosts under the specified load balancer.
+// The addresses do not depend on the nodes, so there is nothing to update.
+func (l *loadbalancer) UpdateLoadBalancer(_ context.Context, _ string, service *v1.Service, _ []*v1.Node) error {
+	klog.V(4).InfoS("loadbalancer.UpdateLoadBalancer() called", "service", klog.KObj(service))
+
+	return nil
+}
+
+// EnsureLoadBalancerDeleted releases the load balancer addresses of the service,
+// and removes the pool annotation, the next allocation selects the pool again.
+func (l *loadbalancer) EnsureLoadBalancerDeleted(ctx context.Context, _ string, service *v1.Service) error {
+	klog.V(4).InfoS("loadbalancer.EnsureLoadBalancerDeleted() called", "service", klog.KObj(service))
+
+	if _, ok := service.Annotations[AnnotationLoadBalancerIPs]; !ok {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	annotations := map[string]string{
+		AnnotationLoadBalancerPool: "",
+		AnnotationLoadBalancerIPs:  "",
+	}
+
+	err := patchServiceAnnotations(ctx, l.c, service, annotations)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to release load balancer addresses: %w", err)
+	}
+
+	return nil
+}
+
+// selectPool returns the pool requested by the service annotation,
+// or the first pool which matches the region and zone of the nodes.
+func (l *loadbalancer) selectPool(service *v1.Service, nodes []*v1.Node) (*ipPool, error) {
+	if name := service.Annotations[AnnotationLoadBalancerPool]; name != "" {
+		for _, pool := range l.pools {
+			if pool.name == name {
+				return pool, nil
+			}
+		}
+
+		return nil, fmt.Errorf("load balancer pool %s not found", name)
+	}
+
+	for _, pool := range l.pools {
+		for _, node := range nodes {
+			region := node.Labels[LabelTopologyRegion]
+			if region == "" {
+				region = node.Labels[v1.LabelTopologyRegion]
+			}
+
+			zone := node.Labels[LabelTopologyZone]
+			if zone == "" {
+				zone = node.Labels[v1.LabelTopologyZone]
+			}
+
+			if pool.region == region && (pool.zone == "" || pool.zone == zone) {
+				return pool, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("no load balancer pool matches the region of the nodes of service %s/%s", service.Namespace, service.Name)
+}
+
+// allocateAddress uses the requested address, keeps the already allocated one, or allocates a new address.
+// The allocated address is replaced when the service requests another one.
+func (l *loadbalancer) allocateAddress(pool *ipPool, service *v1.Service, current []netip.Addr, used map[netip.Addr]bool, ipv6 bool) (netip.Addr, error) {
+	if service.Spec.LoadBalancerIP != "" { //nolint:staticcheck
+		addr, err := netip.ParseAddr(service.Spec.LoadBalancerIP) //nolint:staticcheck
+		if err == nil && addr.Is6() == ipv6 {
+			if !pool.Contains(addr) {
+				return netip.Addr{}, fmt.Errorf("requested address %s is not in the pool %s", addr, pool.name)
+			}
+
+			if used[addr] {
+				return netip.Addr{}, fmt.Errorf("requested address %s is already in use", addr)
+			}
+
+			return addr, nil
+		}
+	}
+
+	for _, addr := range current {
+		if addr.Is6() == ipv6 && pool.Contains(addr) && !used[addr] {
+			return addr, nil
+		}
+	}
+
+	return pool.Allocate(used, ipv6)
+}
+
+// usedAddresses returns the addresses allocated to all other services.
+func (l *loadbalancer) usedAddresses(ctx context.Context, service *v1.Service) (map[netip.Addr]bool, error) {
+	services, err := l.c.kclient.CoreV1().Services(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services: %w", err)
+	}
+
+	used := map[netip.Addr]bool{}
+
+	for _, svc := range services.Items {
+		if svc.UID == service.UID {
+			continue
+		}
+
+		for _, addr := range serviceAllocatedAddresses(&svc) {
+			used[addr] = true
+		}
+	}
+
+	return used, nil
+}
+
+func serviceAllocatedAddresses(service *v1.Service) []netip.Addr {
+	addrs := []netip.Addr{}
+
+	for _, item := range SplitTrim(service.Annotations[AnnotationLoadBalancerIPs], ',') {
+		addr, err := netip.ParseAddr(item)
+		if err != nil {
+			klog.Warningf("Ignoring invalid load balancer address '%s' of service %s/%s", item, service.Namespace, service.Name)
+
+			continue
+		}
+
+		addrs = append(addrs, addr)
+	}
+
+	return addrs
+}
+
+// serviceIPFamilies returns the list of families, true is IPv6.
+func serviceIPFamilies(service *v1.Service) []bool {
+	if len(service.Spec.IPFamilies) == 0 {
+		return []bool{false}
+	}
+
+	families := []bool{}
+
+	for _, family := range service.Spec.IPFamilies {
+		ipv6 := family == v1.IPv6Protocol
+		if !slices.Contains(families, ipv6) {
+			families = append(families, ipv6)
+		}
+	}
+
+	return families
+}
+
+func joinAddresses(addrs []netip.Addr) string {
+	items := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		items = append(items, addr.String())
+	}
+
+	return strings.Join(items, ",")
+}
+
+func loadBalancerStatus(addrs []netip.Addr) *v1.LoadBalancerStatus {
+	vip := v1.LoadBalancerIPModeVIP
+	status := &v1.LoadBalancerStatus{}
+
+	for _, addr := range addrs {
+		status.Ingress = append(status.Ingress, v1.LoadBalancerIngress{
+			IP:     addr.String(),
+			IPMode: &vip,
+		})
+	}
+
+	return status
+}
+
+// patchServiceAnnotations sets the service annotations, an empty value removes the annotation.
+func patchServiceAnnotations(ctx context.Context, c *client, service *v1.Service, annotations map[string]string) error {
+	patch := map[string]any{}
+
+	for k, v := range annotations {
+		if v == "" {
+			patch[k] = nil
+
+			continue
+		}
+
+		if service.Annotations[k] != v {
+			patch[k] = v
+		}
+	}
+
+	if len(patch) == 0 {
+		return nil
+	}
+
+	patchBytes, err := json.Marshal(map[string]any{"metadata": map[string]any{"annotations": patch}})
+	if err != nil {
+		return fmt.Errorf("failed to marshal the patch: %w", err)
+	}
+
+	_, err = c.kclient.CoreV1().Services(service.Namespace).Patch(ctx, service.Name, types.MergePatchType, patchBytes, metav1.PatchOptions{})
+
+	return err
+}
@@ -0,0 +1,226 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmox
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	providerconfig "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/config"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestService(name string, annotations map[string]string) *v1.Service {
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "default",
+			UID:         types.UID("uid-" + name),
+			Annotations: annotations,
+		},
+		Spec: v1.ServiceSpec{
+			Type: v1.ServiceTypeLoadBalancer,
+		},
+	}
+}
+
+func newTestLoadBalancer(t *testing.T, services ...*v1.Service) *loadbalancer {
+	t.Helper()
+
+	kclient := fake.NewClientset()
+	for _, svc := range services {
+		_, err := kclient.CoreV1().Services(svc.Namespace).Create(t.Context(), svc, metav1.CreateOptions{})
+		assert.Nil(t, err)
+	}
+
+	lb, err := newLoadBalancer(&client{kclient: kclient}, providerconfig.ClustersFeatures{
+		LoadBalancer: providerconfig.LoadBalancerOpts{
+			Pools: []providerconfig.LoadBalancerPool{
+				{Name: "zone-a", Region: "cluster-1", Zone: "pve-1", Addresses: "10.0.0.1-10.0.0.2"},
+				{Name: "default", Region: "cluster-1", Addresses: "10.0.1.0/30,fd00::/126"},
+			},
+		},
+	})
+	assert.Nil(t, err)
+	assert.NotNil(t, lb)
+
+	return lb
+}
+
+func TestNewLoadBalancer(t *testing.T) {
+	lb, err := newLoadBalancer(&client{}, providerconfig.ClustersFeatures{})
+	assert.Nil(t, err)
+	assert.Nil(t, lb)
+
+	_, err = newLoadBalancer(&client{}, providerconfig.ClustersFeatures{
+		LoadBalancer: providerconfig.LoadBalancerOpts{
+			Pools: []providerconfig.LoadBalancerPool{{Name: "test", Region: "cluster-1", Addresses: "10.0.0.10-10.0.0.1"}},
+		},
+	})
+	assert.NotNil(t, err)
+}
+
+func TestIPPool(t *testing.T) {
+	pool, err := newIPPool(providerconfig.LoadBalancerPool{Name: "test", Addresses: "10.0.0.0/30, 10.0.1.10-10.0.1.11, fd00::/127"})
+	assert.Nil(t, err)
+
+	assert.False(t, pool.Contains(netip.MustParseAddr("10.0.0.0")))
+	assert.True(t, pool.Contains(netip.MustParseAddr("10.0.0.2")))
+	assert.False(t, pool.Contains(netip.MustParseAddr("10.0.0.3")))
+	assert.True(t, pool.Contains(netip.MustParseAddr("10.0.1.11")))
+	assert.True(t, pool.Contains(netip.MustParseAddr("fd00::1")))
+
+	used := map[netip.Addr]bool{
+		netip.MustParseAddr("10.0.0.1"): true,
+		netip.MustParseAddr("10.0.0.2"): true,
+	}
+
+	addr, err := pool.Allocate(used, false)
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.1.10", addr.String())
+
+	addr, err = pool.Allocate(used, true)
+	assert.Nil(t, err)
+	assert.Equal(t, "fd00::", addr.String())
+
+	used[netip.MustParseAddr("10.0.1.10")] = true
+	used[netip.MustParseAddr("10.0.1.11")] = true
+
+	_, err = pool.Allocate(used, false)
+	assert.NotNil(t, err)
+}
+
+func TestEnsureLoadBalancer(t *testing.T) {
+	other := newTestService("other", map[string]string{AnnotationLoadBalancerIPs: "10.0.1.1"})
+	svc := newTestService("test", nil)
+
+	lb := newTestLoadBalancer(t, other, svc)
+
+	nodes := []*v1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: map[string]string{
+			LabelTopologyRegion: "cluster-1",
+			LabelTopologyZone:   "pve-2",
+		}}},
+	}
+
+	status, err := lb.EnsureLoadBalancer(t.Context(), "kubernetes", svc, nodes)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(status.Ingress))
+	assert.Equal(t, "10.0.1.2", status.Ingress[0].IP)
+
+	stored, err := lb.c.kclient.CoreV1().Services("default").Get(t.Context(), "test", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "default", stored.Annotations[AnnotationLoadBalancerPool])
+	assert.Equal(t, "10.0.1.2", stored.Annotations[AnnotationLoadBalancerIPs])
+
+	// Allocation is stable
+	status, err = lb.EnsureLoadBalancer(t.Context(), "kubernetes", stored, nodes)
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.1.2", status.Ingress[0].IP)
+
+	status, exists, err := lb.GetLoadBalancer(t.Context(), "kubernetes", stored)
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "10.0.1.2", status.Ingress[0].IP)
+
+	err = lb.EnsureLoadBalancerDeleted(t.Context(), "kubernetes", stored)
+	assert.Nil(t, err)
+
+	stored, err = lb.c.kclient.CoreV1().Services("default").Get(t.Context(), "test", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.NotContains(t, stored.Annotations, AnnotationLoadBalancerIPs)
+	assert.NotContains(t, stored.Annotations, AnnotationLoadBalancerPool)
+
+	_, exists, err = lb.GetLoadBalancer(t.Context(), "kubernetes", stored)
+	assert.Nil(t, err)
+	assert.False(t, exists)
+}
+
+func TestEnsureLoadBalancerPoolSelection(t *testing.T) {
+	zoned := newTestService("zoned", nil)
+	dualstack := newTestService("dualstack", map[string]string{AnnotationLoadBalancerPool: "default"})
+	dualstack.Spec.IPFamilies = []v1.IPFamily{v1.IPv6Protocol, v1.IPv4Protocol}
+	unknown := newTestService("unknown", map[string]string{AnnotationLoadBalancerPool: "unknown"})
+	requested := newTestService("requested", nil)
+	requested.Spec.LoadBalancerIP = "10.0.0.2" //nolint:staticcheck
+
+	lb := newTestLoadBalancer(t, zoned, dualstack, unknown, requested)
+
+	nodes := []*v1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: map[string]string{
+			v1.LabelTopologyRegion: "cluster-1",
+			v1.LabelTopologyZone:   "pve-1",
+		}}},
+	}
+
+	status, err := lb.EnsureLoadBalancer(t.Context(), "kubernetes", zoned, nodes)
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.0.1", status.Ingress[0].IP)
+
+	status, err = lb.EnsureLoadBalancer(t.Context(), "kubernetes", dualstack, nodes)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(status.Ingress))
+	assert.Equal(t, "fd00::", status.Ingress[0].IP)
+	assert.Equal(t, "10.0.1.1", status.Ingress[1].IP)
+
+	_, err = lb.EnsureLoadBalancer(t.Context(), "kubernetes", unknown, nodes)
+	assert.NotNil(t, err)
+
+	status, err = lb.EnsureLoadBalancer(t.Context(), "kubernetes", requested, nodes)
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.0.2", status.Ingress[0].IP)
+
+	// Pool is exhausted
+	_, err = lb.EnsureLoadBalancer(t.Context(), "kubernetes", newTestService("exhausted", nil), nodes)
+	assert.NotNil(t, err)
+
+	// The requested address is changed
+	stored, err := lb.c.kclient.CoreV1().Services("default").Get(t.Context(), "requested", metav1.GetOptions{})
+	assert.Nil(t, err)
+
+	stored.Spec.LoadBalancerIP = "10.0.0.1" //nolint:staticcheck
+
+	_, err = lb.EnsureLoadBalancer(t.Context(), "kubernetes", stored, nodes)
+	assert.NotNil(t, err)
+
+	stored.Spec.LoadBalancerIP = "10.0.1.2" //nolint:staticcheck
+	stored.Annotations[AnnotationLoadBalancerPool] = "default"
+
+	status, err = lb.EnsureLoadBalancer(t.Context(), "kubernetes", stored, nodes)
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.1.2", status.Ingress[0].IP)
+
+	stored, err = lb.c.kclient.CoreV1().Services("default").Get(t.Context(), "requested", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.1.2", stored.Annotations[AnnotationLoadBalancerIPs])
+
+	// No pool for the region
+	_, err = lb.EnsureLoadBalancer(t.Context(), "kubernetes", newTestService("foreign", nil), []*v1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "node-2", Labels: map[string]string{LabelTopologyRegion: "cluster-2"}}},
+	})
+	assert.NotNil(t, err)
+
+	// No nodes to match the region
+	_, err = lb.EnsureLoadBalancer(t.Context(), "kubernetes", newTestService("nodeless", nil), nil)
+	assert.NotNil(t, err)
+}
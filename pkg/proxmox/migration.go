@@ -0,0 +1,306 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	proxmox "github.com/luthermonson/go-proxmox"
+
+	metrics "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/metrics"
+	provider "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/provider"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/cloud-provider/app"
+	cloudcontrollerconfig "k8s.io/cloud-provider/app/config"
+	genericcontrollermanager "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// NodeMigrationControllerName is the name of the node migration controller.
+	NodeMigrationControllerName = "node-migration"
+	// NodeMigrationControllerClientName is the client name of the node migration controller.
+	NodeMigrationControllerClientName = "node-migration-controller"
+
+	nodeMigrationSyncPeriod = 30 * time.Second
+
+	// placementMaxAge is the maximum age of the VM inventory, which the VM placement controllers use.
+	placementMaxAge = 10 * time.Second
+)
+
+// nodeMigration follows the VM migrations between the Proxmox nodes,
+// and updates the topology labels of the Kubernetes nodes.
+type nodeMigration struct {
+	i *instances
+
+	nodeLister  corelisters.NodeLister
+	nodesSynced cache.InformerSynced
+
+	mu sync.Mutex
+	// hosts is the last known Proxmox node of the Kubernetes node VM.
+	hosts map[string]string
+}
+
+func newNodeMigration(i *instances) *nodeMigration {
+	return &nodeMigration{
+		i:     i,
+		hosts: map[string]string{},
+	}
+}
+
+// StartNodeMigrationControllerWrapper is used to take cloud config as input and start the node migration controller.
+func StartNodeMigrationControllerWrapper(_ app.ControllerInitContext, completedConfig *cloudcontrollerconfig.CompletedConfig, ccm cloudprovider.Interface) app.InitFunc {
+	return func(ctx context.Context, _ genericcontrollermanager.ControllerContext) (controller.Interface, bool, error) {
+		c, ok := ccm.(*cloud)
+		if !ok || c.nodeMigration == nil {
+			return nil, false, nil
+		}
+
+		c.nodeMigration.setInformer(completedConfig.SharedInformers.Core().V1().Nodes())
+
+		go c.nodeMigration.Run(ctx)
+
+		return nil, true, nil
+	}
+}
+
+func (n *nodeMigration) setInformer(informer coreinformers.NodeInformer) {
+	n.nodeLister = informer.Lister()
+	n.nodesSynced = informer.Informer().HasSynced
+}
+
+// Run starts the node migration controller, it blocks until the context is done.
+func (n *nodeMigration) Run(ctx context.Context) {
+	defer utilruntime.HandleCrash()
+
+	klog.InfoS("starting node-migration controller")
+	defer klog.InfoS("shutting down node-migration controller")
+
+	if !cache.WaitForNamedCacheSync(NodeMigrationControllerName, ctx.Done(), n.nodesSynced) {
+		return
+	}
+
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := n.sync(ctx); err != nil {
+			klog.ErrorS(err, "node-migration failed to sync nodes")
+		}
+	}, nodeMigrationSyncPeriod)
+}
+
+// sync compares the Proxmox node of each VM with the last known one and the node labels.
+func (n *nodeMigration) sync(ctx context.Context) error {
+	nodes, err := n.nodeLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	n.pruneHosts(nodes)
+
+	byRegion := map[string][]*v1.Node{}
+
+	for _, node := range nodes {
+		if region := getNodeRegion(node); region != "" && !hasUninitializedTaint(node) {
+			byRegion[region] = append(byRegion[region], node)
+		}
+	}
+
+	for region, nodes := range byRegion {
+		if err := n.syncRegion(ctx, region, nodes); err != nil {
+			klog.ErrorS(err, "node-migration failed to sync region", "region", region)
+		}
+	}
+
+	return nil
+}
+
+func (n *nodeMigration) syncRegion(ctx context.Context, region string, nodes []*v1.Node) error {
+	mc := metrics.NewMetricContext("getMigrationTasks")
+
+	tasks, err := n.i.c.pxpool.GetMigrationTasks(ctx, region)
+	if mc.ObserveRequest(err) != nil {
+		return err
+	}
+
+	// The VM placement must be recent, the controllers syncing at the same time share one inventory refresh
+	vms, err := n.i.c.pxpool.GetRecentInventoryVMs(ctx, region, placementMaxAge)
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[int]*proxmox.ClusterResource, len(vms))
+	for _, vm := range vms {
+		byID[int(vm.Resource.VMID)] = vm.Resource
+	}
+
+	for _, node := range nodes {
+		vmID, ok := getNodeVMID(node)
+		if !ok {
+			continue
+		}
+
+		rs := byID[vmID]
+		if rs == nil || rs.Status == "unknown" {
+			continue
+		}
+
+		// The migration is in progress, the VM placement is not final
+		if migrationInProgress(tasks, vmID) {
+			klog.V(4).InfoS("node-migration VM migration is in progress", "node", klog.KObj(node), "region", region, "vmID", vmID)
+
+			continue
+		}
+
+		if err := n.syncNode(ctx, node, region, rs); err != nil {
+			klog.ErrorS(err, "node-migration failed to sync node", "node", klog.KObj(node), "region", region, "vmID", vmID)
+		}
+	}
+
+	return nil
+}
+
+// pruneHosts removes the last known Proxmox nodes of the deleted Kubernetes nodes.
+func (n *nodeMigration) pruneHosts(nodes []*v1.Node) {
+	names := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		names[node.Name] = true
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for name := range n.hosts {
+		if !names[name] {
+			delete(n.hosts, name)
+		}
+	}
+}
+
+// syncNode updates the node labels, and records the migration event if the VM left its last known Proxmox node.
+// The first sync of the node after the CCM start only records the placement, the migrations before it are unknown.
+func (n *nodeMigration) syncNode(ctx context.Context, node *v1.Node, region string, rs *proxmox.ClusterResource) error {
+	n.mu.Lock()
+	source := n.hosts[node.Name]
+	n.mu.Unlock()
+
+	zone, haGroups, err := n.i.getInstanceZone(ctx, &instanceInfo{
+		ID:     int(rs.VMID),
+		Node:   rs.Node,
+		Region: region,
+		Zone:   rs.Node,
+		Pool:   rs.Pool,
+	})
+	if err != nil {
+		return err
+	}
+
+	nodeLabels := map[string]string{
+		LabelTopologyRegion: region,
+		LabelTopologyZone:   zone,
+	}
+
+	for _, g := range haGroups {
+		nodeLabels[LabelTopologyHAGroupPrefix+g.Name] = strconv.Itoa(g.Priority)
+	}
+
+	if n.i.updateLabels {
+		nodeLabels[v1.LabelTopologyZone] = zone
+		nodeLabels[v1.LabelFailureDomainBetaZone] = zone
+		nodeLabels[v1.LabelTopologyRegion] = region
+		nodeLabels[v1.LabelFailureDomainBetaRegion] = region
+	}
+
+	patch := map[string]any{}
+
+	for k, v := range nodeLabels {
+		if r, ok := node.Labels[k]; !ok || r != v {
+			patch[k] = v
+		}
+	}
+
+	for k := range node.Labels {
+		if _, ok := nodeLabels[k]; !ok && strings.HasPrefix(k, LabelTopologyHAGroupPrefix) {
+			patch[k] = nil
+		}
+	}
+
+	if len(patch) > 0 {
+		data, err := json.Marshal(map[string]any{"metadata": map[string]any{"labels": patch}})
+		if err != nil {
+			return fmt.Errorf("failed to marshal the patch: %w", err)
+		}
+
+		if _, err := n.i.c.kclient.CoreV1().Nodes().Patch(ctx, node.Name, types.MergePatchType, data, metav1.PatchOptions{}); err != nil {
+			return fmt.Errorf("failed to patch node %s: %w", node.Name, err)
+		}
+
+		klog.InfoS("node-migration updated node labels", "node", klog.KObj(node), "region", region, "zone", zone, "labels", patch)
+	}
+
+	// The placement is recorded only after the node is updated, the failed update is retried on the next sync
+	n.mu.Lock()
+	n.hosts[node.Name] = rs.Node
+	n.mu.Unlock()
+
+	if source != "" && source != rs.Node {
+		klog.InfoS("node-migration VM migrated", "node", klog.KObj(node), "region", region, "vmID", rs.VMID, "source", source, "target", rs.Node)
+
+		if n.i.c.recorder != nil {
+			n.i.c.recorder.Eventf(node, v1.EventTypeNormal, EventReasonNodeMigrated,
+				"VM %d in region %s migrated from Proxmox node %s to %s", rs.VMID, region, source, rs.Node)
+		}
+	}
+
+	return nil
+}
+
+// getNodeVMID returns the VM ID of the node from the providerID or the instance ID annotation.
+func getNodeVMID(node *v1.Node) (int, bool) {
+	if vmID, _, err := provider.ParseProviderID(node.Spec.ProviderID); err == nil {
+		return vmID, true
+	}
+
+	if vmID, err := strconv.Atoi(node.Annotations[AnnotationProxmoxInstanceID]); err == nil {
+		return vmID, true
+	}
+
+	return 0, false
+}
+
+// migrationInProgress returns true if a migration of the VM is in progress.
+func migrationInProgress(tasks proxmox.Tasks, vmID int) bool {
+	return slices.ContainsFunc(tasks, func(t *proxmox.Task) bool {
+		return t.ID == strconv.Itoa(vmID) && t.EndTime.IsZero()
+	})
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmox
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	proxmox "github.com/luthermonson/go-proxmox"
+	"github.com/stretchr/testify/assert"
+
+	providerconfig "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/config"
+	"github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/proxmoxpool"
+	testcluster "github.com/sergelogvinov/proxmox-cloud-controller-manager/test/cluster"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+)
+
+func newTestNodeMigration(t *testing.T, features providerconfig.ClustersFeatures, nodes ...*v1.Node) (*nodeMigration, *record.FakeRecorder) {
+	t.Helper()
+
+	cfg, err := providerconfig.ReadCloudConfigFromFile("../../test/config/cluster-config-1.yaml")
+	assert.Nil(t, err)
+
+	px, err := proxmoxpool.NewProxmoxPool(cfg.Clusters)
+	assert.Nil(t, err)
+
+	kclient := fake.NewClientset()
+	informer := informers.NewSharedInformerFactory(kclient, 0).Core().V1().Nodes()
+
+	for _, node := range nodes {
+		_, err := kclient.CoreV1().Nodes().Create(t.Context(), node, metav1.CreateOptions{})
+		assert.Nil(t, err)
+		assert.Nil(t, informer.Informer().GetIndexer().Add(node))
+	}
+
+	recorder := record.NewFakeRecorder(10)
+
+	n := newNodeMigration(newInstances(&client{pxpool: px, kclient: kclient, recorder: recorder}, features))
+	n.setInformer(informer)
+
+	return n, recorder
+}
+
+func TestMigrationInProgress(t *testing.T) {
+	t.Parallel()
+
+	tasks := proxmox.Tasks{
+		{ID: "100", Type: "qmigrate", Node: "pve-1", Status: "OK", EndTime: time.Now()},
+		{ID: "101", Type: "qmigrate", Node: "pve-2"},
+	}
+
+	assert.False(t, migrationInProgress(tasks, 100))
+	assert.True(t, migrationInProgress(tasks, 101))
+	assert.False(t, migrationInProgress(tasks, 102))
+}
+
+func TestNodeMigrationSync(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	httpmock.RegisterResponder(http.MethodGet, "https://127.0.0.1:8006/api2/json/cluster/tasks",
+		httpmock.NewJsonResponderOrPanic(200, map[string]any{
+			"data": []any{
+				map[string]any{"id": "101", "type": "qmigrate", "node": "pve-1", "status": "OK", "starttime": 1700000000, "endtime": 1700000060},
+				map[string]any{"id": "100", "type": "qmigrate", "node": "pve-1", "starttime": 1700000000},
+			},
+		}))
+
+	n, recorder := newTestNodeMigration(t, providerconfig.ClustersFeatures{},
+		&v1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "cluster-1-node-1",
+				Labels: map[string]string{
+					LabelTopologyRegion: "cluster-1",
+					LabelTopologyZone:   "pve-4",
+				},
+			},
+			Spec: v1.NodeSpec{ProviderID: "proxmox://cluster-1/100"},
+		},
+		&v1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "cluster-1-node-2",
+				Labels: map[string]string{
+					LabelTopologyRegion:                "cluster-1",
+					LabelTopologyZone:                  "pve-1",
+					LabelTopologyHAGroupPrefix + "rnd": "",
+					LabelTopologyHAGroupPrefix + "dev": "",
+				},
+			},
+			Spec: v1.NodeSpec{ProviderID: "proxmox://cluster-1/101"},
+		},
+	)
+
+	assert.Nil(t, n.sync(t.Context()))
+
+	node, err := n.i.c.kclient.CoreV1().Nodes().Get(t.Context(), "cluster-1-node-2", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		LabelTopologyRegion:                "cluster-1",
+		LabelTopologyZone:                  "pve-2",
+		LabelTopologyHAGroupPrefix + "rnd": "0",
+	}, node.Labels)
+
+	// The first sync only records the placement, the migrations before the start are unknown
+	assert.Len(t, recorder.Events, 0)
+	assert.Equal(t, map[string]string{"cluster-1-node-2": "pve-2"}, n.hosts)
+
+	// The migration of VM 100 is in progress
+	node, err = n.i.c.kclient.CoreV1().Nodes().Get(t.Context(), "cluster-1-node-1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "pve-4", node.Labels[LabelTopologyZone])
+
+	// The placement is known, the next sync does not record the same migration
+	assert.Nil(t, n.sync(t.Context()))
+	assert.Len(t, recorder.Events, 0)
+
+	// The VM left its last known Proxmox node
+	n.hosts["cluster-1-node-2"] = "pve-1"
+
+	assert.Nil(t, n.sync(t.Context()))
+	assert.Len(t, recorder.Events, 1)
+	assert.Equal(t, "Normal ProxmoxMigrated VM 101 in region cluster-1 migrated from Proxmox node pve-1 to pve-2", <-recorder.Events)
+}
+
+func TestNodeMigrationSyncPoolZone(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	httpmock.RegisterResponder(http.MethodGet, "https://127.0.0.1:8006/api2/json/cluster/tasks",
+		httpmock.NewJsonResponderOrPanic(200, map[string]any{"data": []any{}}))
+
+	n, _ := newTestNodeMigration(t, providerconfig.ClustersFeatures{Zone: providerconfig.ZoneOpts{Strategy: providerconfig.ZoneStrategyPool}},
+		&v1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "cluster-1-node-1",
+				Labels: map[string]string{
+					LabelTopologyRegion: "cluster-1",
+					LabelTopologyZone:   "team-b",
+				},
+			},
+			Spec: v1.NodeSpec{ProviderID: "proxmox://cluster-1/100"},
+		},
+	)
+
+	assert.Nil(t, n.sync(t.Context()))
+
+	node, err := n.i.c.kclient.CoreV1().Nodes().Get(t.Context(), "cluster-1-node-1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "team-a", node.Labels[LabelTopologyZone])
+}
+
+func TestNodeMigrationHosts(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	httpmock.RegisterResponder(http.MethodGet, "https://127.0.0.1:8006/api2/json/cluster/tasks",
+		httpmock.NewJsonResponderOrPanic(200, map[string]any{"data": []any{}}))
+
+	n, _ := newTestNodeMigration(t, providerconfig.ClustersFeatures{},
+		&v1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "cluster-1-node-2",
+				Labels: map[string]string{
+					LabelTopologyRegion: "cluster-1",
+					LabelTopologyZone:   "pve-1",
+				},
+			},
+			Spec: v1.NodeSpec{ProviderID: "proxmox://cluster-1/101"},
+		},
+	)
+
+	// The Proxmox node of the deleted Kubernetes node is pruned
+	n.hosts["cluster-1-node-9"] = "pve-1"
+
+	// The placement is not recorded if the node update fails
+	kclient, ok := n.i.c.kclient.(*fake.Clientset)
+	assert.True(t, ok)
+
+	kclient.PrependReactor("patch", "nodes", func(_ clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("patch failed")
+	})
+
+	assert.Nil(t, n.sync(t.Context()))
+	assert.Empty(t, n.hosts)
+
+	kclient.ReactionChain = kclient.ReactionChain[1:]
+
+	assert.Nil(t, n.sync(t.Context()))
+	assert.Equal(t, map[string]string{"cluster-1-node-2": "pve-2"}, n.hosts)
+}
@@ -0,0 +1,346 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	proxmox "github.com/luthermonson/go-proxmox"
+
+	providerconfig "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/config"
+	metrics "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/metrics"
+	"github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/proxmoxpool"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/cloud-provider/app"
+	cloudcontrollerconfig "k8s.io/cloud-provider/app/config"
+	genericcontrollermanager "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// NodeGCControllerName is the name of the node garbage collector controller.
+	NodeGCControllerName = "node-gc"
+	// NodeGCControllerClientName is the client name of the node garbage collector controller.
+	NodeGCControllerClientName = "node-gc-controller"
+
+	nodeGCSyncPeriod = 15 * time.Second
+
+	nodeGCResultSuccess   = "success"
+	nodeGCResultError     = "error"
+	nodeGCResultDryRun    = "dry-run"
+	nodeGCResultProtected = "protected"
+)
+
+// nodeGC stops or deletes the VMs of the deleted Kubernetes nodes.
+// The opted-in nodes get the finalizer, it is removed after the action on the VM.
+type nodeGC struct {
+	c *client
+
+	action      providerconfig.NodeGCAction
+	selector    labels.Selector
+	gracePeriod time.Duration
+	dryRun      bool
+
+	nodeLister  corelisters.NodeLister
+	nodesSynced cache.InformerSynced
+}
+
+func newNodeGC(client *client, features providerconfig.ClustersFeatures) *nodeGC {
+	selector, err := labels.Parse(features.NodeGC.Selector)
+	if err != nil {
+		klog.ErrorS(err, "Failed to parse the node gc selector", "selector", features.NodeGC.Selector)
+
+		selector = labels.Nothing()
+	}
+
+	return &nodeGC{
+		c:           client,
+		action:      features.NodeGC.Action,
+		selector:    selector,
+		gracePeriod: features.NodeGC.GracePeriod,
+		dryRun:      features.NodeGC.DryRun,
+	}
+}
+
+// StartNodeGCControllerWrapper is used to take cloud config as input and start the node garbage collector controller.
+func StartNodeGCControllerWrapper(_ app.ControllerInitContext, completedConfig *cloudcontrollerconfig.CompletedConfig, ccm cloudprovider.Interface) app.InitFunc {
+	return func(ctx context.Context, _ genericcontrollermanager.ControllerContext) (controller.Interface, bool, error) {
+		c, ok := ccm.(*cloud)
+		if !ok || c.nodeGC == nil {
+			return nil, false, nil
+		}
+
+		c.nodeGC.setInformer(completedConfig.SharedInformers.Core().V1().Nodes())
+
+		go c.nodeGC.Run(ctx)
+
+		return nil, true, nil
+	}
+}
+
+func (g *nodeGC) setInformer(informer coreinformers.NodeInformer) {
+	g.nodeLister = informer.Lister()
+	g.nodesSynced = informer.Informer().HasSynced
+}
+
+// Run starts the node garbage collector controller, it blocks until the context is done.
+func (g *nodeGC) Run(ctx context.Context) {
+	defer utilruntime.HandleCrash()
+
+	klog.InfoS("starting node-gc controller", "action", g.action, "selector", g.selector, "gracePeriod", g.gracePeriod, "dryRun", g.dryRun)
+	defer klog.InfoS("shutting down node-gc controller")
+
+	if !cache.WaitForNamedCacheSync(NodeGCControllerName, ctx.Done(), g.nodesSynced) {
+		return
+	}
+
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := g.sync(ctx); err != nil {
+			klog.ErrorS(err, "node-gc failed to sync nodes")
+		}
+	}, nodeGCSyncPeriod)
+}
+
+// nodeAction returns the action on the VM of the node, and false if the node is not opted in.
+// The node annotation has the priority over the label selector.
+func (g *nodeGC) nodeAction(node *v1.Node) (providerconfig.NodeGCAction, bool) {
+	if value, ok := node.Annotations[AnnotationDeleteOnNodeRemoval]; ok {
+		switch action := providerconfig.NodeGCAction(value); {
+		case value == "true":
+			return g.action, true
+		case slices.Contains(providerconfig.ValidNodeGCActions, action):
+			return action, true
+		default:
+			return "", false
+		}
+	}
+
+	if !g.selector.Empty() && g.selector.Matches(labels.Set(node.Labels)) {
+		return g.action, true
+	}
+
+	return "", false
+}
+
+// sync adds the finalizer to the opted-in nodes, and collects the VMs of the deleted nodes.
+func (g *nodeGC) sync(ctx context.Context) error {
+	nodes, err := g.nodeLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	for _, node := range nodes {
+		if err := g.syncNode(ctx, node); err != nil {
+			klog.ErrorS(err, "node-gc failed to sync node", "node", klog.KObj(node))
+		}
+	}
+
+	return nil
+}
+
+func (g *nodeGC) syncNode(ctx context.Context, node *v1.Node) error {
+	action, enabled := g.nodeAction(node)
+	hasFinalizer := slices.Contains(node.Finalizers, FinalizerNodeGC)
+
+	switch {
+	case node.DeletionTimestamp == nil && enabled && !hasFinalizer:
+		return g.patchFinalizers(ctx, node, append(slices.Clone(node.Finalizers), FinalizerNodeGC))
+	case !hasFinalizer:
+		return nil
+	case node.DeletionTimestamp == nil && enabled:
+		return nil
+	case node.DeletionTimestamp == nil || !enabled:
+		return g.removeFinalizer(ctx, node)
+	}
+
+	if time.Since(node.DeletionTimestamp.Time) < g.gracePeriod {
+		return nil
+	}
+
+	done, err := g.collect(ctx, node, action)
+	if err != nil {
+		g.c.nodeWarningf(node, EventReasonVMGCFailed, getNodeRegion(node), 0, "Failed to %s the VM: %v", action, err)
+
+		return err
+	}
+
+	if done {
+		return g.removeFinalizer(ctx, node)
+	}
+
+	return nil
+}
+
+// collect stops or deletes the VM of the deleted node, it returns true when the finalizer can be removed.
+// The delete action stops the VM first, and deletes it on the next sync when the VM is stopped.
+func (g *nodeGC) collect(ctx context.Context, node *v1.Node, action providerconfig.NodeGCAction) (bool, error) {
+	region := getNodeRegion(node)
+
+	vmID, ok := getNodeVMID(node)
+	if !ok || region == "" {
+		klog.InfoS("node-gc cannot find the VM of the node, skipping", "node", klog.KObj(node))
+
+		return true, nil
+	}
+
+	// The VM placement must be recent, the VM could be removed or its ID reused
+	vms, err := g.c.pxpool.GetRecentInventoryVMs(ctx, region, placementMaxAge)
+	if err != nil {
+		return false, err
+	}
+
+	idx := slices.IndexFunc(vms, func(vm *proxmoxpool.InventoryVM) bool {
+		return int(vm.Resource.VMID) == vmID
+	})
+	if idx < 0 {
+		klog.V(4).InfoS("node-gc VM does not exist anymore", "node", klog.KObj(node), "region", region, "vmID", vmID)
+
+		return true, nil
+	}
+
+	rs := vms[idx].Resource
+
+	// The VM ID could be reused by another VM
+	if !g.c.pxpool.MatchNodeName(rs.Name, node.Name) || !g.c.pxpool.HasClusterTag(rs) {
+		g.c.nodeEventf(node, v1.EventTypeWarning, EventReasonVMGCSkipped, region, vmID, "VM %s does not belong to the node, skipping", rs.Name)
+
+		return true, nil
+	}
+
+	mc := metrics.NewMetricContext("getGuestProtection")
+
+	protected, err := g.c.pxpool.GetGuestProtection(ctx, region, rs)
+	if mc.ObserveRequest(err) != nil {
+		return false, err
+	}
+
+	if protected {
+		metrics.NodeGCAction(string(action), nodeGCResultProtected)
+		g.c.nodeWarningf(node, EventReasonVMProtected, region, vmID, "VM has the protection flag, it is not changed")
+
+		return true, nil
+	}
+
+	if g.dryRun {
+		metrics.NodeGCAction(string(action), nodeGCResultDryRun)
+		g.c.nodeEventf(node, v1.EventTypeNormal, EventReasonVMGCDryRun, region, vmID, "Dry run, VM would be %s", nodeGCActionPast(action))
+
+		return true, nil
+	}
+
+	status, err := g.c.pxpool.GetGuestStatus(ctx, region, rs)
+	if err != nil {
+		return false, err
+	}
+
+	if status.Status != "stopped" {
+		if err := g.do(ctx, node, region, rs, providerconfig.NodeGCActionStop); err != nil {
+			return false, err
+		}
+
+		return action == providerconfig.NodeGCActionStop, nil
+	}
+
+	if action == providerconfig.NodeGCActionDelete {
+		if err := g.do(ctx, node, region, rs, providerconfig.NodeGCActionDelete); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// do stops or deletes the VM, and records the event and the metric.
+func (g *nodeGC) do(ctx context.Context, node *v1.Node, region string, rs *proxmox.ClusterResource, action providerconfig.NodeGCAction) error {
+	var (
+		err    error
+		reason string
+	)
+
+	mc := metrics.NewMetricContext(string(action) + "Guest")
+
+	switch action {
+	case providerconfig.NodeGCActionDelete:
+		reason = EventReasonVMDeleted
+		err = g.c.pxpool.DeleteGuestInRegion(ctx, region, rs)
+	default:
+		reason = EventReasonVMStopped
+		err = g.c.pxpool.StopGuestInRegion(ctx, region, rs)
+	}
+
+	if mc.ObserveRequest(err) != nil {
+		metrics.NodeGCAction(string(action), nodeGCResultError)
+
+		return err
+	}
+
+	metrics.NodeGCAction(string(action), nodeGCResultSuccess)
+
+	klog.InfoS("node-gc VM of the deleted node", "action", action, "node", klog.KObj(node), "region", region, "vmID", rs.VMID)
+
+	g.c.nodeEventf(node, v1.EventTypeNormal, reason, region, int(rs.VMID), "VM was %s", nodeGCActionPast(action)) //nolint: gosec
+
+	return nil
+}
+
+func (g *nodeGC) removeFinalizer(ctx context.Context, node *v1.Node) error {
+	return g.patchFinalizers(ctx, node, slices.DeleteFunc(slices.Clone(node.Finalizers), func(f string) bool {
+		return f == FinalizerNodeGC
+	}))
+}
+
+// patchFinalizers replaces the node finalizers, the resource version protects from the concurrent changes.
+func (g *nodeGC) patchFinalizers(ctx context.Context, node *v1.Node, finalizers []string) error {
+	data, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"finalizers":      finalizers,
+			"resourceVersion": node.ResourceVersion,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal the patch: %w", err)
+	}
+
+	if _, err := g.c.kclient.CoreV1().Nodes().Patch(ctx, node.Name, types.MergePatchType, data, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("failed to patch node %s: %w", node.Name, err)
+	}
+
+	return nil
+}
+
+func nodeGCActionPast(action providerconfig.NodeGCAction) string {
+	if action == providerconfig.NodeGCActionDelete {
+		return "deleted"
+	}
+
+	return "stopped"
+}
@@ -0,0 +1,290 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmox
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+
+	providerconfig "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/config"
+	"github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/proxmoxpool"
+	testcluster "github.com/sergelogvinov/proxmox-cloud-controller-manager/test/cluster"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestNodeGCNodeAction(t *testing.T) {
+	t.Parallel()
+
+	g := newNodeGC(&client{}, providerconfig.ClustersFeatures{
+		NodeGC: providerconfig.NodeGCOpts{Action: providerconfig.NodeGCActionStop, Selector: "pool=ephemeral"},
+	})
+
+	tests := []struct {
+		msg         string
+		annotations map[string]string
+		labels      map[string]string
+		expected    providerconfig.NodeGCAction
+		enabled     bool
+	}{
+		{msg: "NotOptedIn"},
+		{msg: "Annotation", annotations: map[string]string{AnnotationDeleteOnNodeRemoval: "true"}, expected: "stop", enabled: true},
+		{msg: "AnnotationAction", annotations: map[string]string{AnnotationDeleteOnNodeRemoval: "delete"}, expected: "delete", enabled: true},
+		{msg: "AnnotationInvalid", annotations: map[string]string{AnnotationDeleteOnNodeRemoval: "yes"}},
+		{msg: "Selector", labels: map[string]string{"pool": "ephemeral"}, expected: "stop", enabled: true},
+		{msg: "SelectorOptOut", annotations: map[string]string{AnnotationDeleteOnNodeRemoval: "false"}, labels: map[string]string{"pool": "ephemeral"}},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.msg, func(t *testing.T) {
+			t.Parallel()
+
+			action, enabled := g.nodeAction(&v1.Node{ObjectMeta: metav1.ObjectMeta{Annotations: testCase.annotations, Labels: testCase.labels}})
+			assert.Equal(t, testCase.expected, action)
+			assert.Equal(t, testCase.enabled, enabled)
+		})
+	}
+}
+
+func TestNodeGCSyncNode(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	httpmock.RegisterResponder(http.MethodPost, `=~/nodes/pve-1/qemu/100/status/stop`,
+		httpmock.NewJsonResponderOrPanic(200, map[string]any{"data": "UPID:pve-1:00000001:00000001:00000001:qmstop:100:root@pam:"}))
+	httpmock.RegisterResponder(http.MethodGet, `=~/nodes/pve-2/qemu/101/status/current`,
+		httpmock.NewJsonResponderOrPanic(200, map[string]any{"data": map[string]any{"vmid": 101, "status": "stopped"}}))
+	httpmock.RegisterResponder(http.MethodDelete, `=~/nodes/pve-2/qemu/101`,
+		httpmock.NewJsonResponderOrPanic(200, map[string]any{"data": "UPID:pve-2:00000001:00000001:00000001:qmdestroy:101:root@pam:"}))
+
+	cfg, err := providerconfig.ReadCloudConfigFromFile("../../test/config/cluster-config-1.yaml")
+	assert.Nil(t, err)
+
+	px, err := proxmoxpool.NewProxmoxPool(cfg.Clusters)
+	assert.Nil(t, err)
+
+	deleted := metav1.NewTime(time.Now().Add(-time.Hour))
+
+	tests := []struct {
+		msg               string
+		opts              providerconfig.NodeGCOpts
+		node              *v1.Node
+		expectedFinalizer bool
+		expectedEvent     string
+		expectedCalls     map[string]int
+	}{
+		{
+			msg: "AddFinalizer",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "cluster-1-node-1",
+					Annotations: map[string]string{AnnotationDeleteOnNodeRemoval: "true"},
+				},
+				Spec: v1.NodeSpec{ProviderID: "proxmox://cluster-1/100"},
+			},
+			expectedFinalizer: true,
+		},
+		{
+			msg: "RemoveFinalizer",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "cluster-1-node-1",
+					Finalizers: []string{FinalizerNodeGC},
+				},
+				Spec: v1.NodeSpec{ProviderID: "proxmox://cluster-1/100"},
+			},
+		},
+		{
+			msg:  "GracePeriod",
+			opts: providerconfig.NodeGCOpts{GracePeriod: 2 * time.Hour},
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "cluster-1-node-1",
+					Annotations:       map[string]string{AnnotationDeleteOnNodeRemoval: "true"},
+					Finalizers:        []string{FinalizerNodeGC},
+					DeletionTimestamp: &deleted,
+				},
+				Spec: v1.NodeSpec{ProviderID: "proxmox://cluster-1/100"},
+			},
+			expectedFinalizer: true,
+		},
+		{
+			msg:  "DryRun",
+			opts: providerconfig.NodeGCOpts{DryRun: true},
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "cluster-1-node-1",
+					Annotations:       map[string]string{AnnotationDeleteOnNodeRemoval: "delete"},
+					Finalizers:        []string{FinalizerNodeGC},
+					DeletionTimestamp: &deleted,
+				},
+				Spec: v1.NodeSpec{ProviderID: "proxmox://cluster-1/100"},
+			},
+			expectedEvent: "Normal ProxmoxVMGCDryRun Dry run, VM would be deleted (region=cluster-1, vmID=100)",
+			expectedCalls: map[string]int{"POST =~/nodes/pve-1/qemu/100/status/stop": 0},
+		},
+		{
+			msg: "Stop",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "cluster-1-node-1",
+					Annotations:       map[string]string{AnnotationDeleteOnNodeRemoval: "true"},
+					Finalizers:        []string{FinalizerNodeGC},
+					DeletionTimestamp: &deleted,
+				},
+				Spec: v1.NodeSpec{ProviderID: "proxmox://cluster-1/100"},
+			},
+			expectedEvent: "Normal ProxmoxVMStopped VM was stopped (region=cluster-1, vmID=100)",
+			expectedCalls: map[string]int{"POST =~/nodes/pve-1/qemu/100/status/stop": 1},
+		},
+		{
+			msg: "DeleteRunning",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "cluster-1-node-1",
+					Annotations:       map[string]string{AnnotationDeleteOnNodeRemoval: "delete"},
+					Finalizers:        []string{FinalizerNodeGC},
+					DeletionTimestamp: &deleted,
+				},
+				Spec: v1.NodeSpec{ProviderID: "proxmox://cluster-1/100"},
+			},
+			expectedFinalizer: true,
+			expectedEvent:     "Normal ProxmoxVMStopped VM was stopped (region=cluster-1, vmID=100)",
+			expectedCalls:     map[string]int{"POST =~/nodes/pve-1/qemu/100/status/stop": 2},
+		},
+		{
+			msg: "Delete",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "cluster-1-node-2",
+					Annotations:       map[string]string{AnnotationDeleteOnNodeRemoval: "delete"},
+					Finalizers:        []string{FinalizerNodeGC},
+					DeletionTimestamp: &deleted,
+				},
+				Spec: v1.NodeSpec{ProviderID: "proxmox://cluster-1/101"},
+			},
+			expectedEvent: "Normal ProxmoxVMDeleted VM was deleted (region=cluster-1, vmID=101)",
+			expectedCalls: map[string]int{"DELETE =~/nodes/pve-2/qemu/101": 1},
+		},
+		{
+			msg: "VMIDReused",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "cluster-1-node-3",
+					Annotations:       map[string]string{AnnotationDeleteOnNodeRemoval: "delete"},
+					Finalizers:        []string{FinalizerNodeGC},
+					DeletionTimestamp: &deleted,
+				},
+				Spec: v1.NodeSpec{ProviderID: "proxmox://cluster-1/101"},
+			},
+			expectedEvent: "Warning ProxmoxVMGCSkipped VM cluster-1-node-2 does not belong to the node, skipping (region=cluster-1, vmID=101)",
+			expectedCalls: map[string]int{"DELETE =~/nodes/pve-2/qemu/101": 1},
+		},
+		{
+			msg: "VMNotFound",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "cluster-1-node-9",
+					Annotations:       map[string]string{AnnotationDeleteOnNodeRemoval: "delete"},
+					Finalizers:        []string{FinalizerNodeGC},
+					DeletionTimestamp: &deleted,
+				},
+				Spec: v1.NodeSpec{ProviderID: "proxmox://cluster-1/199"},
+			},
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.msg, func(t *testing.T) {
+			kclient := fake.NewClientset(testCase.node)
+			recorder := record.NewFakeRecorder(10)
+
+			if testCase.opts.Action == "" {
+				testCase.opts.Action = providerconfig.NodeGCActionStop
+			}
+
+			g := newNodeGC(&client{pxpool: px, kclient: kclient, recorder: recorder}, providerconfig.ClustersFeatures{NodeGC: testCase.opts})
+
+			err := g.syncNode(t.Context(), testCase.node)
+			assert.Nil(t, err)
+
+			node, err := kclient.CoreV1().Nodes().Get(t.Context(), testCase.node.Name, metav1.GetOptions{})
+			assert.Nil(t, err)
+			assert.Equal(t, testCase.expectedFinalizer, len(node.Finalizers) == 1)
+
+			if testCase.expectedEvent != "" {
+				assert.Equal(t, testCase.expectedEvent, <-recorder.Events)
+			}
+
+			assert.Empty(t, recorder.Events)
+
+			calls := httpmock.GetCallCountInfo()
+			for k, v := range testCase.expectedCalls {
+				assert.Equal(t, v, calls[k], k)
+			}
+		})
+	}
+}
+
+func TestNodeGCProtection(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	httpmock.RegisterResponder(http.MethodGet, `=~/nodes/pve-1/qemu/100/config`,
+		httpmock.NewJsonResponderOrPanic(200, map[string]any{"data": map[string]any{"vmid": 100, "protection": 1}}))
+
+	cfg, err := providerconfig.ReadCloudConfigFromFile("../../test/config/cluster-config-1.yaml")
+	assert.Nil(t, err)
+
+	px, err := proxmoxpool.NewProxmoxPool(cfg.Clusters)
+	assert.Nil(t, err)
+
+	deleted := metav1.NewTime(time.Now().Add(-time.Hour))
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "cluster-1-node-1",
+			Annotations:       map[string]string{AnnotationDeleteOnNodeRemoval: "delete"},
+			Finalizers:        []string{FinalizerNodeGC},
+			DeletionTimestamp: &deleted,
+		},
+		Spec: v1.NodeSpec{ProviderID: "proxmox://cluster-1/100"},
+	}
+
+	kclient := fake.NewClientset(node)
+	recorder := record.NewFakeRecorder(10)
+	g := newNodeGC(&client{pxpool: px, kclient: kclient, recorder: recorder}, providerconfig.ClustersFeatures{})
+
+	err = g.syncNode(t.Context(), node)
+	assert.Nil(t, err)
+	assert.Equal(t, "Warning ProxmoxVMProtected VM has the protection flag, it is not changed (region=cluster-1, vmID=100)", <-recorder.Events)
+
+	node, err = kclient.CoreV1().Nodes().Get(t.Context(), node.Name, metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Empty(t, node.Finalizers)
+	assert.Zero(t, httpmock.GetCallCountInfo()["POST =~/nodes/pve-1/qemu/100/status/stop"])
+}
@@ -0,0 +1,585 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+
+	proxmox "github.com/luthermonson/go-proxmox"
+
+	providerconfig "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/config"
+	metrics "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/metrics"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/cloud-provider/app"
+	cloudcontrollerconfig "k8s.io/cloud-provider/app/config"
+	genericcontrollermanager "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// NodeIPAMControllerName is the name of the node IPAM controller.
+	NodeIPAMControllerName = "node-ipam"
+	// NodeIPAMControllerClientName is the client name of the node IPAM controller.
+	NodeIPAMControllerClientName = "node-ipam-controller"
+
+	// nodeIPAMReconcileKey is the queue key of the orphaned block reconciliation, it is not a valid node name.
+	nodeIPAMReconcileKey = "/reconcile"
+	// nodeIPAMReconcilePeriod is the period of the orphaned block reconciliation.
+	nodeIPAMReconcilePeriod = 10 * time.Minute
+)
+
+type nodeIPAMRelease struct {
+	region string
+	cidrs  []string
+}
+
+// nodeIPAM allocates the node pod CIDRs from the subnets of the Proxmox SDN vnet,
+// and reserves each allocation as a subnet of the vnet.
+type nodeIPAM struct {
+	c            *client
+	zone         string
+	vnet         string
+	maskSizeIPv4 int
+	maskSizeIPv6 int
+
+	nodeLister  corelisters.NodeLister
+	nodesSynced cache.InformerSynced
+	queue       workqueue.TypedRateLimitingInterface[string]
+
+	mu       sync.Mutex
+	released map[string]nodeIPAMRelease
+}
+
+func newNodeIPAM(client *client, features providerconfig.ClustersFeatures) *nodeIPAM {
+	if features.NodeIPAM.VNet == "" {
+		return nil
+	}
+
+	return &nodeIPAM{
+		c:            client,
+		zone:         features.NodeIPAM.Zone,
+		vnet:         features.NodeIPAM.VNet,
+		maskSizeIPv4: features.NodeIPAM.NodeMaskSizeIPv4,
+		maskSizeIPv6: features.NodeIPAM.NodeMaskSizeIPv6,
+		queue: workqueue.NewTypedRateLimitingQueueWithConfig(
+			workqueue.DefaultTypedControllerRateLimiter[string](),
+			workqueue.TypedRateLimitingQueueConfig[string]{Name: NodeIPAMControllerName},
+		),
+		released: map[string]nodeIPAMRelease{},
+	}
+}
+
+// StartNodeIPAMControllerWrapper is used to take cloud config as input and start the node IPAM controller.
+func StartNodeIPAMControllerWrapper(_ app.ControllerInitContext, completedConfig *cloudcontrollerconfig.CompletedConfig, ccm cloudprovider.Interface) app.InitFunc {
+	return func(ctx context.Context, _ genericcontrollermanager.ControllerContext) (controller.Interface, bool, error) {
+		c, ok := ccm.(*cloud)
+		if !ok || c.nodeIPAM == nil {
+			klog.InfoS("node-ipam controller is disabled, node_ipam.vnet is not defined")
+
+			return nil, false, nil
+		}
+
+		if err := c.nodeIPAM.setInformer(completedConfig.SharedInformers.Core().V1().Nodes()); err != nil {
+			return nil, false, err
+		}
+
+		go c.nodeIPAM.Run(ctx)
+
+		return nil, true, nil
+	}
+}
+
+func (n *nodeIPAM) setInformer(informer coreinformers.NodeInformer) error {
+	n.nodeLister = informer.Lister()
+	n.nodesSynced = informer.Informer().HasSynced
+
+	_, err := informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    n.enqueue,
+		UpdateFunc: func(_, obj any) { n.enqueue(obj) },
+		DeleteFunc: n.release,
+	})
+
+	return err
+}
+
+func (n *nodeIPAM) enqueue(obj any) {
+	node, ok := obj.(*v1.Node)
+	if !ok || len(getNodePodCIDRs(node)) > 0 {
+		return
+	}
+
+	n.queue.Add(node.Name)
+}
+
+func (n *nodeIPAM) release(obj any) {
+	node, ok := obj.(*v1.Node)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			return
+		}
+
+		if node, ok = tombstone.Obj.(*v1.Node); !ok {
+			return
+		}
+	}
+
+	cidrs := getNodePodCIDRs(node)
+	if len(cidrs) == 0 {
+		return
+	}
+
+	n.mu.Lock()
+	n.released[node.Name] = nodeIPAMRelease{region: getNodeRegion(node), cidrs: cidrs}
+	n.mu.Unlock()
+
+	n.queue.Add(node.Name)
+}
+
+// Run starts the node IPAM controller, it blocks until the context is done.
+func (n *nodeIPAM) Run(ctx context.Context) {
+	defer utilruntime.HandleCrash()
+	defer n.queue.ShutDown()
+
+	klog.InfoS("starting node-ipam controller")
+	defer klog.InfoS("shutting down node-ipam controller")
+
+	if !cache.WaitForNamedCacheSync(NodeIPAMControllerName, ctx.Done(), n.nodesSynced) {
+		return
+	}
+
+	// The allocation and the reconciliation are serialized by a single worker, each of them reads the current SDN state.
+	go wait.UntilWithContext(ctx, n.worker, time.Second)
+	go wait.UntilWithContext(ctx, func(_ context.Context) { n.queue.Add(nodeIPAMReconcileKey) }, nodeIPAMReconcilePeriod)
+
+	<-ctx.Done()
+}
+
+func (n *nodeIPAM) worker(ctx context.Context) {
+	for n.processNextItem(ctx) {
+	}
+}
+
+func (n *nodeIPAM) processNextItem(ctx context.Context) bool {
+	name, quit := n.queue.Get()
+	if quit {
+		return false
+	}
+	defer n.queue.Done(name)
+
+	if name == nodeIPAMReconcileKey {
+		if err := n.reconcile(ctx); err != nil {
+			klog.ErrorS(err, "node-ipam failed to reconcile the pod CIDRs")
+			n.queue.AddRateLimited(name)
+
+			return true
+		}
+
+		n.queue.Forget(name)
+
+		return true
+	}
+
+	if err := n.sync(ctx, name); err != nil {
+		klog.ErrorS(err, "node-ipam failed to sync node", "node", klog.KRef("", name))
+		n.queue.AddRateLimited(name)
+
+		return true
+	}
+
+	n.queue.Forget(name)
+
+	return true
+}
+
+func (n *nodeIPAM) sync(ctx context.Context, name string) error {
+	n.mu.Lock()
+	rel, ok := n.released[name]
+	n.mu.Unlock()
+
+	if ok {
+		if err := n.releaseCIDRs(ctx, rel.region, rel.cidrs); err != nil {
+			return err
+		}
+
+		n.mu.Lock()
+		delete(n.released, name)
+		n.mu.Unlock()
+	}
+
+	node, err := n.nodeLister.Get(name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	if len(getNodePodCIDRs(node)) > 0 {
+		return nil
+	}
+
+	region := getNodeRegion(node)
+	if region == "" {
+		klog.V(4).InfoS("node-ipam waiting for the node region", "node", klog.KObj(node))
+
+		return nil
+	}
+
+	cidrs, err := n.allocate(ctx, node, region)
+	if err != nil {
+		return err
+	}
+
+	patch, err := json.Marshal(map[string]any{"spec": map[string]any{"podCIDR": cidrs[0], "podCIDRs": cidrs}})
+	if err != nil {
+		return fmt.Errorf("failed to marshal the patch: %w", err)
+	}
+
+	if _, err := n.c.kclient.CoreV1().Nodes().Patch(ctx, node.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		if rerr := n.releaseCIDRs(ctx, region, cidrs); rerr != nil {
+			klog.ErrorS(rerr, "node-ipam failed to release the pod CIDRs", "node", klog.KObj(node), "cidrs", cidrs)
+		}
+
+		return fmt.Errorf("failed to patch node %s: %w", node.Name, err)
+	}
+
+	klog.InfoS("node-ipam allocated pod CIDRs", "node", klog.KObj(node), "region", region, "cidrs", cidrs)
+
+	return nil
+}
+
+// allocate finds a free block for each IP family in the vnet subnets, and reserves it as a subnet of the vnet.
+// A block is free if no node uses it, no other block subnet overlaps it, and no IPAM entry belongs to it.
+func (n *nodeIPAM) allocate(ctx context.Context, node *v1.Node, region string) ([]string, error) {
+	mc := metrics.NewMetricContext("getSDNSubnets")
+
+	subnets, err := n.c.pxpool.GetSDNSubnets(ctx, region, n.vnet)
+	if mc.ObserveRequest(err) != nil {
+		return nil, err
+	}
+
+	used, err := n.usedPrefixes()
+	if err != nil {
+		return nil, err
+	}
+
+	pools, blocks := n.splitSubnets(subnets)
+	for _, block := range blocks {
+		used = append(used, block.prefix)
+	}
+
+	entries := map[string][]netip.Addr{}
+	cidrs := []string{}
+
+	for _, ipv6 := range []bool{false, true} {
+		for _, pool := range pools {
+			if pool.prefix.Addr().Is6() != ipv6 {
+				continue
+			}
+
+			zone := pool.subnet.Zone
+			if _, ok := entries[zone]; !ok {
+				if entries[zone], err = n.ipamAddrs(ctx, region, zone); err != nil {
+					return nil, err
+				}
+			}
+
+			maskSize := n.maskSizeIPv4
+			if ipv6 {
+				maskSize = n.maskSizeIPv6
+			}
+
+			block, ok := findFreeBlock(pool.prefix, maskSize, used, entries[zone])
+			if !ok {
+				continue
+			}
+
+			mc := metrics.NewMetricContext("createSDNSubnet")
+			if err := mc.ObserveRequest(n.c.pxpool.CreateSDNSubnet(ctx, region, n.vnet, block.String())); err != nil {
+				if rerr := n.releaseCIDRs(ctx, region, cidrs); rerr != nil {
+					klog.ErrorS(rerr, "node-ipam failed to release the pod CIDRs", "node", klog.KObj(node), "cidrs", cidrs)
+				}
+
+				return nil, err
+			}
+
+			cidrs = append(cidrs, block.String())
+
+			break
+		}
+	}
+
+	if len(cidrs) == 0 {
+		return nil, fmt.Errorf("no free pod CIDR in vnet %s of region %s", n.vnet, region)
+	}
+
+	mc = metrics.NewMetricContext("applySDN")
+	if err := mc.ObserveRequest(n.c.pxpool.ApplySDN(ctx, region)); err != nil {
+		if rerr := n.releaseCIDRs(ctx, region, cidrs); rerr != nil {
+			klog.ErrorS(rerr, "node-ipam failed to release the pod CIDRs", "node", klog.KObj(node), "cidrs", cidrs)
+		}
+
+		return nil, err
+	}
+
+	return cidrs, nil
+}
+
+// releaseCIDRs removes the block subnets of the pod CIDRs from the vnet.
+func (n *nodeIPAM) releaseCIDRs(ctx context.Context, region string, cidrs []string) error {
+	if region == "" || len(cidrs) == 0 {
+		return nil
+	}
+
+	mc := metrics.NewMetricContext("getSDNSubnets")
+
+	subnets, err := n.c.pxpool.GetSDNSubnets(ctx, region, n.vnet)
+	if mc.ObserveRequest(err) != nil {
+		return err
+	}
+
+	_, blocks := n.splitSubnets(subnets)
+	released := false
+
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			continue
+		}
+
+		for _, block := range blocks {
+			if block.prefix != prefix.Masked() {
+				continue
+			}
+
+			mc := metrics.NewMetricContext("deleteSDNSubnet")
+			if err := mc.ObserveRequest(n.c.pxpool.DeleteSDNSubnet(ctx, region, n.vnet, block.subnet.ID)); err != nil {
+				return err
+			}
+
+			released = true
+
+			klog.InfoS("node-ipam released pod CIDR", "region", region, "cidr", cidr)
+		}
+	}
+
+	if !released {
+		return nil
+	}
+
+	mc = metrics.NewMetricContext("applySDN")
+
+	return mc.ObserveRequest(n.c.pxpool.ApplySDN(ctx, region))
+}
+
+// reconcile removes the block subnets with the node mask size which no node uses.
+// They are left behind when the controller stops between the block reservation and the node update.
+// The subnets with other mask sizes were not created by the controller, they are never removed.
+// The nodes are listed from the API server, the informer cache may not have the last allocations yet.
+func (n *nodeIPAM) reconcile(ctx context.Context) error {
+	nodes, err := n.c.kclient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	used := map[netip.Prefix]bool{}
+
+	for _, node := range nodes.Items {
+		for _, cidr := range getNodePodCIDRs(&node) {
+			if p, err := netip.ParsePrefix(cidr); err == nil {
+				used[p.Masked()] = true
+			}
+		}
+	}
+
+	regions := n.c.pxpool.GetRegions()
+	slices.Sort(regions)
+
+	var errs []error
+
+	for _, region := range regions {
+		mc := metrics.NewMetricContext("getSDNSubnets")
+
+		subnets, err := n.c.pxpool.GetSDNSubnets(ctx, region, n.vnet)
+		if mc.ObserveRequest(err) != nil {
+			errs = append(errs, fmt.Errorf("failed to get the subnets of region %s: %w", region, err))
+
+			continue
+		}
+
+		_, blocks := n.splitSubnets(subnets)
+		orphans := []string{}
+
+		for _, block := range blocks {
+			if !used[block.prefix] && n.isNodeBlock(block.prefix) {
+				orphans = append(orphans, block.prefix.String())
+			}
+		}
+
+		if len(orphans) == 0 {
+			continue
+		}
+
+		klog.InfoS("node-ipam releasing orphaned pod CIDRs", "region", region, "cidrs", orphans)
+
+		if err := n.releaseCIDRs(ctx, region, orphans); err != nil {
+			errs = append(errs, fmt.Errorf("failed to release the pod CIDRs of region %s: %w", region, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+type nodeIPAMSubnet struct {
+	subnet *proxmox.VNetSubnet
+	prefix netip.Prefix
+}
+
+// splitSubnets splits the vnet subnets into the pools, which the pod CIDRs are allocated from,
+// and the blocks, the allocated pod CIDRs inside the pools.
+func (n *nodeIPAM) splitSubnets(subnets []*proxmox.VNetSubnet) (pools []nodeIPAMSubnet, blocks []nodeIPAMSubnet) {
+	list := []nodeIPAMSubnet{}
+
+	for _, subnet := range subnets {
+		if n.zone != "" && subnet.Zone != n.zone {
+			continue
+		}
+
+		if prefix, err := netip.ParsePrefix(subnet.CIDR); err == nil {
+			list = append(list, nodeIPAMSubnet{subnet: subnet, prefix: prefix.Masked()})
+		}
+	}
+
+	for _, s := range list {
+		inPool := slices.ContainsFunc(list, func(p nodeIPAMSubnet) bool {
+			return p.prefix.Bits() < s.prefix.Bits() && p.prefix.Contains(s.prefix.Addr())
+		})
+
+		if inPool {
+			blocks = append(blocks, s)
+		} else {
+			pools = append(pools, s)
+		}
+	}
+
+	return pools, blocks
+}
+
+// isNodeBlock returns true if the prefix has the mask size of the node pod CIDR.
+func (n *nodeIPAM) isNodeBlock(prefix netip.Prefix) bool {
+	if prefix.Addr().Is6() {
+		return prefix.Bits() == n.maskSizeIPv6
+	}
+
+	return prefix.Bits() == n.maskSizeIPv4
+}
+
+func (n *nodeIPAM) usedPrefixes() ([]netip.Prefix, error) {
+	nodes, err := n.nodeLister.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	used := []netip.Prefix{}
+
+	for _, node := range nodes {
+		for _, cidr := range getNodePodCIDRs(node) {
+			if p, err := netip.ParsePrefix(cidr); err == nil {
+				used = append(used, p.Masked())
+			}
+		}
+	}
+
+	return used, nil
+}
+
+func (n *nodeIPAM) ipamAddrs(ctx context.Context, region string, zone string) ([]netip.Addr, error) {
+	mc := metrics.NewMetricContext("getSDNIPAMEntries")
+
+	entries, err := n.c.pxpool.GetSDNIPAMEntries(ctx, region, zone)
+	if mc.ObserveRequest(err) != nil {
+		return nil, err
+	}
+
+	addrs := []netip.Addr{}
+
+	for _, e := range entries {
+		if e.VNet != n.vnet {
+			continue
+		}
+
+		if addr, err := netip.ParseAddr(e.IP); err == nil {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	return addrs, nil
+}
+
+// findFreeBlock returns the first block of the subnet with the mask size,
+// which does not overlap with the used prefixes and does not contain any of the addresses.
+func findFreeBlock(subnet netip.Prefix, maskSize int, used []netip.Prefix, addrs []netip.Addr) (netip.Prefix, bool) {
+	if maskSize < subnet.Bits() || maskSize > subnet.Addr().BitLen() {
+		return netip.Prefix{}, false
+	}
+
+	for addr := subnet.Addr(); addr.IsValid() && subnet.Contains(addr); {
+		block := netip.PrefixFrom(addr, maskSize)
+
+		if !slices.ContainsFunc(used, block.Overlaps) && !slices.ContainsFunc(addrs, block.Contains) {
+			return block, true
+		}
+
+		addr = lastAddr(block).Next()
+	}
+
+	return netip.Prefix{}, false
+}
+
+func getNodePodCIDRs(node *v1.Node) []string {
+	if len(node.Spec.PodCIDRs) > 0 {
+		return node.Spec.PodCIDRs
+	}
+
+	if node.Spec.PodCIDR != "" {
+		return []string{node.Spec.PodCIDR}
+	}
+
+	return nil
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmox
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	proxmox "github.com/luthermonson/go-proxmox"
+	"github.com/stretchr/testify/assert"
+
+	providerconfig "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/config"
+	"github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/proxmoxpool"
+	testcluster "github.com/sergelogvinov/proxmox-cloud-controller-manager/test/cluster"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestNodeIPAM(t *testing.T, nodes ...*v1.Node) *nodeIPAM {
+	t.Helper()
+
+	cfg, err := providerconfig.ReadCloudConfigFromFile("../../test/config/cluster-config-1.yaml")
+	assert.Nil(t, err)
+
+	px, err := proxmoxpool.NewProxmoxPool(cfg.Clusters)
+	assert.Nil(t, err)
+
+	kclient := fake.NewClientset()
+	informer := informers.NewSharedInformerFactory(kclient, 0).Core().V1().Nodes()
+
+	for _, node := range nodes {
+		_, err := kclient.CoreV1().Nodes().Create(t.Context(), node, metav1.CreateOptions{})
+		assert.Nil(t, err)
+		assert.Nil(t, informer.Informer().GetIndexer().Add(node))
+	}
+
+	n := newNodeIPAM(&client{pxpool: px, kclient: kclient}, providerconfig.ClustersFeatures{
+		NodeIPAM: providerconfig.NodeIPAMOpts{VNet: "pods", NodeMaskSizeIPv4: 24, NodeMaskSizeIPv6: 64},
+	})
+	assert.NotNil(t, n)
+	assert.Nil(t, n.setInformer(informer))
+
+	return n
+}
+
+func TestNewNodeIPAM(t *testing.T) {
+	assert.Nil(t, newNodeIPAM(&client{}, providerconfig.ClustersFeatures{}))
+}
+
+func TestFindFreeBlock(t *testing.T) {
+	subnet := netip.MustParsePrefix("10.244.0.0/22")
+	used := []netip.Prefix{netip.MustParsePrefix("10.244.0.0/24")}
+	addrs := []netip.Addr{netip.MustParseAddr("10.244.1.1")}
+
+	block, ok := findFreeBlock(subnet, 24, used, addrs)
+	assert.True(t, ok)
+	assert.Equal(t, "10.244.2.0/24", block.String())
+
+	_, ok = findFreeBlock(subnet, 20, used, addrs)
+	assert.False(t, ok)
+
+	used = append(used, netip.MustParsePrefix("10.244.2.0/23"))
+
+	_, ok = findFreeBlock(subnet, 24, used, addrs)
+	assert.False(t, ok)
+
+	block, ok = findFreeBlock(netip.MustParsePrefix("fd00:10:244::/56"), 64, nil, nil)
+	assert.True(t, ok)
+	assert.Equal(t, "fd00:10:244::/64", block.String())
+}
+
+func TestNodeIPAMSync(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	sdn := testcluster.SetupSDNMockResponders("k8s", map[string][]*proxmox.VNetSubnet{
+		"127.0.0.1:8006": {
+			{ID: "k8s-10.244.0.0-16", CIDR: "10.244.0.0/16", Zone: "k8s", VNet: "pods"},
+			{ID: "k8s-fd00-10-244---56", CIDR: "fd00:10:244::/56", Zone: "k8s", VNet: "pods"},
+		},
+	})
+	sdn.AddIPAMEntry("127.0.0.1:8006", &proxmox.IPAM{IP: "10.244.1.1", Zone: "k8s", VNet: "pods", VMID: "200"})
+
+	n := newTestNodeIPAM(t,
+		&v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-1-node-1"},
+			Spec:       v1.NodeSpec{ProviderID: "proxmox://cluster-1/100", PodCIDR: "10.244.0.0/24"},
+		},
+		&v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-1-node-2"},
+			Spec:       v1.NodeSpec{ProviderID: "proxmox://cluster-1/101"},
+		},
+		&v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-1-node-3"},
+		},
+	)
+
+	err := n.sync(t.Context(), "cluster-1-node-2")
+	assert.Nil(t, err)
+
+	node, err := n.c.kclient.CoreV1().Nodes().Get(t.Context(), "cluster-1-node-2", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "10.244.2.0/24", node.Spec.PodCIDR)
+	assert.Equal(t, []string{"10.244.2.0/24", "fd00:10:244::/64"}, node.Spec.PodCIDRs)
+
+	subnets := sdn.Subnets("127.0.0.1:8006")
+	assert.Equal(t, 4, len(subnets))
+	assert.Equal(t, "10.244.2.0/24", subnets[2].CIDR)
+	assert.Equal(t, "fd00:10:244::/64", subnets[3].CIDR)
+	assert.Equal(t, 1, sdn.Applied["127.0.0.1:8006"])
+	assert.Equal(t, 1, len(sdn.IPAMEntries("127.0.0.1:8006")))
+
+	// The block subnet is not allocated twice, even if the node informer is not up to date
+	err = n.c.kclient.CoreV1().Nodes().Delete(t.Context(), "cluster-1-node-2", metav1.DeleteOptions{})
+	assert.Nil(t, err)
+
+	_, err = n.c.kclient.CoreV1().Nodes().Create(t.Context(), &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-1-node-2"},
+		Spec:       v1.NodeSpec{ProviderID: "proxmox://cluster-1/101"},
+	}, metav1.CreateOptions{})
+	assert.Nil(t, err)
+
+	err = n.sync(t.Context(), "cluster-1-node-2")
+	assert.Nil(t, err)
+
+	node, err = n.c.kclient.CoreV1().Nodes().Get(t.Context(), "cluster-1-node-2", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"10.244.3.0/24", "fd00:10:244:1::/64"}, node.Spec.PodCIDRs)
+	assert.Equal(t, 6, len(sdn.Subnets("127.0.0.1:8006")))
+
+	// Node without region is skipped
+	err = n.sync(t.Context(), "cluster-1-node-3")
+	assert.Nil(t, err)
+
+	node, err = n.c.kclient.CoreV1().Nodes().Get(t.Context(), "cluster-1-node-3", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Empty(t, node.Spec.PodCIDRs)
+
+	// Deleted node releases the block subnets
+	n.release(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-1-node-4"},
+		Spec:       v1.NodeSpec{ProviderID: "proxmox://cluster-1/104", PodCIDRs: []string{"10.244.3.0/24", "fd00:10:244:1::/64"}},
+	})
+
+	err = n.sync(t.Context(), "cluster-1-node-4")
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(sdn.Subnets("127.0.0.1:8006")))
+	assert.Empty(t, n.released)
+}
+
+func TestNodeIPAMReconcile(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	sdn := testcluster.SetupSDNMockResponders("k8s", map[string][]*proxmox.VNetSubnet{
+		"127.0.0.1:8006": {
+			{ID: "k8s-10.244.0.0-16", CIDR: "10.244.0.0/16", Zone: "k8s", VNet: "pods"},
+			{ID: "k8s-10.244.0.0-24", CIDR: "10.244.0.0/24", Zone: "k8s", VNet: "pods"},
+			{ID: "k8s-10.244.1.0-24", CIDR: "10.244.1.0/24", Zone: "k8s", VNet: "pods"},
+			{ID: "k8s-10.244.2.0-25", CIDR: "10.244.2.0/25", Zone: "k8s", VNet: "pods"},
+		},
+	})
+
+	n := newTestNodeIPAM(t,
+		&v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-1-node-1"},
+			Spec:       v1.NodeSpec{ProviderID: "proxmox://cluster-1/100", PodCIDR: "10.244.0.0/24"},
+		},
+	)
+
+	err := n.reconcile(t.Context())
+	assert.Nil(t, err)
+
+	// The subnet with another mask size was created by the operator
+	subnets := sdn.Subnets("127.0.0.1:8006")
+	assert.Equal(t, 3, len(subnets))
+	assert.Equal(t, "10.244.0.0/16", subnets[0].CIDR)
+	assert.Equal(t, "10.244.0.0/24", subnets[1].CIDR)
+	assert.Equal(t, "10.244.2.0/25", subnets[2].CIDR)
+	assert.Equal(t, 1, sdn.Applied["127.0.0.1:8006"])
+}
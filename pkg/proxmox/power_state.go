@@ -0,0 +1,127 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmox
+
+import (
+	"context"
+	"maps"
+
+	proxmox "github.com/luthermonson/go-proxmox"
+
+	providerconfig "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/config"
+	metrics "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/metrics"
+
+	"k8s.io/klog/v2"
+)
+
+// defaultPowerStates is the default mapping of the Proxmox VM states to the instance state.
+// The VM which is live migrated or backed up is paused for a short time, it is still running.
+func defaultPowerStates() providerconfig.PowerStateOpts {
+	return providerconfig.PowerStateOpts{
+		Status: map[string]providerconfig.InstanceState{
+			"running":   providerconfig.InstanceStateRunning,
+			"stopped":   providerconfig.InstanceStateShutdown,
+			"paused":    providerconfig.InstanceStateShutdown,
+			"prelaunch": providerconfig.InstanceStateShutdown,
+			"suspended": providerconfig.InstanceStateShutdown,
+		},
+		HA: map[string]providerconfig.InstanceState{
+			"request_stop": providerconfig.InstanceStateShutdown,
+			"stopped":      providerconfig.InstanceStateShutdown,
+		},
+		Lock: map[string]providerconfig.InstanceState{
+			"backup":     providerconfig.InstanceStateRunning,
+			"migrate":    providerconfig.InstanceStateRunning,
+			"suspending": providerconfig.InstanceStateShutdown,
+			"suspended":  providerconfig.InstanceStateShutdown,
+		},
+	}
+}
+
+type powerStates struct {
+	providerconfig.PowerStateOpts
+}
+
+func newPowerStates(opts providerconfig.PowerStateOpts) *powerStates {
+	states := defaultPowerStates()
+
+	maps.Copy(states.Status, opts.Status)
+	maps.Copy(states.HA, opts.HA)
+	maps.Copy(states.Lock, opts.Lock)
+
+	return &powerStates{states}
+}
+
+// state returns the instance state, the first defined mapping wins: lock, HA state, QEMU status and VM status.
+// The unknown states are reported as running.
+func (p *powerStates) state(lock, haState, qmpStatus, status string) providerconfig.InstanceState {
+	if s, ok := p.Lock[lock]; ok && lock != "" {
+		return s
+	}
+
+	if s, ok := p.HA[haState]; ok && haState != "" {
+		return s
+	}
+
+	if s, ok := p.Status[qmpStatus]; ok && qmpStatus != "" {
+		return s
+	}
+
+	if s, ok := p.Status[status]; ok && status != "" {
+		return s
+	}
+
+	return providerconfig.InstanceStateRunning
+}
+
+// hasAbsent returns true if any state is mapped to absent.
+func (p *powerStates) hasAbsent() bool {
+	for _, states := range []map[string]providerconfig.InstanceState{p.Status, p.HA, p.Lock} {
+		for _, s := range states {
+			if s == providerconfig.InstanceStateAbsent {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// getInstanceState returns the instance state of the VM or LXC container resource.
+// The status and the HA state come from the inventory, the lock and the QEMU status are requested
+// only for the running guest, it can be paused, suspended or locked by a backup or a migration.
+func (i *instances) getInstanceState(ctx context.Context, region string, rs *proxmox.ClusterResource) providerconfig.InstanceState {
+	var lock, qmpStatus string
+
+	if rs.Status == "running" {
+		mc := metrics.NewMetricContext("getGuestStatus")
+
+		status, err := i.c.pxpool.GetGuestStatus(ctx, region, rs)
+		if mc.ObserveRequest(err) != nil {
+			klog.ErrorS(err, "instances.getInstanceState() failed to get guest status", "region", region, "vmID", rs.VMID)
+		} else {
+			lock, qmpStatus = status.Lock, status.QMPStatus
+		}
+	}
+
+	state := i.powerStates.state(lock, rs.HAstate, qmpStatus, rs.Status)
+
+	klog.V(5).InfoS("instances.getInstanceState()", "region", region, "vmID", rs.VMID,
+		"status", rs.Status, "qmpStatus", qmpStatus, "haState", rs.HAstate, "lock", lock, "state", state)
+
+	return state
+}
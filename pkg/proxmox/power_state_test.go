@@ -0,0 +1,146 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmox
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+
+	providerconfig "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/config"
+	"github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/proxmoxpool"
+	testcluster "github.com/sergelogvinov/proxmox-cloud-controller-manager/test/cluster"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPowerStates(t *testing.T) {
+	t.Parallel()
+
+	p := newPowerStates(providerconfig.PowerStateOpts{
+		Status: map[string]providerconfig.InstanceState{"paused": providerconfig.InstanceStateRunning},
+	})
+
+	tests := []struct {
+		msg       string
+		lock      string
+		haState   string
+		qmpStatus string
+		status    string
+		expected  providerconfig.InstanceState
+	}{
+		{msg: "Running", qmpStatus: "running", status: "running", expected: providerconfig.InstanceStateRunning},
+		{msg: "Stopped", status: "stopped", expected: providerconfig.InstanceStateShutdown},
+		{msg: "Unknown", status: "unknown", expected: providerconfig.InstanceStateRunning},
+		{msg: "PausedOverride", qmpStatus: "paused", status: "running", expected: providerconfig.InstanceStateRunning},
+		{msg: "Prelaunch", qmpStatus: "prelaunch", status: "running", expected: providerconfig.InstanceStateShutdown},
+		{msg: "HARequestStop", haState: "request_stop", qmpStatus: "running", status: "running", expected: providerconfig.InstanceStateShutdown},
+		{msg: "HAStarted", haState: "started", status: "stopped", expected: providerconfig.InstanceStateShutdown},
+		{msg: "Hibernated", lock: "suspended", status: "stopped", expected: providerconfig.InstanceStateShutdown},
+		{msg: "Migrate", lock: "migrate", qmpStatus: "paused", status: "running", expected: providerconfig.InstanceStateRunning},
+		{msg: "Backup", lock: "backup", haState: "request_stop", status: "running", expected: providerconfig.InstanceStateRunning},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.msg, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, testCase.expected, p.state(testCase.lock, testCase.haState, testCase.qmpStatus, testCase.status))
+		})
+	}
+
+	assert.False(t, p.hasAbsent())
+	assert.True(t, newPowerStates(providerconfig.PowerStateOpts{
+		HA: map[string]providerconfig.InstanceState{"error": providerconfig.InstanceStateAbsent},
+	}).hasAbsent())
+}
+
+func TestInstanceShutdownPaused(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	httpmock.RegisterResponder(http.MethodGet, `=~/nodes/pve-1/qemu/100/status/current`,
+		httpmock.NewJsonResponderOrPanic(200, map[string]any{
+			"data": map[string]any{"vmid": 100, "status": "running", "qmpstatus": "paused"},
+		}))
+
+	cfg, err := providerconfig.ReadCloudConfigFromFile("../../test/config/cluster-config-1.yaml")
+	assert.Nil(t, err)
+
+	px, err := proxmoxpool.NewProxmoxPool(cfg.Clusters)
+	assert.Nil(t, err)
+
+	i := newInstances(&client{pxpool: px, kclient: fake.NewClientset()}, providerconfig.ClustersFeatures{})
+
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-1-node-1"},
+		Spec:       v1.NodeSpec{ProviderID: "proxmox://cluster-1/100"},
+	}
+
+	shutdown, err := i.InstanceShutdown(t.Context(), node)
+	assert.Nil(t, err)
+	assert.True(t, shutdown)
+
+	i = newInstances(&client{pxpool: px, kclient: fake.NewClientset()}, providerconfig.ClustersFeatures{
+		PowerState: providerconfig.PowerStateOpts{
+			Status: map[string]providerconfig.InstanceState{"paused": providerconfig.InstanceStateRunning},
+		},
+	})
+
+	shutdown, err = i.InstanceShutdown(t.Context(), node)
+	assert.Nil(t, err)
+	assert.False(t, shutdown)
+}
+
+func TestInstanceShutdownStopped(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	httpmock.RegisterResponder(http.MethodGet, `=~/nodes/pve-3/qemu/103/status/current`,
+		httpmock.NewJsonResponderOrPanic(200, map[string]any{
+			"data": map[string]any{"vmid": 103, "status": "stopped"},
+		}))
+
+	cfg, err := providerconfig.ReadCloudConfigFromFile("../../test/config/cluster-config-1.yaml")
+	assert.Nil(t, err)
+
+	px, err := proxmoxpool.NewProxmoxPool(cfg.Clusters)
+	assert.Nil(t, err)
+
+	i := newInstances(&client{pxpool: px, kclient: fake.NewClientset()}, providerconfig.ClustersFeatures{})
+
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-2-node-1"},
+		Spec:       v1.NodeSpec{ProviderID: "proxmox://cluster-2/103"},
+	}
+
+	// The state of the stopped VM is taken from the inventory
+	shutdown, err := i.InstanceShutdown(t.Context(), node)
+	assert.Nil(t, err)
+	assert.True(t, shutdown)
+
+	info := httpmock.GetCallCountInfo()
+	assert.Equal(t, 0, info["GET =~/nodes/pve-3/qemu/103/status/current"])
+}
@@ -0,0 +1,218 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"slices"
+
+	providerconfig "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/config"
+	metrics "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/metrics"
+	provider "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/provider"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/klog/v2"
+)
+
+// routes programs the node pod CIDRs as subnets of the Proxmox SDN vnet.
+type routes struct {
+	c    *client
+	zone string
+	vnet string
+}
+
+func newRoutes(client *client, features providerconfig.ClustersFeatures) *routes {
+	if features.Routes.VNet == "" {
+		return nil
+	}
+
+	return &routes{
+		c:    client,
+		zone: features.Routes.Zone,
+		vnet: features.Routes.VNet,
+	}
+}
+
+// ListRoutes lists all managed routes that belong to the specified clusterName.
+func (r *routes) ListRoutes(ctx context.Context, _ string) ([]*cloudprovider.Route, error) {
+	klog.V(4).InfoS("routes.ListRoutes() called")
+
+	nodes, err := r.c.kclient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	regions := r.c.pxpool.GetRegions()
+	slices.Sort(regions)
+
+	result := []*cloudprovider.Route{}
+
+	for _, region := range regions {
+		mc := metrics.NewMetricContext("getSDNSubnets")
+
+		subnets, err := r.c.pxpool.GetSDNSubnets(ctx, region, r.vnet)
+		if mc.ObserveRequest(err) != nil {
+			return nil, err
+		}
+
+		list := []nodeIPAMSubnet{}
+
+		for _, subnet := range subnets {
+			if r.zone != "" && subnet.Zone != r.zone {
+				continue
+			}
+
+			prefix, err := netip.ParsePrefix(subnet.CIDR)
+			if err != nil {
+				klog.V(4).InfoS("routes.ListRoutes() skipping subnet with invalid CIDR", "region", region, "subnet", subnet.ID, "cidr", subnet.CIDR)
+
+				continue
+			}
+
+			list = append(list, nodeIPAMSubnet{subnet: subnet, prefix: prefix.Masked()})
+		}
+
+		for _, s := range list {
+			// The subnet which contains other subnets is the address pool, not a route.
+			isPool := slices.ContainsFunc(list, func(p nodeIPAMSubnet) bool {
+				return p.prefix.Bits() > s.prefix.Bits() && s.prefix.Contains(p.prefix.Addr())
+			})
+			if isPool {
+				continue
+			}
+
+			result = append(result, &cloudprovider.Route{
+				Name:            s.subnet.ID,
+				TargetNode:      findNodeByPodCIDR(nodes.Items, region, s.prefix),
+				DestinationCIDR: s.prefix.String(),
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// CreateRoute creates the pod CIDR subnet in the vnet of the node region.
+func (r *routes) CreateRoute(ctx context.Context, _ string, _ string, route *cloudprovider.Route) error {
+	klog.V(4).InfoS("routes.CreateRoute() called", "node", klog.KRef("", string(route.TargetNode)), "cidr", route.DestinationCIDR)
+
+	prefix, err := netip.ParsePrefix(route.DestinationCIDR)
+	if err != nil {
+		return fmt.Errorf("invalid route destination %s: %w", route.DestinationCIDR, err)
+	}
+
+	node, err := r.c.kclient.CoreV1().Nodes().Get(ctx, string(route.TargetNode), metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get node %s: %w", route.TargetNode, err)
+	}
+
+	region := getNodeRegion(node)
+	if region == "" {
+		return fmt.Errorf("failed to get region of node %s", node.Name)
+	}
+
+	mc := metrics.NewMetricContext("getSDNSubnets")
+
+	subnets, err := r.c.pxpool.GetSDNSubnets(ctx, region, r.vnet)
+	if mc.ObserveRequest(err) != nil {
+		return err
+	}
+
+	for _, subnet := range subnets {
+		if p, err := netip.ParsePrefix(subnet.CIDR); err == nil && p.Masked() == prefix.Masked() {
+			return nil
+		}
+	}
+
+	mc = metrics.NewMetricContext("createSDNSubnet")
+	if err := mc.ObserveRequest(r.c.pxpool.CreateSDNSubnet(ctx, region, r.vnet, prefix.Masked().String())); err != nil {
+		return err
+	}
+
+	mc = metrics.NewMetricContext("applySDN")
+
+	return mc.ObserveRequest(r.c.pxpool.ApplySDN(ctx, region))
+}
+
+// DeleteRoute deletes the pod CIDR subnet from the vnet.
+func (r *routes) DeleteRoute(ctx context.Context, _ string, route *cloudprovider.Route) error {
+	klog.V(4).InfoS("routes.DeleteRoute() called", "name", route.Name, "cidr", route.DestinationCIDR)
+
+	regions := r.c.pxpool.GetRegions()
+	slices.Sort(regions)
+
+	for _, region := range regions {
+		mc := metrics.NewMetricContext("getSDNSubnets")
+
+		subnets, err := r.c.pxpool.GetSDNSubnets(ctx, region, r.vnet)
+		if mc.ObserveRequest(err) != nil {
+			return err
+		}
+
+		for _, subnet := range subnets {
+			if subnet.ID != route.Name || (r.zone != "" && subnet.Zone != r.zone) {
+				continue
+			}
+
+			mc = metrics.NewMetricContext("deleteSDNSubnet")
+			if err := mc.ObserveRequest(r.c.pxpool.DeleteSDNSubnet(ctx, region, r.vnet, subnet.ID)); err != nil {
+				return err
+			}
+
+			mc = metrics.NewMetricContext("applySDN")
+
+			return mc.ObserveRequest(r.c.pxpool.ApplySDN(ctx, region))
+		}
+	}
+
+	return nil
+}
+
+// findNodeByPodCIDR returns the name of the node in the region which owns the pod CIDR.
+func findNodeByPodCIDR(nodes []v1.Node, region string, prefix netip.Prefix) types.NodeName {
+	for _, node := range nodes {
+		if getNodeRegion(&node) != region {
+			continue
+		}
+
+		for _, cidr := range getNodePodCIDRs(&node) {
+			if p, err := netip.ParsePrefix(cidr); err == nil && p.Masked() == prefix.Masked() {
+				return types.NodeName(node.Name)
+			}
+		}
+	}
+
+	return ""
+}
+
+// getNodeRegion returns the region of the node from the providerID or the topology labels.
+func getNodeRegion(node *v1.Node) string {
+	if _, region, err := provider.ParseProviderID(node.Spec.ProviderID); err == nil {
+		return region
+	}
+
+	if region := node.Labels[LabelTopologyRegion]; region != "" {
+		return region
+	}
+
+	return node.Labels[v1.LabelTopologyRegion]
+}
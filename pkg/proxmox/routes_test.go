@@ -0,0 +1,119 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmox
+
+import (
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	proxmox "github.com/luthermonson/go-proxmox"
+	"github.com/stretchr/testify/assert"
+
+	providerconfig "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/config"
+	"github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/proxmoxpool"
+	testcluster "github.com/sergelogvinov/proxmox-cloud-controller-manager/test/cluster"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	cloudprovider "k8s.io/cloud-provider"
+)
+
+func newTestRoutes(t *testing.T, nodes ...*v1.Node) *routes {
+	t.Helper()
+
+	cfg, err := providerconfig.ReadCloudConfigFromFile("../../test/config/cluster-config-1.yaml")
+	assert.Nil(t, err)
+
+	px, err := proxmoxpool.NewProxmoxPool(cfg.Clusters)
+	assert.Nil(t, err)
+
+	kclient := fake.NewClientset()
+	for _, node := range nodes {
+		_, err := kclient.CoreV1().Nodes().Create(t.Context(), node, metav1.CreateOptions{})
+		assert.Nil(t, err)
+	}
+
+	r := newRoutes(&client{pxpool: px, kclient: kclient}, providerconfig.ClustersFeatures{
+		Routes: providerconfig.SDNOpts{Zone: "k8s", VNet: "pods"},
+	})
+	assert.NotNil(t, r)
+
+	return r
+}
+
+func TestNewRoutes(t *testing.T) {
+	assert.Nil(t, newRoutes(&client{}, providerconfig.ClustersFeatures{}))
+	assert.Nil(t, newRoutes(&client{}, providerconfig.ClustersFeatures{Routes: providerconfig.SDNOpts{Zone: "k8s"}}))
+}
+
+func TestRoutes(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	sdn := testcluster.SetupSDNMockResponders("k8s", map[string][]*proxmox.VNetSubnet{
+		"127.0.0.1:8006": {
+			{ID: "k8s-10.244.0.0-16", CIDR: "10.244.0.0/16", Zone: "k8s", VNet: "pods"},
+			{ID: "k8s-10.244.0.0-24", CIDR: "10.244.0.0/24", Zone: "k8s", VNet: "pods"},
+			{ID: "other-10.0.0.0-24", CIDR: "10.0.0.0/24", Zone: "other", VNet: "pods"},
+		},
+	})
+
+	r := newTestRoutes(t,
+		&v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-1-node-1"},
+			Spec:       v1.NodeSpec{ProviderID: "proxmox://cluster-1/100", PodCIDR: "10.244.0.0/24"},
+		},
+		&v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-2-node-1", Labels: map[string]string{v1.LabelTopologyRegion: "cluster-2"}},
+			Spec:       v1.NodeSpec{PodCIDRs: []string{"10.244.1.0/24"}},
+		},
+	)
+
+	list, err := r.ListRoutes(t.Context(), "kubernetes")
+	assert.Nil(t, err)
+	assert.Equal(t, []*cloudprovider.Route{
+		{Name: "k8s-10.244.0.0-24", TargetNode: "cluster-1-node-1", DestinationCIDR: "10.244.0.0/24"},
+	}, list)
+
+	route := &cloudprovider.Route{TargetNode: "cluster-2-node-1", DestinationCIDR: "10.244.1.0/24"}
+
+	err = r.CreateRoute(t.Context(), "kubernetes", "", route)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(sdn.Subnets("127.0.0.2:8006")))
+	assert.Equal(t, 1, sdn.Applied["127.0.0.2:8006"])
+
+	// Route already exists
+	err = r.CreateRoute(t.Context(), "kubernetes", "", route)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, sdn.Applied["127.0.0.2:8006"])
+
+	list, err = r.ListRoutes(t.Context(), "kubernetes")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(list))
+	assert.Equal(t, &cloudprovider.Route{
+		Name: "k8s-10.244.1.0-24", TargetNode: "cluster-2-node-1", DestinationCIDR: "10.244.1.0/24",
+	}, list[1])
+
+	err = r.DeleteRoute(t.Context(), "kubernetes", list[0])
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(sdn.Subnets("127.0.0.1:8006")))
+	assert.Equal(t, 1, sdn.Applied["127.0.0.1:8006"])
+
+	err = r.CreateRoute(t.Context(), "kubernetes", "", &cloudprovider.Route{TargetNode: "unknown", DestinationCIDR: "10.244.2.0/24"})
+	assert.NotNil(t, err)
+}
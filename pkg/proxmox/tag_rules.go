@@ -0,0 +1,237 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	providerconfig "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/config"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	cloudnodeutil "k8s.io/cloud-provider/node/helpers"
+	"k8s.io/klog/v2"
+)
+
+type tagRule struct {
+	providerconfig.TagRule
+
+	re *regexp.Regexp
+}
+
+// tagRules maps the VM tags to the node labels and taints.
+type tagRules []tagRule
+
+func newTagRules(rules []providerconfig.TagRule) tagRules {
+	r := make(tagRules, 0, len(rules))
+
+	for _, rule := range rules {
+		t := tagRule{TagRule: rule}
+
+		if rule.Match == providerconfig.TagMatchRegex {
+			re, err := regexp.Compile(rule.Tag)
+			if err != nil {
+				klog.ErrorS(err, "Failed to parse the tag rule regex", "tag", rule.Tag)
+
+				continue
+			}
+
+			t.re = re
+		}
+
+		r = append(r, t)
+	}
+
+	return r
+}
+
+// expander returns the function which expands the regex capture groups in the labels and taint values,
+// it returns nil if the tag does not match the rule.
+func (r *tagRule) expander(tag string) func(string) string {
+	switch r.Match {
+	case providerconfig.TagMatchPrefix:
+		if strings.HasPrefix(tag, r.Tag) {
+			return func(s string) string { return s }
+		}
+	case providerconfig.TagMatchRegex:
+		if m := r.re.FindStringSubmatchIndex(tag); m != nil {
+			return func(s string) string { return string(r.re.ExpandString(nil, s, tag, m)) }
+		}
+	default:
+		if tag == r.Tag {
+			return func(s string) string { return s }
+		}
+	}
+
+	return nil
+}
+
+// resolve returns the node labels and taints of the VM tags, the later rules override the earlier ones.
+// The expanded labels and taints, which are not valid, are skipped and returned as the invalid entries.
+func (r tagRules) resolve(tags []string) (map[string]string, []v1.Taint, []string) {
+	labels := map[string]string{}
+	taints := map[string]v1.Taint{}
+	invalid := map[string]bool{}
+
+	for idx := range r {
+		for _, tag := range tags {
+			expand := r[idx].expander(tag)
+			if expand == nil {
+				continue
+			}
+
+			for k, v := range r[idx].Labels {
+				key, value := expand(k), expand(v)
+
+				if errs := append(validation.IsQualifiedName(key), validation.IsValidLabelValue(value)...); len(errs) > 0 {
+					invalid[fmt.Sprintf("label %s=%s: %s", key, value, strings.Join(errs, ", "))] = true
+
+					continue
+				}
+
+				labels[key] = value
+			}
+
+			for _, t := range r[idx].Taints {
+				taint := v1.Taint{Key: t.Key, Value: expand(t.Value), Effect: v1.TaintEffect(t.Effect)}
+
+				errs := append(validation.IsQualifiedName(taint.Key), validation.IsValidLabelValue(taint.Value)...)
+				if !slices.Contains(providerconfig.ValidTaintEffects, t.Effect) {
+					errs = append(errs, fmt.Sprintf("effect must be one of %v", providerconfig.ValidTaintEffects))
+				}
+
+				if len(errs) > 0 {
+					invalid[fmt.Sprintf("taint %s=%s:%s: %s", taint.Key, taint.Value, taint.Effect, strings.Join(errs, ", "))] = true
+
+					continue
+				}
+
+				taints[taintID(&taint)] = taint
+			}
+		}
+	}
+
+	result := make([]v1.Taint, 0, len(taints))
+	for _, id := range slices.Sorted(maps.Keys(taints)) {
+		result = append(result, taints[id])
+	}
+
+	return labels, result, slices.Sorted(maps.Keys(invalid))
+}
+
+func taintID(t *v1.Taint) string {
+	return t.Key + ":" + string(t.Effect)
+}
+
+// syncTagRules applies the labels and the taints of the tag rules to the node.
+// The labels and the taints of the rules which do not match the VM tags anymore are removed,
+// the managed keys are stored in the node annotations.
+func (i *instances) syncTagRules(ctx context.Context, node *v1.Node, tags []string) error {
+	managedLabels := SplitTrim(node.Annotations[AnnotationProxmoxTagLabels], ',')
+	managedTaints := SplitTrim(node.Annotations[AnnotationProxmoxTagTaints], ',')
+
+	if len(i.tagRules) == 0 && len(managedLabels) == 0 && len(managedTaints) == 0 {
+		return nil
+	}
+
+	labels, taints, _ := i.tagRules.resolve(tags)
+
+	labelsPatch := map[string]any{}
+
+	for k, v := range labels {
+		if r, ok := node.Labels[k]; !ok || r != v {
+			labelsPatch[k] = v
+		}
+	}
+
+	for _, k := range managedLabels {
+		if _, ok := labels[k]; !ok {
+			if _, ok := node.Labels[k]; ok {
+				labelsPatch[k] = nil
+			}
+		}
+	}
+
+	taintIDs := make([]string, 0, len(taints))
+	for idx := range taints {
+		taintIDs = append(taintIDs, taintID(&taints[idx]))
+	}
+
+	annotationsPatch := map[string]any{}
+
+	for k, v := range map[string]string{
+		AnnotationProxmoxTagLabels: strings.Join(slices.Sorted(maps.Keys(labels)), ","),
+		AnnotationProxmoxTagTaints: strings.Join(taintIDs, ","),
+	} {
+		switch {
+		case v == "" && node.Annotations[k] != "":
+			annotationsPatch[k] = nil
+		case v != "" && node.Annotations[k] != v:
+			annotationsPatch[k] = v
+		}
+	}
+
+	if len(labelsPatch) > 0 || len(annotationsPatch) > 0 {
+		data, err := json.Marshal(map[string]any{"metadata": map[string]any{"labels": labelsPatch, "annotations": annotationsPatch}})
+		if err != nil {
+			return fmt.Errorf("failed to marshal the patch: %w", err)
+		}
+
+		if _, err := i.c.kclient.CoreV1().Nodes().Patch(ctx, node.Name, types.MergePatchType, data, metav1.PatchOptions{}); err != nil {
+			return fmt.Errorf("failed to patch node %s: %w", node.Name, err)
+		}
+	}
+
+	var staleTaints []*v1.Taint
+
+	for _, id := range managedTaints {
+		if slices.Contains(taintIDs, id) {
+			continue
+		}
+
+		key, effect, _ := strings.Cut(id, ":")
+		staleTaints = append(staleTaints, &v1.Taint{Key: key, Effect: v1.TaintEffect(effect)})
+	}
+
+	if err := cloudnodeutil.RemoveTaintOffNode(i.c.kclient, node.Name, node, staleTaints...); err != nil {
+		return fmt.Errorf("failed to remove taints of node %s: %w", node.Name, err)
+	}
+
+	var newTaints []*v1.Taint
+
+	for idx := range taints {
+		if !slices.ContainsFunc(node.Spec.Taints, func(t v1.Taint) bool {
+			return t.MatchTaint(&taints[idx]) && t.Value == taints[idx].Value
+		}) {
+			newTaints = append(newTaints, &taints[idx])
+		}
+	}
+
+	if err := cloudnodeutil.AddOrUpdateTaintOnNode(i.c.kclient, node.Name, newTaints...); err != nil {
+		return fmt.Errorf("failed to add taints to node %s: %w", node.Name, err)
+	}
+
+	return nil
+}
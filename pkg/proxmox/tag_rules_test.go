@@ -0,0 +1,189 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmox
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+
+	providerconfig "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/config"
+	"github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/proxmoxpool"
+	testcluster "github.com/sergelogvinov/proxmox-cloud-controller-manager/test/cluster"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var testTagRules = []providerconfig.TagRule{
+	{
+		Tag:    "prod",
+		Match:  providerconfig.TagMatchExact,
+		Labels: map[string]string{"env": "prod"},
+	},
+	{
+		Tag:    "ingress",
+		Match:  providerconfig.TagMatchPrefix,
+		Labels: map[string]string{"node-role.kubernetes.io/ingress": ""},
+		Taints: []providerconfig.TagTaint{{Key: "ingress", Value: "true", Effect: "NoSchedule"}},
+	},
+	{
+		Tag:    "^role-(.+)$",
+		Match:  providerconfig.TagMatchRegex,
+		Labels: map[string]string{"node-role.kubernetes.io/${1}": ""},
+		Taints: []providerconfig.TagTaint{{Key: "dedicated", Value: "${1}", Effect: "NoSchedule"}},
+	},
+}
+
+func TestTagRulesResolve(t *testing.T) {
+	t.Parallel()
+
+	r := newTagRules(testTagRules)
+
+	tests := []struct {
+		msg             string
+		tags            []string
+		expectedLabels  map[string]string
+		expectedTaints  []v1.Taint
+		expectedInvalid []string
+	}{
+		{
+			msg:            "NoTags",
+			expectedLabels: map[string]string{},
+			expectedTaints: []v1.Taint{},
+		},
+		{
+			msg:            "Exact",
+			tags:           []string{"prod", "production"},
+			expectedLabels: map[string]string{"env": "prod"},
+			expectedTaints: []v1.Taint{},
+		},
+		{
+			msg:            "Prefix",
+			tags:           []string{"ingress-public"},
+			expectedLabels: map[string]string{"node-role.kubernetes.io/ingress": ""},
+			expectedTaints: []v1.Taint{{Key: "ingress", Value: "true", Effect: v1.TaintEffectNoSchedule}},
+		},
+		{
+			msg:            "Regex",
+			tags:           []string{"role-gpu", "prod"},
+			expectedLabels: map[string]string{"env": "prod", "node-role.kubernetes.io/gpu": ""},
+			expectedTaints: []v1.Taint{{Key: "dedicated", Value: "gpu", Effect: v1.TaintEffectNoSchedule}},
+		},
+		{
+			msg:            "Invalid",
+			tags:           []string{"role-gpu_", "prod"},
+			expectedLabels: map[string]string{"env": "prod"},
+			expectedTaints: []v1.Taint{},
+			expectedInvalid: []string{
+				"label node-role.kubernetes.io/gpu_=: name part must consist of alphanumeric characters",
+				"taint dedicated=gpu_:NoSchedule: a valid label must be an empty string or consist of alphanumeric characters",
+			},
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.msg, func(t *testing.T) {
+			t.Parallel()
+
+			labels, taints, invalid := r.resolve(testCase.tags)
+			assert.Equal(t, testCase.expectedLabels, labels)
+			assert.Equal(t, testCase.expectedTaints, taints)
+			if assert.Equal(t, len(testCase.expectedInvalid), len(invalid)) {
+				for idx := range invalid {
+					assert.True(t, strings.HasPrefix(invalid[idx], testCase.expectedInvalid[idx]), invalid[idx])
+				}
+			}
+		})
+	}
+}
+
+func TestSyncTagRules(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	cfg, err := providerconfig.ReadCloudConfigFromFile("../../test/config/cluster-config-1.yaml")
+	assert.Nil(t, err)
+
+	px, err := proxmoxpool.NewProxmoxPool(cfg.Clusters)
+	assert.Nil(t, err)
+
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster-1-node-1",
+			Labels: map[string]string{
+				"node-role.kubernetes.io/ingress": "",
+			},
+			Annotations: map[string]string{
+				AnnotationProxmoxTagLabels: "node-role.kubernetes.io/ingress",
+				AnnotationProxmoxTagTaints: "ingress:NoSchedule",
+			},
+		},
+		Spec: v1.NodeSpec{
+			ProviderID: "proxmox://cluster-1/100",
+			Taints:     []v1.Taint{{Key: "ingress", Value: "true", Effect: v1.TaintEffectNoSchedule}},
+		},
+		Status: v1.NodeStatus{
+			NodeInfo: v1.NodeSystemInfo{SystemUUID: "11833f4c-341f-4bd3-aad7-f7abed000000"},
+		},
+	}
+
+	kclient := fake.NewClientset(node)
+	i := newInstances(&client{pxpool: px, kclient: kclient}, providerconfig.ClustersFeatures{TagRules: testTagRules})
+
+	// The node is not changed before the initialization
+	uninitialized := node.DeepCopy()
+	uninitialized.Spec.Taints = append(uninitialized.Spec.Taints, *uninitializedTaint)
+
+	meta, err := i.InstanceMetadata(t.Context(), uninitialized)
+	assert.Nil(t, err)
+	assert.Equal(t, "prod", meta.AdditionalLabels["env"])
+
+	stored, err := kclient.CoreV1().Nodes().Get(t.Context(), "cluster-1-node-1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, node, stored)
+
+	meta, err = i.InstanceMetadata(t.Context(), node)
+	assert.Nil(t, err)
+	assert.Equal(t, "prod", meta.AdditionalLabels["env"])
+	assert.Contains(t, meta.AdditionalLabels, "node-role.kubernetes.io/gpu")
+
+	node, err = kclient.CoreV1().Nodes().Get(t.Context(), "cluster-1-node-1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "prod", node.Labels["env"])
+	assert.Contains(t, node.Labels, "node-role.kubernetes.io/gpu")
+	assert.NotContains(t, node.Labels, "node-role.kubernetes.io/ingress")
+	assert.Equal(t, "env,node-role.kubernetes.io/gpu", node.Annotations[AnnotationProxmoxTagLabels])
+	assert.Equal(t, "dedicated:NoSchedule", node.Annotations[AnnotationProxmoxTagTaints])
+	assert.Equal(t, []v1.Taint{{Key: "dedicated", Value: "gpu", Effect: v1.TaintEffectNoSchedule}}, node.Spec.Taints)
+
+	// The rules do not match anymore
+	err = i.syncTagRules(t.Context(), node, nil)
+	assert.Nil(t, err)
+
+	node, err = kclient.CoreV1().Nodes().Get(t.Context(), "cluster-1-node-1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.NotContains(t, node.Labels, "env")
+	assert.NotContains(t, node.Labels, "node-role.kubernetes.io/gpu")
+	assert.Empty(t, node.Annotations)
+	assert.Empty(t, node.Spec.Taints)
+}
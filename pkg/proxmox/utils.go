@@ -182,3 +182,29 @@ func syncNodeLabels(c *client, node *corev1.Node, nodeLabels map[string]string)
 
 	return nil
 }
+
+// removeNodeLabels removes the labels from the node, the labels which the node does not have are skipped.
+func removeNodeLabels(ctx context.Context, kclient clientkubernetes.Interface, node *corev1.Node, keys ...string) error {
+	patch := map[string]any{}
+
+	for _, k := range keys {
+		if _, ok := node.Labels[k]; ok {
+			patch[k] = nil
+		}
+	}
+
+	if len(patch) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(map[string]any{"metadata": map[string]any{"labels": patch}})
+	if err != nil {
+		return fmt.Errorf("failed to marshal the patch: %w", err)
+	}
+
+	if _, err := kclient.CoreV1().Nodes().Patch(ctx, node.Name, types.MergePatchType, data, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("failed to patch node %s: %w", node.Name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,301 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmox
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	goproxmox "github.com/sergelogvinov/go-proxmox"
+	providerconfig "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/config"
+	metrics "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/metrics"
+	provider "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/provider"
+	"github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/proxmoxpool"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/klog/v2"
+)
+
+// zones implements the legacy cloudprovider.Zones interface on top of the instances zone logic.
+type zones struct {
+	i *instances
+}
+
+func newZones(i *instances) *zones {
+	return &zones{i: i}
+}
+
+// GetZone returns the Zone containing the current failure zone and locality region that the program is running in.
+// The CCM does not run inside the Proxmox VM, so it is not implemented.
+func (z *zones) GetZone(_ context.Context) (cloudprovider.Zone, error) {
+	return cloudprovider.Zone{}, cloudprovider.NotImplemented
+}
+
+// GetZoneByProviderID returns the Zone containing the current zone and locality region of the node specified by providerID.
+func (z *zones) GetZoneByProviderID(ctx context.Context, providerID string) (cloudprovider.Zone, error) {
+	klog.V(4).InfoS("zones.GetZoneByProviderID() called", "providerID", providerID)
+
+	if !strings.HasPrefix(providerID, provider.ProviderName) {
+		return cloudprovider.Zone{}, fmt.Errorf("foreign providerID or empty \"%s\"", providerID)
+	}
+
+	vmID, region, err := provider.ParseProviderID(providerID)
+	if err != nil {
+		mc := metrics.NewMetricContext("findVmByUUID")
+
+		vmID, region, err = z.i.c.pxpool.FindVMByUUID(ctx, strings.TrimPrefix(providerID, provider.ProviderName+"://"))
+		if mc.ObserveRequest(err) != nil {
+			if errors.Is(err, proxmoxpool.ErrInstanceNotFound) {
+				return cloudprovider.Zone{}, cloudprovider.InstanceNotFound
+			}
+
+			return cloudprovider.Zone{}, err
+		}
+	}
+
+	if provider.IsContainer(providerID) {
+		mc := metrics.NewMetricContext("getContainerConfig")
+
+		ct, err := z.i.c.pxpool.GetContainerConfig(ctx, region, vmID)
+		if mc.ObserveRequest(err) != nil {
+			if errors.Is(err, proxmoxpool.ErrInstanceNotFound) {
+				return cloudprovider.Zone{}, cloudprovider.InstanceNotFound
+			}
+
+			return cloudprovider.Zone{}, err
+		}
+
+		return z.getZone(ctx, &instanceInfo{
+			ID:        vmID,
+			Name:      ct.Name,
+			Node:      ct.Node,
+			Region:    region,
+			Zone:      ct.Node,
+			GuestType: proxmoxpool.GuestTypeContainer,
+		})
+	}
+
+	px, err := z.i.c.pxpool.GetProxmoxCluster(region)
+	if err != nil {
+		return cloudprovider.Zone{}, err
+	}
+
+	mc := metrics.NewMetricContext("getVMConfig")
+
+	vm, err := px.GetVMConfig(ctx, vmID)
+	if mc.ObserveRequest(err) != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return cloudprovider.Zone{}, cloudprovider.InstanceNotFound
+		}
+
+		if errors.Is(err, goproxmox.ErrVirtualMachineUnreachable) {
+			return cloudprovider.Zone{}, proxmoxpool.ErrNodeInaccessible
+		}
+
+		return cloudprovider.Zone{}, err
+	}
+
+	return z.getZone(ctx, &instanceInfo{
+		ID:     vmID,
+		Name:   vm.Name,
+		Node:   vm.Node,
+		Region: region,
+		Zone:   vm.Node,
+	})
+}
+
+// GetZoneByNodeName returns the Zone containing the current zone and locality region of the node specified by node name.
+func (z *zones) GetZoneByNodeName(ctx context.Context, nodeName types.NodeName) (cloudprovider.Zone, error) {
+	klog.V(4).InfoS("zones.GetZoneByNodeName() called", "node", klog.KRef("", string(nodeName)))
+
+	node, err := z.i.c.kclient.CoreV1().Nodes().Get(ctx, string(nodeName), metav1.GetOptions{})
+	if err != nil {
+		return cloudprovider.Zone{}, fmt.Errorf("failed to get node %s: %w", nodeName, err)
+	}
+
+	mc := metrics.NewMetricContext("getInstanceInfo")
+
+	info, err := z.i.getInstanceInfo(ctx, node)
+	if mc.ObserveRequest(err) != nil {
+		return cloudprovider.Zone{}, err
+	}
+
+	return z.getZone(ctx, info)
+}
+
+func (z *zones) getZone(ctx context.Context, info *instanceInfo) (cloudprovider.Zone, error) {
+	zone, _, err := z.i.getInstanceZone(ctx, info)
+	if err != nil {
+		return cloudprovider.Zone{}, err
+	}
+
+	return cloudprovider.Zone{
+		FailureDomain: zone,
+		Region:        info.Region,
+	}, nil
+}
+
+// selectHAGroup returns the HA group used as a zone, the groups are ordered by the preference list,
+// the restricted flag if it is preferred, the highest priority of the Proxmox node if it is preferred, and the name.
+func selectHAGroup(groups []proxmoxpool.HAGroup, opts providerconfig.HAGroupZoneOpts) proxmoxpool.HAGroup {
+	preference := func(g proxmoxpool.HAGroup) int {
+		if idx := slices.Index(opts.Preference, g.Name); idx >= 0 {
+			return idx
+		}
+
+		return len(opts.Preference)
+	}
+
+	restricted := func(g proxmoxpool.HAGroup) int {
+		if opts.PreferRestricted && g.Restricted {
+			return 0
+		}
+
+		return 1
+	}
+
+	priority := func(g proxmoxpool.HAGroup) int {
+		if opts.ByPriority {
+			return -g.Priority
+		}
+
+		return 0
+	}
+
+	return slices.MinFunc(groups, func(a, b proxmoxpool.HAGroup) int {
+		return cmp.Or(
+			cmp.Compare(preference(a), preference(b)),
+			cmp.Compare(restricted(a), restricted(b)),
+			cmp.Compare(priority(a), priority(b)),
+			strings.Compare(a.Name, b.Name),
+		)
+	})
+}
+
+// zoneResolver derives the zone of the instance from its Proxmox node with the configured strategy.
+type zoneResolver struct {
+	providerconfig.ZoneOpts
+
+	haGroupZone   providerconfig.HAGroupZoneOpts
+	re            *regexp.Regexp
+	descriptionRe *regexp.Regexp
+}
+
+func newZoneResolver(features providerconfig.ClustersFeatures) *zoneResolver {
+	z := &zoneResolver{
+		ZoneOpts:    features.Zone,
+		haGroupZone: features.HAGroupZone,
+	}
+
+	switch {
+	case z.Strategy != "":
+	case features.HAGroup:
+		z.Strategy = providerconfig.ZoneStrategyHAGroup
+	case features.PoolAsZone:
+		z.Strategy = providerconfig.ZoneStrategyPool
+	default:
+		z.Strategy = providerconfig.ZoneStrategyNode
+	}
+
+	if z.DescriptionKey == "" {
+		z.DescriptionKey = "zone"
+	}
+
+	if z.CephFailureDomain == "" {
+		z.CephFailureDomain = "rack"
+	}
+
+	if z.Strategy == providerconfig.ZoneStrategyRegex {
+		re, err := regexp.Compile(z.Regex)
+		if err != nil {
+			klog.ErrorS(err, "Failed to parse the zone regex", "regex", z.Regex)
+		} else {
+			z.re = re
+		}
+	}
+
+	z.descriptionRe = regexp.MustCompile(`(?m)(?:^|[\s,;])` + regexp.QuoteMeta(z.DescriptionKey) + `\s*[:=]\s*([^\s,;]+)`)
+
+	return z
+}
+
+// zone returns the zone of the instance, the HA groups of the instance are used by the ha_group strategy.
+func (z *zoneResolver) zone(ctx context.Context, pool *proxmoxpool.ProxmoxPool, info *instanceInfo, haGroups []proxmoxpool.HAGroup) (string, error) {
+	switch z.Strategy {
+	case providerconfig.ZoneStrategyHAGroup:
+		if len(haGroups) == 0 {
+			return "", fmt.Errorf("cannot set zone as HA-Group")
+		}
+
+		return selectHAGroup(haGroups, z.haGroupZone).Name, nil
+	case providerconfig.ZoneStrategyPool:
+		if info.Pool == "" {
+			return "", fmt.Errorf("cannot set zone as resource pool")
+		}
+
+		return labelValue(info.Pool), nil
+	case providerconfig.ZoneStrategyStatic:
+		if zone, ok := z.Static[info.Node]; ok {
+			return zone, nil
+		}
+
+		return "", fmt.Errorf("proxmox node %s is not in the static zone map", info.Node)
+	case providerconfig.ZoneStrategyRegex:
+		if z.re == nil {
+			return "", fmt.Errorf("invalid zone regex %s", z.Regex)
+		}
+
+		m := z.re.FindStringSubmatch(info.Node)
+		if m == nil {
+			return "", fmt.Errorf("proxmox node %s does not match the zone regex %s", info.Node, z.Regex)
+		}
+
+		if len(m) > 1 {
+			return labelValue(m[1]), nil
+		}
+
+		return labelValue(m[0]), nil
+	case providerconfig.ZoneStrategyHostDescription:
+		cfg, err := pool.GetNodeConfig(ctx, info.Region, info.Node)
+		if err != nil {
+			return "", err
+		}
+
+		m := z.descriptionRe.FindStringSubmatch(cfg.Description)
+		if m == nil {
+			return "", fmt.Errorf("proxmox node %s description does not have the %s key", info.Node, z.DescriptionKey)
+		}
+
+		return labelValue(m[1]), nil
+	case providerconfig.ZoneStrategyCeph:
+		domain, err := pool.GetCephFailureDomain(ctx, info.Region, info.Node, z.CephFailureDomain)
+		if err != nil {
+			return "", err
+		}
+
+		return labelValue(domain), nil
+	}
+
+	return info.Zone, nil
+}
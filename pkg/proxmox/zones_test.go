@@ -0,0 +1,287 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmox
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	proxmox "github.com/luthermonson/go-proxmox"
+	"github.com/stretchr/testify/assert"
+
+	providerconfig "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/config"
+	"github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/proxmoxpool"
+	testcluster "github.com/sergelogvinov/proxmox-cloud-controller-manager/test/cluster"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	cloudprovider "k8s.io/cloud-provider"
+)
+
+func newTestZones(t *testing.T, features providerconfig.ClustersFeatures) *zones {
+	t.Helper()
+
+	cfg, err := providerconfig.ReadCloudConfigFromFile("../../test/config/cluster-config-1.yaml")
+	assert.Nil(t, err)
+
+	px, err := proxmoxpool.NewProxmoxPool(cfg.Clusters)
+	assert.Nil(t, err)
+
+	kclient := fake.NewClientset(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-1-node-1"},
+		Status: v1.NodeStatus{
+			NodeInfo: v1.NodeSystemInfo{SystemUUID: "11833f4c-341f-4bd3-aad7-f7abed000000"},
+		},
+	})
+
+	return newZones(newInstances(&client{pxpool: px, kclient: kclient}, features))
+}
+
+func TestZones(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	z := newTestZones(t, providerconfig.ClustersFeatures{})
+
+	_, err := z.GetZone(t.Context())
+	assert.Equal(t, cloudprovider.NotImplemented, err)
+
+	zone, err := z.GetZoneByProviderID(t.Context(), "proxmox://cluster-1/100")
+	assert.Nil(t, err)
+	assert.Equal(t, cloudprovider.Zone{Region: "cluster-1", FailureDomain: "pve-1"}, zone)
+
+	zone, err = z.GetZoneByProviderID(t.Context(), "proxmox://11833f4c-341f-4bd3-aad7-f7abed000001")
+	assert.Nil(t, err)
+	assert.Equal(t, cloudprovider.Zone{Region: "cluster-1", FailureDomain: "pve-2"}, zone)
+
+	_, err = z.GetZoneByProviderID(t.Context(), "foreign://provider-id")
+	assert.NotNil(t, err)
+
+	zone, err = z.GetZoneByNodeName(t.Context(), "cluster-1-node-1")
+	assert.Nil(t, err)
+	assert.Equal(t, cloudprovider.Zone{Region: "cluster-1", FailureDomain: "pve-1"}, zone)
+
+	_, err = z.GetZoneByNodeName(t.Context(), "unknown")
+	assert.NotNil(t, err)
+}
+
+func TestZonesHAGroup(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	z := newTestZones(t, providerconfig.ClustersFeatures{HAGroup: true})
+
+	zone, err := z.GetZoneByProviderID(t.Context(), "proxmox://cluster-1/100")
+	assert.Nil(t, err)
+	assert.Equal(t, cloudprovider.Zone{Region: "cluster-1", FailureDomain: "rnd"}, zone)
+
+	zone, err = z.GetZoneByNodeName(t.Context(), "cluster-1-node-1")
+	assert.Nil(t, err)
+	assert.Equal(t, cloudprovider.Zone{Region: "cluster-1", FailureDomain: "rnd"}, zone)
+}
+
+func TestZonesHARules(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	httpmock.RegisterResponder(http.MethodGet, `=~/version$`,
+		httpmock.NewJsonResponderOrPanic(200, map[string]any{"data": proxmox.Version{Version: "9.0.3"}}))
+
+	z := newTestZones(t, providerconfig.ClustersFeatures{HAGroup: true})
+
+	zone, err := z.GetZoneByProviderID(t.Context(), "proxmox://cluster-1/100")
+	assert.Nil(t, err)
+	assert.Equal(t, cloudprovider.Zone{Region: "cluster-1", FailureDomain: "gpu"}, zone)
+
+	// The node affinity rule with the highest node priority
+	z = newTestZones(t, providerconfig.ClustersFeatures{HAGroup: true, HAGroupZone: providerconfig.HAGroupZoneOpts{ByPriority: true}})
+
+	zone, err = z.GetZoneByProviderID(t.Context(), "proxmox://cluster-1/100")
+	assert.Nil(t, err)
+	assert.Equal(t, cloudprovider.Zone{Region: "cluster-1", FailureDomain: "rnd"}, zone)
+
+	zone, err = z.GetZoneByProviderID(t.Context(), "proxmox://cluster-1/101")
+	assert.Nil(t, err)
+	assert.Equal(t, cloudprovider.Zone{Region: "cluster-1", FailureDomain: "rnd"}, zone)
+
+	// The strict node affinity rule has the priority
+	z = newTestZones(t, providerconfig.ClustersFeatures{HAGroup: true, HAGroupZone: providerconfig.HAGroupZoneOpts{PreferRestricted: true}})
+
+	zone, err = z.GetZoneByProviderID(t.Context(), "proxmox://cluster-1/100")
+	assert.Nil(t, err)
+	assert.Equal(t, cloudprovider.Zone{Region: "cluster-1", FailureDomain: "gpu"}, zone)
+}
+
+func TestSelectHAGroup(t *testing.T) {
+	t.Parallel()
+
+	groups := []proxmoxpool.HAGroup{
+		{Name: "a", Priority: 1},
+		{Name: "b", Priority: 3},
+		{Name: "c", Priority: 3},
+		{Name: "d", Restricted: true},
+	}
+
+	tests := []struct {
+		msg      string
+		opts     providerconfig.HAGroupZoneOpts
+		expected string
+	}{
+		{msg: "Name", expected: "a"},
+		{msg: "Priority", opts: providerconfig.HAGroupZoneOpts{ByPriority: true}, expected: "b"},
+		{msg: "Restricted", opts: providerconfig.HAGroupZoneOpts{PreferRestricted: true}, expected: "d"},
+		{msg: "Preference", opts: providerconfig.HAGroupZoneOpts{Preference: []string{"x", "c", "a"}, PreferRestricted: true}, expected: "c"},
+		{msg: "PreferenceNotFound", opts: providerconfig.HAGroupZoneOpts{Preference: []string{"x"}, ByPriority: true}, expected: "b"},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.msg, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, testCase.expected, selectHAGroup(groups, testCase.opts).Name)
+		})
+	}
+}
+
+func TestZoneStrategies(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	tests := []struct {
+		msg      string
+		zone     providerconfig.ZoneOpts
+		info     *instanceInfo
+		expected string
+		err      bool
+	}{
+		{
+			msg:      "Node",
+			info:     &instanceInfo{ID: 100, Node: "pve-1", Region: "cluster-1", Zone: "pve-1"},
+			expected: "pve-1",
+		},
+		{
+			msg:      "HAGroup",
+			zone:     providerconfig.ZoneOpts{Strategy: providerconfig.ZoneStrategyHAGroup},
+			info:     &instanceInfo{ID: 100, Node: "pve-1", Region: "cluster-1", Zone: "pve-1"},
+			expected: "rnd",
+		},
+		{
+			msg:      "Pool",
+			zone:     providerconfig.ZoneOpts{Strategy: providerconfig.ZoneStrategyPool},
+			info:     &instanceInfo{ID: 100, Node: "pve-1", Region: "cluster-1", Zone: "pve-1", Pool: "Team A"},
+			expected: "Team-A",
+		},
+		{
+			msg:  "PoolNotMember",
+			zone: providerconfig.ZoneOpts{Strategy: providerconfig.ZoneStrategyPool},
+			info: &instanceInfo{ID: 100, Node: "pve-1", Region: "cluster-1", Zone: "pve-1"},
+			err:  true,
+		},
+		{
+			msg:      "Static",
+			zone:     providerconfig.ZoneOpts{Strategy: providerconfig.ZoneStrategyStatic, Static: map[string]string{"pve-1": "zone-a", "pve-2": "zone-b"}},
+			info:     &instanceInfo{ID: 101, Node: "pve-2", Region: "cluster-1", Zone: "pve-2"},
+			expected: "zone-b",
+		},
+		{
+			msg:  "StaticNotMapped",
+			zone: providerconfig.ZoneOpts{Strategy: providerconfig.ZoneStrategyStatic, Static: map[string]string{"pve-1": "zone-a"}},
+			info: &instanceInfo{ID: 101, Node: "pve-2", Region: "cluster-1", Zone: "pve-2"},
+			err:  true,
+		},
+		{
+			msg:      "Regex",
+			zone:     providerconfig.ZoneOpts{Strategy: providerconfig.ZoneStrategyRegex, Regex: `^(pve)-\d+$`},
+			info:     &instanceInfo{ID: 100, Node: "pve-1", Region: "cluster-1", Zone: "pve-1"},
+			expected: "pve",
+		},
+		{
+			msg:      "RegexWholeMatch",
+			zone:     providerconfig.ZoneOpts{Strategy: providerconfig.ZoneStrategyRegex, Regex: `^[a-z]+`},
+			info:     &instanceInfo{ID: 100, Node: "pve-1", Region: "cluster-1", Zone: "pve-1"},
+			expected: "pve",
+		},
+		{
+			msg:  "RegexNoMatch",
+			zone: providerconfig.ZoneOpts{Strategy: providerconfig.ZoneStrategyRegex, Regex: `^node-(\d+)$`},
+			info: &instanceInfo{ID: 100, Node: "pve-1", Region: "cluster-1", Zone: "pve-1"},
+			err:  true,
+		},
+		{
+			msg:      "HostDescriptionLine",
+			zone:     providerconfig.ZoneOpts{Strategy: providerconfig.ZoneStrategyHostDescription},
+			info:     &instanceInfo{ID: 100, Node: "pve-1", Region: "cluster-1", Zone: "pve-1"},
+			expected: "dc-1",
+		},
+		{
+			msg:      "HostDescriptionTag",
+			zone:     providerconfig.ZoneOpts{Strategy: providerconfig.ZoneStrategyHostDescription},
+			info:     &instanceInfo{ID: 101, Node: "pve-2", Region: "cluster-1", Zone: "pve-2"},
+			expected: "dc-2",
+		},
+		{
+			msg:  "HostDescriptionNoKey",
+			zone: providerconfig.ZoneOpts{Strategy: providerconfig.ZoneStrategyHostDescription, DescriptionKey: "datacenter"},
+			info: &instanceInfo{ID: 100, Node: "pve-1", Region: "cluster-1", Zone: "pve-1"},
+			err:  true,
+		},
+		{
+			msg:      "Ceph",
+			zone:     providerconfig.ZoneOpts{Strategy: providerconfig.ZoneStrategyCeph},
+			info:     &instanceInfo{ID: 101, Node: "pve-2", Region: "cluster-1", Zone: "pve-2"},
+			expected: "rack-2",
+		},
+		{
+			msg:      "CephRoot",
+			zone:     providerconfig.ZoneOpts{Strategy: providerconfig.ZoneStrategyCeph, CephFailureDomain: "root"},
+			info:     &instanceInfo{ID: 100, Node: "pve-1", Region: "cluster-1", Zone: "pve-1"},
+			expected: "default",
+		},
+		{
+			msg:  "CephNoRack",
+			zone: providerconfig.ZoneOpts{Strategy: providerconfig.ZoneStrategyCeph},
+			info: &instanceInfo{ID: 104, Node: "pve-4", Region: "cluster-1", Zone: "pve-4"},
+			err:  true,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.msg, func(t *testing.T) {
+			z := newTestZones(t, providerconfig.ClustersFeatures{Zone: testCase.zone})
+
+			zone, _, err := z.i.getInstanceZone(t.Context(), testCase.info)
+			if testCase.err {
+				assert.NotNil(t, err)
+
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, testCase.expected, zone)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmoxpool
+
+import (
+	"context"
+	"fmt"
+
+	proxmox "github.com/luthermonson/go-proxmox"
+
+	goproxmox "github.com/sergelogvinov/go-proxmox"
+)
+
+// GetClusterName returns the name of the Proxmox cluster in a given region.
+func (c *ProxmoxPool) GetClusterName(ctx context.Context, region string) (string, error) {
+	px, err := c.GetProxmoxCluster(region)
+	if err != nil {
+		return "", err
+	}
+
+	return getClusterName(ctx, px)
+}
+
+// resolveClusterName returns the Proxmox cluster name, it is used to resolve the region at startup.
+func resolveClusterName(px *goproxmox.APIClient) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultRegionTimeout)
+	defer cancel()
+
+	return getClusterName(ctx, px)
+}
+
+// getClusterName returns the cluster name from /cluster/status, a standalone Proxmox node does not have it.
+func getClusterName(ctx context.Context, px *goproxmox.APIClient) (string, error) {
+	members := proxmox.NodeStatuses{}
+	if err := px.Get(ctx, "/cluster/status", &members); err != nil {
+		return "", fmt.Errorf("error get cluster status: %w", err)
+	}
+
+	for _, m := range members {
+		if m.Type == "cluster" && m.Name != "" {
+			return m.Name, nil
+		}
+	}
+
+	return "", ErrClusterNameNotFound
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmoxpool
+
+import (
+	"context"
+	"fmt"
+
+	proxmox "github.com/luthermonson/go-proxmox"
+)
+
+const (
+	// GuestTypeVM is the resource type of the Proxmox virtual machines.
+	GuestTypeVM = "qemu"
+	// GuestTypeContainer is the resource type of the Proxmox LXC containers.
+	GuestTypeContainer = "lxc"
+)
+
+// GetGuestType returns the resource type of the guest, virtual machine or LXC container, in a given region.
+func (c *ProxmoxPool) GetGuestType(ctx context.Context, region string, vmID int) (string, error) {
+	vm, err := c.lookupInventory(ctx, region, func(inv *regionInventory) (*InventoryVM, error) {
+		return inv.byID[uint64(vmID)], nil //nolint: gosec
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if vm == nil {
+		return "", ErrInstanceNotFound
+	}
+
+	return vm.Resource.Type, nil
+}
+
+// GetGuestPool returns the resource pool of the VM or LXC container in a given region.
+// It returns an empty string if the guest is not a member of any pool.
+func (c *ProxmoxPool) GetGuestPool(ctx context.Context, region string, vmID int) (string, error) {
+	vm, err := c.lookupInventory(ctx, region, func(inv *regionInventory) (*InventoryVM, error) {
+		return inv.byID[uint64(vmID)], nil //nolint: gosec
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if vm == nil {
+		return "", ErrInstanceNotFound
+	}
+
+	return vm.Resource.Pool, nil
+}
+
+// GetContainerByIDInRegion returns a Proxmox LXC container by its ID in a given region.
+func (c *ProxmoxPool) GetContainerByIDInRegion(ctx context.Context, region string, vmID int) (*proxmox.ClusterResource, error) {
+	vm, err := c.lookupInventory(ctx, region, func(inv *regionInventory) (*InventoryVM, error) {
+		vm := inv.byID[uint64(vmID)] //nolint: gosec
+		if vm != nil && vm.Resource.Type != GuestTypeContainer {
+			vm = nil
+		}
+
+		return vm, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if vm == nil {
+		return nil, ErrInstanceNotFound
+	}
+
+	return vm.Resource, nil
+}
+
+// GetContainerConfig returns the status and the config of the LXC container in a given region.
+func (c *ProxmoxPool) GetContainerConfig(ctx context.Context, region string, vmID int) (*proxmox.Container, error) {
+	px, err := c.GetProxmoxCluster(region)
+	if err != nil {
+		return nil, err
+	}
+
+	rs, err := c.GetContainerByIDInRegion(ctx, region, vmID)
+	if err != nil {
+		return nil, err
+	}
+
+	if rs.Status == "unknown" {
+		return nil, ErrNodeInaccessible
+	}
+
+	return (&proxmox.Node{}).New(px.Client, rs.Node).Container(ctx, vmID)
+}
+
+// GuestStatus is the current status of the VM or LXC container.
+type GuestStatus struct {
+	Status    string `json:"status"`
+	QMPStatus string `json:"qmpstatus,omitempty"`
+	Lock      string `json:"lock,omitempty"`
+}
+
+// GetGuestStatus returns the current status of the VM or LXC container resource in a given region.
+func (c *ProxmoxPool) GetGuestStatus(ctx context.Context, region string, rs *proxmox.ClusterResource) (*GuestStatus, error) {
+	px, err := c.GetProxmoxCluster(region)
+	if err != nil {
+		return nil, err
+	}
+
+	if rs.Status == "unknown" {
+		return nil, ErrNodeInaccessible
+	}
+
+	status := &GuestStatus{}
+	if err := px.Get(ctx, fmt.Sprintf("/nodes/%s/%s/%d/status/current", rs.Node, rs.Type, rs.VMID), status); err != nil {
+		return nil, fmt.Errorf("error get status of %s %d: %w", rs.Type, rs.VMID, err)
+	}
+
+	return status, nil
+}
+
+// GetGuestByIDInRegion returns the cluster resource of the VM or LXC container by its ID in a given region.
+func (c *ProxmoxPool) GetGuestByIDInRegion(ctx context.Context, region string, vmID int) (*proxmox.ClusterResource, error) {
+	vm, err := c.lookupInventory(ctx, region, func(inv *regionInventory) (*InventoryVM, error) {
+		return inv.byID[uint64(vmID)], nil //nolint: gosec
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if vm == nil {
+		return nil, ErrInstanceNotFound
+	}
+
+	return vm.Resource, nil
+}
+
+// GetGuestProtection returns true if the VM or LXC container has the Proxmox protection flag,
+// the guest and its disks cannot be removed.
+func (c *ProxmoxPool) GetGuestProtection(ctx context.Context, region string, rs *proxmox.ClusterResource) (bool, error) {
+	px, err := c.GetProxmoxCluster(region)
+	if err != nil {
+		return false, err
+	}
+
+	if rs.Status == "unknown" {
+		return false, ErrNodeInaccessible
+	}
+
+	config := struct {
+		Protection proxmox.IntOrBool `json:"protection"`
+	}{}
+	if err := px.Get(ctx, fmt.Sprintf("/nodes/%s/%s/%d/config", rs.Node, rs.Type, rs.VMID), &config); err != nil {
+		return false, fmt.Errorf("error get config of %s %d: %w", rs.Type, rs.VMID, err)
+	}
+
+	return bool(config.Protection), nil
+}
+
+// StopGuestInRegion stops the VM or LXC container in a given region, it does not wait for the task.
+func (c *ProxmoxPool) StopGuestInRegion(ctx context.Context, region string, rs *proxmox.ClusterResource) error {
+	px, err := c.GetProxmoxCluster(region)
+	if err != nil {
+		return err
+	}
+
+	var upid proxmox.UPID
+	if err := px.Post(ctx, fmt.Sprintf("/nodes/%s/%s/%d/status/stop", rs.Node, rs.Type, rs.VMID), nil, &upid); err != nil {
+		return fmt.Errorf("error stop %s %d: %w", rs.Type, rs.VMID, err)
+	}
+
+	return nil
+}
+
+// DeleteGuestInRegion deletes the stopped VM or LXC container in a given region.
+func (c *ProxmoxPool) DeleteGuestInRegion(ctx context.Context, region string, rs *proxmox.ClusterResource) error {
+	if rs.Type != GuestTypeContainer {
+		return c.DeleteVMByIDInRegion(ctx, region, rs)
+	}
+
+	px, err := c.GetProxmoxCluster(region)
+	if err != nil {
+		return err
+	}
+
+	c.InvalidateVM(region, int(rs.VMID)) //nolint: gosec
+
+	if err := px.Delete(ctx, fmt.Sprintf("/nodes/%s/%s/%d", rs.Node, rs.Type, rs.VMID), nil); err != nil {
+		return fmt.Errorf("error delete %s %d: %w", rs.Type, rs.VMID, err)
+	}
+
+	return nil
+}
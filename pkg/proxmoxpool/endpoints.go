@@ -0,0 +1,313 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmoxpool
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	metrics "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/metrics"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// DefaultEndpointProbeInterval is the default interval of the Proxmox API endpoint health probes.
+	DefaultEndpointProbeInterval = 15 * time.Second
+	// endpointProbeTimeout is the deadline of the endpoint health probe.
+	endpointProbeTimeout = 5 * time.Second
+)
+
+// Endpoint is the state of the Proxmox API endpoint of the cluster.
+type Endpoint struct {
+	URL     string
+	Healthy bool
+	Active  bool
+}
+
+type endpoint struct {
+	url     *url.URL
+	healthy bool
+}
+
+// endpointTransport sends the Proxmox API requests to one of the cluster endpoints.
+// The active endpoint is sticky while it is healthy, the request is sent to the next endpoint
+// on a connection error before the request was sent, or on a connection error or a gateway error (502 and above)
+// of an idempotent request. 500 is the error of the Proxmox API call itself.
+type endpointTransport struct {
+	mu        sync.Mutex
+	region    string
+	endpoints []*endpoint
+	active    int
+
+	// base is the transport of the requests, the default transport if it is nil.
+	base http.RoundTripper
+}
+
+func newEndpointTransport(urls []string, base http.RoundTripper) (*endpointTransport, error) {
+	t := &endpointTransport{base: base}
+
+	for _, u := range urls {
+		parsed, err := url.Parse(strings.TrimSuffix(u, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxmox url %s: %w", u, err)
+		}
+
+		t.endpoints = append(t.endpoints, &endpoint{url: parsed, healthy: true})
+	}
+
+	return t, nil
+}
+
+// setRegion sets the region of the endpoint metrics.
+func (t *endpointTransport) setRegion(region string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.region = region
+
+	for idx, ep := range t.endpoints {
+		metrics.EndpointHealth(region, ep.url.Host, ep.healthy)
+		metrics.EndpointActive(region, ep.url.Host, idx == t.active)
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *endpointTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var (
+		resp *http.Response
+		err  error
+	)
+
+	for attempt, idx := range t.order() {
+		if attempt > 0 {
+			// The request body cannot be sent again
+			if req.Body != nil && req.GetBody == nil {
+				break
+			}
+
+			if resp != nil {
+				_, _ = io.Copy(io.Discard, resp.Body)
+				resp.Body.Close() //nolint:errcheck
+			}
+		}
+
+		r, rerr := t.endpointRequest(req, t.endpoints[idx], attempt > 0)
+		if rerr != nil {
+			return nil, rerr
+		}
+
+		var sent atomic.Bool
+
+		r = r.WithContext(httptrace.WithClientTrace(r.Context(), &httptrace.ClientTrace{
+			WroteHeaders: func() { sent.Store(true) },
+		}))
+
+		resp, err = t.transport().RoundTrip(r)
+		if err == nil && resp.StatusCode <= http.StatusNotImplemented {
+			t.setActive(idx)
+
+			return resp, nil
+		}
+
+		if req.Context().Err() != nil {
+			break
+		}
+
+		t.setHealthy(idx, false)
+
+		// The Proxmox API could have executed the request, only the idempotent requests are sent again
+		if !isIdempotent(req.Method) && (err == nil || sent.Load()) {
+			break
+		}
+	}
+
+	return resp, err
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut:
+		return true
+	}
+
+	return false
+}
+
+// order returns the endpoints in the order of the attempts, the active endpoint first,
+// then the healthy endpoints and the unhealthy ones.
+func (t *endpointTransport) order() []int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	healthy := make([]int, 0, len(t.endpoints))
+	unhealthy := []int{}
+
+	for i := range t.endpoints {
+		idx := (t.active + i) % len(t.endpoints)
+		if t.endpoints[idx].healthy {
+			healthy = append(healthy, idx)
+		} else {
+			unhealthy = append(unhealthy, idx)
+		}
+	}
+
+	return append(healthy, unhealthy...)
+}
+
+func (t *endpointTransport) transport() http.RoundTripper {
+	if t.base != nil {
+		return t.base
+	}
+
+	return http.DefaultTransport
+}
+
+// endpointRequest returns the copy of the request to the endpoint,
+// the request path is relative to the first endpoint, which is the base URL of the client.
+func (t *endpointTransport) endpointRequest(req *http.Request, ep *endpoint, retry bool) (*http.Request, error) {
+	r := req.Clone(req.Context())
+	r.Host = ""
+	r.URL.Scheme = ep.url.Scheme
+	r.URL.Host = ep.url.Host
+	r.URL.Path = ep.url.Path + strings.TrimPrefix(req.URL.Path, t.endpoints[0].url.Path)
+	r.URL.RawPath = ""
+
+	if retry && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+
+		r.Body = body
+	}
+
+	return r, nil
+}
+
+func (t *endpointTransport) setHealthy(idx int, healthy bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ep := t.endpoints[idx]
+	if ep.healthy != healthy {
+		klog.InfoS("Proxmox API endpoint health changed", "region", t.region, "endpoint", ep.url.Host, "healthy", healthy)
+	}
+
+	if !healthy && idx == t.active {
+		metrics.EndpointFailover(t.region, ep.url.Host)
+	}
+
+	ep.healthy = healthy
+	metrics.EndpointHealth(t.region, ep.url.Host, healthy)
+}
+
+func (t *endpointTransport) setActive(idx int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.endpoints[idx].healthy = true
+	metrics.EndpointHealth(t.region, t.endpoints[idx].url.Host, true)
+
+	if t.active == idx {
+		return
+	}
+
+	klog.InfoS("Proxmox API endpoint switched", "region", t.region,
+		"from", t.endpoints[t.active].url.Host, "to", t.endpoints[idx].url.Host)
+
+	metrics.EndpointActive(t.region, t.endpoints[t.active].url.Host, false)
+	metrics.EndpointActive(t.region, t.endpoints[idx].url.Host, true)
+
+	t.active = idx
+}
+
+// probe checks the health of the endpoints, any response below 500 means the Proxmox API is up.
+// The probe does not authenticate, the unauthorized response is expected.
+func (t *endpointTransport) probe(ctx context.Context) {
+	for idx, ep := range t.endpoints {
+		pctx, cancel := context.WithTimeout(ctx, endpointProbeTimeout)
+
+		healthy := false
+
+		req, err := http.NewRequestWithContext(pctx, http.MethodGet, ep.url.String()+"/version", nil)
+		if err == nil {
+			resp, err := t.transport().RoundTrip(req)
+			if err == nil {
+				_, _ = io.Copy(io.Discard, resp.Body)
+				resp.Body.Close() //nolint:errcheck
+
+				healthy = resp.StatusCode < http.StatusInternalServerError
+			}
+		}
+
+		cancel()
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		t.setHealthy(idx, healthy)
+	}
+}
+
+func (t *endpointTransport) status() []Endpoint {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	endpoints := make([]Endpoint, 0, len(t.endpoints))
+	for idx, ep := range t.endpoints {
+		endpoints = append(endpoints, Endpoint{URL: ep.url.String(), Healthy: ep.healthy, Active: idx == t.active})
+	}
+
+	return endpoints
+}
+
+// GetEndpoints returns the state of the Proxmox API endpoints in a given region.
+// The region with a single url does not have the endpoint state.
+func (c *ProxmoxPool) GetEndpoints(region string) []Endpoint {
+	if t, ok := c.endpoints[region]; ok {
+		return t.status()
+	}
+
+	return nil
+}
+
+// ProbeEndpoints checks the health of the Proxmox API endpoints of the regions with several urls.
+func (c *ProxmoxPool) ProbeEndpoints(ctx context.Context) {
+	for _, t := range c.endpoints {
+		t.probe(ctx)
+	}
+}
+
+// RunEndpointProbes checks the health of the Proxmox API endpoints periodically until the context is done.
+func (c *ProxmoxPool) RunEndpointProbes(ctx context.Context) {
+	if len(c.endpoints) == 0 {
+		return
+	}
+
+	wait.UntilWithContext(ctx, c.ProbeEndpoints, DefaultEndpointProbeInterval)
+}
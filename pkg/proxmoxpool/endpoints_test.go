@@ -0,0 +1,152 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmoxpool_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+
+	pxpool "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/proxmoxpool"
+	testcluster "github.com/sergelogvinov/proxmox-cloud-controller-manager/test/cluster"
+)
+
+func newEndpointsEnv() []*pxpool.ProxmoxCluster {
+	return []*pxpool.ProxmoxCluster{
+		{
+			URLs: []string{
+				"https://127.0.0.3:8006/api2/json",
+				"https://127.0.0.1:8006/api2/json",
+			},
+			TokenID:     "user!token-id",
+			TokenSecret: "secret",
+			Region:      "cluster-1",
+		},
+	}
+}
+
+func TestEndpointsFailover(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	httpmock.RegisterResponder(http.MethodGet, "https://127.0.0.3:8006/api2/json/cluster/status",
+		httpmock.NewErrorResponder(errors.New("connection refused")))
+
+	pool, err := pxpool.NewProxmoxPool(newEndpointsEnv())
+	assert.Nil(t, err)
+	assert.Equal(t, []pxpool.Endpoint{
+		{URL: "https://127.0.0.3:8006/api2/json", Healthy: true, Active: true},
+		{URL: "https://127.0.0.1:8006/api2/json", Healthy: true},
+	}, pool.GetEndpoints("cluster-1"))
+
+	name, err := pool.GetClusterName(t.Context(), "cluster-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "cluster-1", name)
+	assert.Equal(t, []pxpool.Endpoint{
+		{URL: "https://127.0.0.3:8006/api2/json"},
+		{URL: "https://127.0.0.1:8006/api2/json", Healthy: true, Active: true},
+	}, pool.GetEndpoints("cluster-1"))
+
+	// The active endpoint is sticky
+	_, err = pool.GetClusterName(t.Context(), "cluster-1")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, httpmock.GetCallCountInfo()["GET https://127.0.0.3:8006/api2/json/cluster/status"])
+
+	// The gateway error of the active endpoint
+	httpmock.RegisterResponder(http.MethodGet, "https://127.0.0.1:8006/api2/json/cluster/status",
+		httpmock.NewStringResponder(http.StatusBadGateway, ""))
+	httpmock.RegisterResponder(http.MethodGet, "https://127.0.0.3:8006/api2/json/cluster/status",
+		httpmock.NewJsonResponderOrPanic(200, map[string]any{"data": []map[string]any{{"type": "cluster", "name": "cluster-1"}}}))
+
+	_, err = pool.GetClusterName(t.Context(), "cluster-1")
+	assert.Nil(t, err)
+	assert.Equal(t, []pxpool.Endpoint{
+		{URL: "https://127.0.0.3:8006/api2/json", Healthy: true, Active: true},
+		{URL: "https://127.0.0.1:8006/api2/json"},
+	}, pool.GetEndpoints("cluster-1"))
+
+	// The API error is not an endpoint failure
+	httpmock.RegisterResponder(http.MethodGet, "https://127.0.0.3:8006/api2/json/cluster/status",
+		httpmock.NewStringResponder(http.StatusInternalServerError, ""))
+
+	calls := httpmock.GetCallCountInfo()["GET https://127.0.0.1:8006/api2/json/cluster/status"]
+
+	_, err = pool.GetClusterName(t.Context(), "cluster-1")
+	assert.NotNil(t, err)
+	assert.Equal(t, calls, httpmock.GetCallCountInfo()["GET https://127.0.0.1:8006/api2/json/cluster/status"])
+	assert.True(t, pool.GetEndpoints("cluster-1")[0].Healthy)
+	assert.True(t, pool.GetEndpoints("cluster-1")[0].Active)
+
+	// The connection error before the request was sent fails over the non-idempotent request
+	const (
+		subnets3 = "https://127.0.0.3:8006/api2/json/cluster/sdn/vnets/pods/subnets"
+		subnets1 = "https://127.0.0.1:8006/api2/json/cluster/sdn/vnets/pods/subnets"
+	)
+
+	httpmock.RegisterResponder(http.MethodPost, subnets3, httpmock.NewErrorResponder(errors.New("connection refused")))
+	httpmock.RegisterResponder(http.MethodPost, subnets1, httpmock.NewJsonResponderOrPanic(200, map[string]any{"data": nil}))
+
+	err = pool.CreateSDNSubnet(t.Context(), "cluster-1", "pods", "10.244.0.0/24")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, httpmock.GetCallCountInfo()["POST "+subnets1])
+	assert.True(t, pool.GetEndpoints("cluster-1")[1].Active)
+
+	// The gateway error of the non-idempotent request is not sent to the next endpoint
+	httpmock.RegisterResponder(http.MethodPost, subnets1, httpmock.NewStringResponder(http.StatusServiceUnavailable, ""))
+
+	_ = pool.CreateSDNSubnet(t.Context(), "cluster-1", "pods", "10.244.1.0/24")
+	assert.Equal(t, 1, httpmock.GetCallCountInfo()["POST "+subnets1])
+	assert.Equal(t, 1, httpmock.GetCallCountInfo()["POST "+subnets3])
+	assert.False(t, pool.GetEndpoints("cluster-1")[1].Healthy)
+
+	assert.Nil(t, pool.GetEndpoints("cluster-2"))
+}
+
+func TestProbeEndpoints(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	httpmock.RegisterResponder(http.MethodGet, "https://127.0.0.3:8006/api2/json/version",
+		httpmock.NewErrorResponder(errors.New("connection refused")))
+	httpmock.RegisterResponder(http.MethodGet, "https://127.0.0.1:8006/api2/json/version",
+		httpmock.NewStringResponder(http.StatusUnauthorized, ""))
+	httpmock.RegisterResponder(http.MethodGet, "https://127.0.0.3:8006/api2/json/cluster/status",
+		httpmock.NewErrorResponder(errors.New("connection refused")))
+
+	pool, err := pxpool.NewProxmoxPool(newEndpointsEnv())
+	assert.Nil(t, err)
+
+	pool.ProbeEndpoints(t.Context())
+	assert.Equal(t, []pxpool.Endpoint{
+		{URL: "https://127.0.0.3:8006/api2/json", Active: true},
+		{URL: "https://127.0.0.1:8006/api2/json", Healthy: true},
+	}, pool.GetEndpoints("cluster-1"))
+
+	// The healthy endpoint is used first
+	name, err := pool.GetClusterName(t.Context(), "cluster-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "cluster-1", name)
+	assert.Equal(t, 0, httpmock.GetCallCountInfo()["GET https://127.0.0.3:8006/api2/json/cluster/status"])
+	assert.True(t, pool.GetEndpoints("cluster-1")[1].Active)
+}
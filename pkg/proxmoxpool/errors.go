@@ -25,10 +25,18 @@ var (
 	ErrHAGroupNotFound = errors.New("ha-group not found")
 	// ErrRegionNotFound is returned when a region is not found in the Proxmox
 	ErrRegionNotFound = errors.New("region not found")
+	// ErrRegionCollision is returned when several Proxmox clusters have the same region
+	ErrRegionCollision = errors.New("region is used by several clusters")
+	// ErrClusterNameNotFound is returned when the Proxmox node is not a member of a cluster
+	ErrClusterNameNotFound = errors.New("cluster name not found")
 	// ErrZoneNotFound is returned when a zone is not found in the Proxmox
 	ErrZoneNotFound = errors.New("zone not found")
 	// ErrInstanceNotFound is returned when an instance is not found in the Proxmox
 	ErrInstanceNotFound = errors.New("instance not found")
+	// ErrInstanceAmbiguous is returned when an instance matches in several Proxmox clusters
+	ErrInstanceAmbiguous = errors.New("instance matches in several regions")
+	// ErrInstanceUntagged is returned when an instance does not have the Proxmox tag of the Kubernetes cluster
+	ErrInstanceUntagged = errors.New("instance has no cluster tag")
 
 	// ErrNodeInaccessible is returned when a Proxmox node cannot be reached or accessed
 	ErrNodeInaccessible = errors.New("node is inaccessible")
@@ -0,0 +1,193 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmoxpool
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	proxmox "github.com/luthermonson/go-proxmox"
+)
+
+// haRulesMajorVersion is the first Proxmox VE major version with the HA rules instead of the HA groups.
+const haRulesMajorVersion = 9
+
+// HAGroup is the HA group of the Proxmox node, or the node affinity HA rule on Proxmox VE 9.
+type HAGroup struct {
+	Name string
+	// Priority is the priority of the Proxmox node in the group, the default is 0.
+	Priority int
+	// Restricted is true if the HA resources can run only on the group nodes,
+	// the restricted group or the strict node affinity rule.
+	Restricted bool
+}
+
+// haGroupConfig is the Proxmox VE 8 HA group.
+type haGroupConfig struct {
+	Group string `json:"group"`
+	Type  string `json:"type"`
+	// Nodes is the list of the Proxmox nodes with the optional priority, for example pve-1:2,pve-2
+	Nodes      string            `json:"nodes"`
+	Restricted proxmox.IntOrBool `json:"restricted,omitempty"`
+}
+
+// HARule is the Proxmox VE 9 HA rule.
+type HARule struct {
+	Rule string `json:"rule"`
+	// Type is node-affinity or resource-affinity.
+	Type string `json:"type"`
+	// Nodes is the list of the Proxmox nodes with the optional priority, for example pve-1:2,pve-2
+	Nodes string `json:"nodes,omitempty"`
+	// Resources is the list of the HA resources, for example vm:100,ct:105
+	Resources string            `json:"resources"`
+	Strict    proxmox.IntOrBool `json:"strict,omitempty"`
+	Disable   proxmox.IntOrBool `json:"disable,omitempty"`
+}
+
+// NodePriority returns the priority of the Proxmox node in the node affinity rule,
+// and false if the rule does not contain the node.
+func (r *HARule) NodePriority(node string) (int, bool) {
+	return nodePriority(r.Nodes, node)
+}
+
+// HasResource returns true if the rule contains the VM or LXC container.
+func (r *HARule) HasResource(vmID int) bool {
+	id := strconv.Itoa(vmID)
+
+	for res := range strings.SplitSeq(r.Resources, ",") {
+		if _, rid, _ := strings.Cut(strings.TrimSpace(res), ":"); rid == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetGuestHARules returns the enabled node affinity HA rules of the VM or LXC container in a given region,
+// which contain the Proxmox node where the guest is running, sorted by name.
+func (c *ProxmoxPool) GetGuestHARules(ctx context.Context, region string, node string, vmID int) ([]HAGroup, error) {
+	px, err := c.GetProxmoxCluster(region)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := []*HARule{}
+	if err := px.Get(ctx, "/cluster/ha/rules", &rules); err != nil {
+		return nil, fmt.Errorf("error get ha-rules in region %s: %w", region, err)
+	}
+
+	groups := []HAGroup{}
+
+	for _, r := range rules {
+		if r.Type != "node-affinity" || bool(r.Disable) || !r.HasResource(vmID) {
+			continue
+		}
+
+		if priority, ok := r.NodePriority(node); ok {
+			groups = append(groups, HAGroup{Name: r.Rule, Priority: priority, Restricted: bool(r.Strict)})
+		}
+	}
+
+	if len(groups) > 0 {
+		sortHAGroups(groups)
+
+		return groups, nil
+	}
+
+	return nil, ErrHAGroupNotFound
+}
+
+// nodePriority returns the priority of the Proxmox node in the node list, for example pve-1:2,pve-2
+func nodePriority(nodes string, node string) (int, bool) {
+	for n := range strings.SplitSeq(nodes, ",") {
+		name, priority, ok := strings.Cut(strings.TrimSpace(n), ":")
+		if name != node {
+			continue
+		}
+
+		if !ok {
+			return 0, true
+		}
+
+		p, err := strconv.Atoi(priority)
+		if err != nil {
+			return 0, true
+		}
+
+		return p, true
+	}
+
+	return 0, false
+}
+
+func sortHAGroups(groups []HAGroup) {
+	slices.SortFunc(groups, func(a, b HAGroup) int { return strings.Compare(a.Name, b.Name) })
+}
+
+// HAManagerStatus is the status of the Proxmox HA manager.
+type HAManagerStatus struct {
+	ManagerStatus struct {
+		// NodeStatus is the CRM state of the Proxmox nodes: online, maintenance, unknown, fence or gone.
+		NodeStatus map[string]string `json:"node_status"`
+	} `json:"manager_status"`
+	// LRMStatus is the local resource manager status of the Proxmox nodes.
+	LRMStatus map[string]struct {
+		// Mode is active, restart, shutdown or maintenance.
+		Mode string `json:"mode"`
+	} `json:"lrm_status"`
+}
+
+// GetHAManagerStatus returns the HA manager status in a given region.
+func (c *ProxmoxPool) GetHAManagerStatus(ctx context.Context, region string) (*HAManagerStatus, error) {
+	px, err := c.GetProxmoxCluster(region)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &HAManagerStatus{}
+	if err := px.Get(ctx, "/cluster/ha/status/manager_status", status); err != nil {
+		return nil, fmt.Errorf("error get ha manager status in region %s: %w", region, err)
+	}
+
+	return status, nil
+}
+
+// MaintenanceNodes returns the sorted list of the Proxmox nodes in the HA maintenance mode.
+// The node is in maintenance as soon as its local resource manager has the maintenance mode,
+// while the HA resources are still migrating away.
+func (s *HAManagerStatus) MaintenanceNodes() []string {
+	nodes := []string{}
+
+	for node, state := range s.ManagerStatus.NodeStatus {
+		if state == "maintenance" {
+			nodes = append(nodes, node)
+		}
+	}
+
+	for node, lrm := range s.LRMStatus {
+		if lrm.Mode == "maintenance" && !slices.Contains(nodes, node) {
+			nodes = append(nodes, node)
+		}
+	}
+
+	slices.Sort(nodes)
+
+	return nodes
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmoxpool_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	proxmox "github.com/luthermonson/go-proxmox"
+	"github.com/stretchr/testify/assert"
+
+	pxpool "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/proxmoxpool"
+	testcluster "github.com/sergelogvinov/proxmox-cloud-controller-manager/test/cluster"
+)
+
+func TestGetHAManagerStatus(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	pool, err := pxpool.NewProxmoxPool(newClusterEnv())
+	assert.Nil(t, err)
+
+	status, err := pool.GetHAManagerStatus(t.Context(), "cluster-1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"pve-2", "pve-4"}, status.MaintenanceNodes())
+
+	_, err = pool.GetHAManagerStatus(t.Context(), "cluster-3")
+	assert.ErrorIs(t, err, pxpool.ErrRegionNotFound)
+
+	assert.Empty(t, (&pxpool.HAManagerStatus{}).MaintenanceNodes())
+}
+
+func TestHARule(t *testing.T) {
+	t.Parallel()
+
+	r := &pxpool.HARule{Rule: "rnd", Type: "node-affinity", Nodes: "pve-1:2, pve-2,pve-3:x", Resources: "vm:100,ct:105"}
+
+	for node, expected := range map[string]int{"pve-1": 2, "pve-2": 0, "pve-3": 0} {
+		priority, ok := r.NodePriority(node)
+		assert.True(t, ok, node)
+		assert.Equal(t, expected, priority, node)
+	}
+
+	_, ok := r.NodePriority("pve-4")
+	assert.False(t, ok)
+
+	assert.True(t, r.HasResource(100))
+	assert.True(t, r.HasResource(105))
+	assert.False(t, r.HasResource(10))
+}
+
+func TestGetGuestHAGroups(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	pool, err := pxpool.NewProxmoxPool(newClusterEnv())
+	assert.Nil(t, err)
+
+	// Proxmox VE 8 HA groups of the Proxmox node
+	groups, err := pool.GetGuestHAGroups(t.Context(), "cluster-1", "pve-1", 100)
+	assert.Nil(t, err)
+	assert.Equal(t, []pxpool.HAGroup{{Name: "rnd", Priority: 2}}, groups)
+
+	groups, err = pool.GetGuestHAGroups(t.Context(), "cluster-1", "pve-2", 101)
+	assert.Nil(t, err)
+	assert.Equal(t, []pxpool.HAGroup{{Name: "rnd"}}, groups)
+
+	httpmock.RegisterResponder(http.MethodGet, `=~/version$`,
+		httpmock.NewJsonResponderOrPanic(200, map[string]any{"data": proxmox.Version{Version: "9.0.3"}}))
+
+	pool, err = pxpool.NewProxmoxPool(newClusterEnv())
+	assert.Nil(t, err)
+
+	major, err := pool.GetMajorVersion(t.Context(), "cluster-1")
+	assert.Nil(t, err)
+	assert.Equal(t, 9, major)
+
+	tests := []struct {
+		msg      string
+		node     string
+		vmID     int
+		expected []pxpool.HAGroup
+	}{
+		{msg: "NodeAffinity", node: "pve-1", vmID: 100, expected: []pxpool.HAGroup{{Name: "gpu", Restricted: true}, {Name: "rnd", Priority: 2}}},
+		{msg: "OtherNode", node: "pve-2", vmID: 100, expected: []pxpool.HAGroup{{Name: "rnd"}}},
+		{msg: "Container", node: "pve-2", vmID: 105, expected: []pxpool.HAGroup{{Name: "rnd"}}},
+		{msg: "NodeNotInRule", node: "pve-3", vmID: 101},
+		{msg: "NoRules", node: "pve-1", vmID: 102},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.msg, func(t *testing.T) {
+			groups, err := pool.GetGuestHAGroups(t.Context(), "cluster-1", testCase.node, testCase.vmID)
+			if testCase.expected == nil {
+				assert.ErrorIs(t, err, pxpool.ErrHAGroupNotFound)
+
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, testCase.expected, groups)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmoxpool
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// PCIDevice is the PCI device of the Proxmox node.
+type PCIDevice struct {
+	// ID is the PCI address, for example 0000:01:00.0
+	ID     string `json:"id"`
+	Vendor string `json:"vendor"`
+	Device string `json:"device"`
+}
+
+// PCIMapping is the cluster-wide PCI resource mapping.
+type PCIMapping struct {
+	ID string `json:"id"`
+	// Map is the list of the node devices, for example id=10de:1db6,node=pve-1,path=0000:01:00.0
+	Map []string `json:"map"`
+}
+
+// GetNodePCIDevices returns the PCI devices of the Proxmox node in a given region.
+func (c *ProxmoxPool) GetNodePCIDevices(ctx context.Context, region string, node string) ([]*PCIDevice, error) {
+	px, err := c.GetProxmoxCluster(region)
+	if err != nil {
+		return nil, err
+	}
+
+	devices := []*PCIDevice{}
+	if err := px.Get(ctx, fmt.Sprintf("/nodes/%s/hardware/pci", url.PathEscape(node)), &devices); err != nil {
+		return nil, fmt.Errorf("error get PCI devices of node %s in region %s: %w", node, region, err)
+	}
+
+	return devices, nil
+}
+
+// GetPCIMapping returns the PCI resource mapping in a given region.
+func (c *ProxmoxPool) GetPCIMapping(ctx context.Context, region string, name string) (*PCIMapping, error) {
+	px, err := c.GetProxmoxCluster(region)
+	if err != nil {
+		return nil, err
+	}
+
+	mapping := &PCIMapping{}
+	if err := px.Get(ctx, fmt.Sprintf("/cluster/mapping/pci/%s", url.PathEscape(name)), mapping); err != nil {
+		return nil, fmt.Errorf("error get PCI mapping %s in region %s: %w", name, region, err)
+	}
+
+	return mapping, nil
+}
+
+// NodeDeviceID returns the vendor:device ID of the mapped device on the Proxmox node.
+func (m *PCIMapping) NodeDeviceID(node string) (string, bool) {
+	for _, entry := range m.Map {
+		opts := map[string]string{}
+
+		for _, kv := range strings.Split(entry, ",") {
+			k, v, _ := strings.Cut(kv, "=")
+			opts[k] = v
+		}
+
+		if opts["node"] == node && opts["id"] != "" {
+			return opts["id"], true
+		}
+	}
+
+	return "", false
+}
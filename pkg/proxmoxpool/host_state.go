@@ -0,0 +1,89 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmoxpool
+
+import (
+	"context"
+	"fmt"
+
+	proxmox "github.com/luthermonson/go-proxmox"
+)
+
+// HostState is the state of the Proxmox node.
+type HostState string
+
+const (
+	// HostStateOnline is the online Proxmox node.
+	HostStateOnline HostState = "Online"
+	// HostStateOffline is the Proxmox node which left the cluster quorum or is not reachable.
+	HostStateOffline HostState = "Offline"
+	// HostStateFenced is the Proxmox node fenced by the HA manager.
+	HostStateFenced HostState = "Fenced"
+	// HostStateUnknown is the Proxmox node with the unknown state.
+	HostStateUnknown HostState = "Unknown"
+)
+
+// GetHostStates returns the state of the Proxmox nodes in a given region,
+// from the cluster status, the node list and the HA manager status.
+func (c *ProxmoxPool) GetHostStates(ctx context.Context, region string) (map[string]HostState, error) {
+	px, err := c.GetProxmoxCluster(region)
+	if err != nil {
+		return nil, err
+	}
+
+	members := proxmox.NodeStatuses{}
+	if err := px.Get(ctx, "/cluster/status", &members); err != nil {
+		return nil, fmt.Errorf("error get cluster status in region %s: %w", region, err)
+	}
+
+	nodes := proxmox.NodeStatuses{}
+	if err := px.Get(ctx, "/nodes", &nodes); err != nil {
+		return nil, fmt.Errorf("error get nodes in region %s: %w", region, err)
+	}
+
+	ha, err := c.GetHAManagerStatus(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make(map[string]HostState, len(nodes))
+
+	for _, n := range nodes {
+		switch n.Status {
+		case "online":
+			states[n.Node] = HostStateOnline
+		case "offline":
+			states[n.Node] = HostStateOffline
+		default:
+			states[n.Node] = HostStateUnknown
+		}
+	}
+
+	for _, m := range members {
+		if m.Type == "node" && m.Online == 0 {
+			states[m.Name] = HostStateOffline
+		}
+	}
+
+	for node, state := range ha.ManagerStatus.NodeStatus {
+		if state == "fence" {
+			states[node] = HostStateFenced
+		}
+	}
+
+	return states, nil
+}
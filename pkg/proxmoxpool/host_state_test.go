@@ -0,0 +1,63 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmoxpool_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+
+	pxpool "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/proxmoxpool"
+	testcluster "github.com/sergelogvinov/proxmox-cloud-controller-manager/test/cluster"
+)
+
+func TestGetHostStates(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	pool, err := pxpool.NewProxmoxPool(newClusterEnv())
+	assert.Nil(t, err)
+
+	states, err := pool.GetHostStates(t.Context(), "cluster-1")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]pxpool.HostState{
+		"pve-1": pxpool.HostStateOnline,
+		"pve-2": pxpool.HostStateOnline,
+		"pve-3": pxpool.HostStateOnline,
+		"pve-4": pxpool.HostStateOffline,
+	}, states)
+
+	httpmock.RegisterResponder(http.MethodGet, `=~/cluster/ha/status/manager_status$`,
+		httpmock.NewJsonResponderOrPanic(200, map[string]any{
+			"data": map[string]any{
+				"manager_status": map[string]any{
+					"node_status": map[string]string{"pve-1": "online", "pve-2": "fence"},
+				},
+			},
+		}))
+
+	states, err = pool.GetHostStates(t.Context(), "cluster-1")
+	assert.Nil(t, err)
+	assert.Equal(t, pxpool.HostStateFenced, states["pve-2"])
+
+	_, err = pool.GetHostStates(t.Context(), "cluster-3")
+	assert.ErrorIs(t, err, pxpool.ErrRegionNotFound)
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmoxpool
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	metrics "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/metrics"
+)
+
+// NodeConfig is the configuration of the Proxmox node, /nodes/{node}/config.
+type NodeConfig struct {
+	Description string `json:"description"`
+}
+
+// CephBucket is the CRUSH bucket of the Ceph OSD tree, /nodes/{node}/ceph/osd.
+type CephBucket struct {
+	Name     string        `json:"name"`
+	Type     string        `json:"type"`
+	Children []*CephBucket `json:"children"`
+}
+
+type nodeConfigEntry struct {
+	config  *NodeConfig
+	updated time.Time
+}
+
+type cephTreeEntry struct {
+	root    *CephBucket
+	updated time.Time
+}
+
+// GetNodeConfig returns the configuration of the Proxmox node in a given region.
+// The configuration is cached with the lifetime of the node status.
+func (c *ProxmoxPool) GetNodeConfig(ctx context.Context, region string, node string) (*NodeConfig, error) {
+	key := region + "/" + node
+
+	c.nodeStatus.mu.Lock()
+	entry, ok := c.nodeStatus.configs[key]
+	ttl := c.nodeStatus.ttl
+	c.nodeStatus.mu.Unlock()
+
+	if ok && time.Since(entry.updated) < ttl {
+		return entry.config, nil
+	}
+
+	px, err := c.GetProxmoxCluster(region)
+	if err != nil {
+		return nil, err
+	}
+
+	mc := metrics.NewMetricContext("getNodeConfig")
+
+	cfg := &NodeConfig{}
+	if err := px.Get(ctx, fmt.Sprintf("/nodes/%s/config", url.PathEscape(node)), cfg); mc.ObserveRequest(err) != nil {
+		return nil, fmt.Errorf("error get config of node %s in region %s: %w", node, region, err)
+	}
+
+	c.nodeStatus.mu.Lock()
+	c.nodeStatus.configs[key] = &nodeConfigEntry{config: cfg, updated: time.Now()}
+	c.nodeStatus.mu.Unlock()
+
+	return cfg, nil
+}
+
+// GetCephFailureDomain returns the name of the CRUSH bucket of the given type, which contains the Proxmox node.
+// The OSD tree is cached with the lifetime of the node status.
+func (c *ProxmoxPool) GetCephFailureDomain(ctx context.Context, region string, node string, bucketType string) (string, error) {
+	root, err := c.getCephTree(ctx, region, node)
+	if err != nil {
+		return "", err
+	}
+
+	if domain := root.failureDomain(node, bucketType, ""); domain != "" {
+		return domain, nil
+	}
+
+	return "", fmt.Errorf("ceph crush bucket %s of node %s not found in region %s", bucketType, node, region)
+}
+
+func (c *ProxmoxPool) getCephTree(ctx context.Context, region string, node string) (*CephBucket, error) {
+	key := region + "/" + node
+
+	c.nodeStatus.mu.Lock()
+	entry, ok := c.nodeStatus.cephTrees[key]
+	ttl := c.nodeStatus.ttl
+	c.nodeStatus.mu.Unlock()
+
+	if ok && time.Since(entry.updated) < ttl {
+		return entry.root, nil
+	}
+
+	px, err := c.GetProxmoxCluster(region)
+	if err != nil {
+		return nil, err
+	}
+
+	mc := metrics.NewMetricContext("getCephOSDTree")
+
+	tree := struct {
+		Root CephBucket `json:"root"`
+	}{}
+	if err := px.Get(ctx, fmt.Sprintf("/nodes/%s/ceph/osd", url.PathEscape(node)), &tree); mc.ObserveRequest(err) != nil {
+		return nil, fmt.Errorf("error get ceph osd tree of node %s in region %s: %w", node, region, err)
+	}
+
+	c.nodeStatus.mu.Lock()
+	c.nodeStatus.cephTrees[key] = &cephTreeEntry{root: &tree.Root, updated: time.Now()}
+	c.nodeStatus.mu.Unlock()
+
+	return &tree.Root, nil
+}
+
+// failureDomain returns the closest parent bucket of the given type of the host bucket.
+func (b *CephBucket) failureDomain(host string, bucketType string, parent string) string {
+	if b.Type == bucketType {
+		parent = b.Name
+	}
+
+	if b.Type == "host" {
+		if b.Name == host {
+			return parent
+		}
+
+		return ""
+	}
+
+	for _, child := range b.Children {
+		if domain := child.failureDomain(host, bucketType, parent); domain != "" {
+			return domain
+		}
+	}
+
+	return ""
+}
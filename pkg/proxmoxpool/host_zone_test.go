@@ -0,0 +1,80 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmoxpool_test
+
+import (
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+
+	pxpool "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/proxmoxpool"
+	testcluster "github.com/sergelogvinov/proxmox-cloud-controller-manager/test/cluster"
+)
+
+func TestGetNodeConfig(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	pool, err := pxpool.NewProxmoxPool(newClusterEnv())
+	assert.Nil(t, err)
+
+	cfg, err := pool.GetNodeConfig(t.Context(), "cluster-1", "pve-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "Rack A1\nzone: dc-1\n", cfg.Description)
+
+	// The config is cached
+	_, err = pool.GetNodeConfig(t.Context(), "cluster-1", "pve-1")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, httpmock.GetCallCountInfo()["GET =~/nodes/pve-1/config$"])
+
+	_, err = pool.GetNodeConfig(t.Context(), "cluster-3", "pve-1")
+	assert.NotNil(t, err)
+}
+
+func TestGetCephFailureDomain(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	pool, err := pxpool.NewProxmoxPool(newClusterEnv())
+	assert.Nil(t, err)
+
+	domain, err := pool.GetCephFailureDomain(t.Context(), "cluster-1", "pve-1", "rack")
+	assert.Nil(t, err)
+	assert.Equal(t, "rack-1", domain)
+
+	domain, err = pool.GetCephFailureDomain(t.Context(), "cluster-1", "pve-2", "host")
+	assert.Nil(t, err)
+	assert.Equal(t, "pve-2", domain)
+
+	domain, err = pool.GetCephFailureDomain(t.Context(), "cluster-1", "pve-4", "root")
+	assert.Nil(t, err)
+	assert.Equal(t, "default", domain)
+
+	_, err = pool.GetCephFailureDomain(t.Context(), "cluster-1", "pve-4", "rack")
+	assert.NotNil(t, err)
+
+	_, err = pool.GetCephFailureDomain(t.Context(), "cluster-1", "pve-3", "rack")
+	assert.NotNil(t, err)
+
+	// The OSD tree is cached per Proxmox node
+	assert.Equal(t, 4, httpmock.GetCallCountInfo()["GET =~/nodes/pve-[0-9]/ceph/osd$"])
+}
@@ -0,0 +1,388 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmoxpool
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	proxmox "github.com/luthermonson/go-proxmox"
+	"go.uber.org/multierr"
+
+	goproxmox "github.com/sergelogvinov/go-proxmox"
+	metrics "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/metrics"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// DefaultInventoryTTL is the default lifetime of the VM inventory.
+	DefaultInventoryTTL = time.Minute
+	// DefaultInventoryMinRefreshInterval is the minimum interval between the refreshes forced by the inventory misses.
+	DefaultInventoryMinRefreshInterval = 10 * time.Second
+
+	// inventoryConfigWorkers is the number of the concurrent VM config requests of the inventory refresh.
+	inventoryConfigWorkers = 8
+)
+
+// InventoryVM is a VM or LXC container of the inventory.
+type InventoryVM struct {
+	Region   string
+	Resource *proxmox.ClusterResource
+	UUID     string
+}
+
+// regionInventory is the VM inventory of a region, indexed by VMID, UUID and name.
+type regionInventory struct {
+	// refresh serializes the refreshes, the lookups wait on mu only while the indexes are replaced.
+	refresh sync.Mutex
+
+	mu      sync.Mutex
+	updated time.Time
+
+	byID   map[uint64]*InventoryVM
+	byUUID map[string]*InventoryVM
+	byName map[string][]*InventoryVM
+}
+
+// inventory is the VM inventory of all regions.
+// It is refreshed from the cluster resources, the VM config is fetched only for the new VMs.
+type inventory struct {
+	mu         sync.RWMutex
+	ttl        time.Duration
+	minRefresh time.Duration
+	regions    map[string]*regionInventory
+}
+
+func newInventory(regions []string) *inventory {
+	inv := &inventory{
+		ttl:        DefaultInventoryTTL,
+		minRefresh: DefaultInventoryMinRefreshInterval,
+		regions:    make(map[string]*regionInventory, len(regions)),
+	}
+
+	for _, region := range regions {
+		inv.regions[region] = &regionInventory{}
+	}
+
+	return inv
+}
+
+// SetInventoryTTL sets the lifetime of the VM inventory.
+func (c *ProxmoxPool) SetInventoryTTL(ttl time.Duration) {
+	c.inventory.mu.Lock()
+	defer c.inventory.mu.Unlock()
+
+	c.inventory.ttl = ttl
+}
+
+// SetInventoryMinRefreshInterval sets the minimum interval between the refreshes forced by the inventory misses.
+func (c *ProxmoxPool) SetInventoryMinRefreshInterval(interval time.Duration) {
+	c.inventory.mu.Lock()
+	defer c.inventory.mu.Unlock()
+
+	c.inventory.minRefresh = interval
+}
+
+// RunInventoryRefresh refreshes the VM inventory of all regions periodically, it blocks until the context is done.
+func (c *ProxmoxPool) RunInventoryRefresh(ctx context.Context) {
+	c.inventory.mu.RLock()
+	ttl := c.inventory.ttl
+	c.inventory.mu.RUnlock()
+
+	if ttl <= 0 {
+		return
+	}
+
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		for _, region := range c.GetRegions() {
+			if _, err := c.getInventory(ctx, region, 0); err != nil {
+				klog.ErrorS(err, "failed to refresh the VM inventory", "region", region)
+			}
+		}
+	}, ttl)
+}
+
+// InvalidateVM removes the VM from the inventory, the next lookup fetches the VM config again.
+func (c *ProxmoxPool) InvalidateVM(region string, vmID int) {
+	inv := c.regionInventory(region)
+	if inv == nil {
+		return
+	}
+
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	if vm, ok := inv.byID[uint64(vmID)]; ok { //nolint: gosec
+		inv.remove(vm)
+	}
+}
+
+// InvalidateRegion expires the inventory of the region, the next lookup refreshes it.
+func (c *ProxmoxPool) InvalidateRegion(region string) {
+	inv := c.regionInventory(region)
+	if inv == nil {
+		return
+	}
+
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	inv.updated = time.Time{}
+}
+
+// GetInventoryVMs returns the VMs of the region from the inventory.
+func (c *ProxmoxPool) GetInventoryVMs(ctx context.Context, region string) ([]*InventoryVM, error) {
+	return c.GetRecentInventoryVMs(ctx, region, c.inventoryTTL())
+}
+
+// GetRecentInventoryVMs returns the VMs of the region from the inventory, which is not older than maxAge.
+// The callers within maxAge share one refresh of the inventory.
+func (c *ProxmoxPool) GetRecentInventoryVMs(ctx context.Context, region string, maxAge time.Duration) ([]*InventoryVM, error) {
+	inv, err := c.getInventory(ctx, region, maxAge)
+	if err != nil {
+		return nil, err
+	}
+
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	vms := make([]*InventoryVM, 0, len(inv.byID))
+	for _, id := range slices.Sorted(maps.Keys(inv.byID)) {
+		vms = append(vms, inv.byID[id])
+	}
+
+	return vms, nil
+}
+
+func (c *ProxmoxPool) regionInventory(region string) *regionInventory {
+	c.inventory.mu.RLock()
+	defer c.inventory.mu.RUnlock()
+
+	return c.inventory.regions[region]
+}
+
+func (c *ProxmoxPool) inventoryTTL() time.Duration {
+	c.inventory.mu.RLock()
+	defer c.inventory.mu.RUnlock()
+
+	return c.inventory.ttl
+}
+
+// getInventory returns the inventory of the region, it refreshes the inventory if it is older than maxAge.
+// The concurrent callers share one refresh.
+func (c *ProxmoxPool) getInventory(ctx context.Context, region string, maxAge time.Duration) (*regionInventory, error) {
+	inv := c.regionInventory(region)
+	if inv == nil {
+		return nil, ErrRegionNotFound
+	}
+
+	if maxAge > 0 && inv.age() < maxAge {
+		return inv, nil
+	}
+
+	inv.refresh.Lock()
+	defer inv.refresh.Unlock()
+
+	// The inventory could be refreshed while waiting for the lock
+	if maxAge > 0 && inv.age() < maxAge {
+		return inv, nil
+	}
+
+	if err := c.refreshInventory(ctx, region, inv); err != nil {
+		return nil, err
+	}
+
+	return inv, nil
+}
+
+func (inv *regionInventory) age() time.Duration {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	if inv.updated.IsZero() {
+		return time.Duration(math.MaxInt64)
+	}
+
+	return time.Since(inv.updated)
+}
+
+// refreshInventory lists the cluster resources and fetches the config of the new VMs.
+// The caller must hold the refresh lock, the inventory lock is taken only to replace the indexes.
+func (c *ProxmoxPool) refreshInventory(ctx context.Context, region string, inv *regionInventory) error {
+	px, err := c.GetProxmoxCluster(region)
+	if err != nil {
+		return err
+	}
+
+	mc := metrics.NewMetricContext("getClusterResources")
+
+	resources, err := (&proxmox.Cluster{}).New(px.Client).Resources(ctx, "vm")
+	if mc.ObserveRequest(err) != nil {
+		return fmt.Errorf("error get cluster resources in region %s: %w", region, err)
+	}
+
+	inv.mu.Lock()
+	old := inv.byID
+	inv.mu.Unlock()
+
+	vms := make([]*InventoryVM, 0, len(resources))
+	sem := make(chan struct{}, inventoryConfigWorkers)
+
+	var wg sync.WaitGroup
+
+	for _, rs := range resources {
+		if rs.Type != GuestTypeVM && rs.Type != GuestTypeContainer {
+			continue
+		}
+
+		vm := &InventoryVM{Region: region, Resource: rs}
+		vms = append(vms, vm)
+
+		// LXC containers do not have SMBIOS UUID
+		if rs.Type == GuestTypeContainer {
+			continue
+		}
+
+		if prev, ok := old[rs.VMID]; ok && prev.UUID != "" && prev.Resource.Name == rs.Name {
+			vm.UUID = prev.UUID
+
+			continue
+		}
+
+		if rs.Status == "unknown" {
+			continue
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			mc := metrics.NewMetricContext("getVMConfig")
+
+			config, err := px.GetVMConfig(ctx, int(rs.VMID))
+			if mc.ObserveRequest(err) != nil {
+				klog.V(4).InfoS("failed to get VM config", "region", region, "vmID", rs.VMID, "err", err)
+
+				return
+			}
+
+			vm.UUID = strings.ToLower(goproxmox.GetVMUUID(config))
+		}()
+	}
+
+	wg.Wait()
+
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	inv.byID = make(map[uint64]*InventoryVM, len(vms))
+	inv.byUUID = make(map[string]*InventoryVM, len(vms))
+	inv.byName = make(map[string][]*InventoryVM, len(vms))
+
+	for _, vm := range vms {
+		inv.add(vm)
+	}
+
+	inv.updated = time.Now()
+
+	return nil
+}
+
+func (inv *regionInventory) add(vm *InventoryVM) {
+	inv.byID[vm.Resource.VMID] = vm
+	inv.byName[vm.Resource.Name] = append(inv.byName[vm.Resource.Name], vm)
+
+	if vm.UUID != "" {
+		inv.byUUID[vm.UUID] = vm
+	}
+}
+
+func (inv *regionInventory) remove(vm *InventoryVM) {
+	delete(inv.byID, vm.Resource.VMID)
+	delete(inv.byUUID, vm.UUID)
+
+	inv.byName[vm.Resource.Name] = slices.DeleteFunc(inv.byName[vm.Resource.Name], func(v *InventoryVM) bool { return v == vm })
+	if len(inv.byName[vm.Resource.Name]) == 0 {
+		delete(inv.byName, vm.Resource.Name)
+	}
+
+	// The inventory does not know this VM anymore, refresh it on the next lookup
+	inv.updated = time.Time{}
+}
+
+// lookupInventory searches the VM in the inventory of the region with the match function.
+// If the VM is not found, the inventory is refreshed once and the search is repeated.
+// The refreshes forced by the misses are limited to one per the minimum refresh interval.
+func (c *ProxmoxPool) lookupInventory(ctx context.Context, region string, match func(inv *regionInventory) (*InventoryVM, error)) (*InventoryVM, error) {
+	inv, err := c.getInventory(ctx, region, c.inventoryTTL())
+	if err != nil {
+		return nil, err
+	}
+
+	inv.mu.Lock()
+	vm, _ := match(inv)
+	inv.mu.Unlock()
+
+	if vm != nil {
+		metrics.CacheHit("inventory")
+
+		return vm, nil
+	}
+
+	metrics.CacheMiss("inventory")
+
+	c.inventory.mu.RLock()
+	minRefresh := c.inventory.minRefresh
+	c.inventory.mu.RUnlock()
+
+	if inv, err = c.getInventory(ctx, region, minRefresh); err != nil {
+		return nil, err
+	}
+
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	return match(inv)
+}
+
+// inaccessibleErrors returns the errors for the VMs which could not be identified because of the Proxmox node state.
+func (inv *regionInventory) inaccessibleErrors(filter func(vm *InventoryVM) bool) error {
+	var errs error
+
+	ids := slices.Sorted(maps.Keys(inv.byID))
+
+	for _, id := range ids {
+		vm := inv.byID[id]
+		if vm.UUID == "" && vm.Resource.Status == "unknown" && filter(vm) {
+			errs = multierr.Append(errs, fmt.Errorf("node %s: %w", vm.Resource.Node, ErrNodeInaccessible))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,248 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmoxpool_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/multierr"
+
+	pxpool "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/proxmoxpool"
+	testcluster "github.com/sergelogvinov/proxmox-cloud-controller-manager/test/cluster"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const vm100ConfigCall = "GET =~/nodes/pve-1/qemu/100/config"
+
+func TestInventoryFindVM(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	pool, err := pxpool.NewProxmoxPool(newClusterEnv())
+	assert.Nil(t, err)
+
+	vmID, region, err := pool.FindVMByUUID(t.Context(), "11833F4C-341F-4BD3-AAD7-F7ABED000001")
+	assert.Nil(t, err)
+	assert.Equal(t, 101, vmID)
+	assert.Equal(t, "cluster-1", region)
+	assert.Equal(t, 1, httpmock.GetCallCountInfo()[vm100ConfigCall])
+
+	// Cache hit, no more config requests
+	vmID, region, err = pool.FindVMByNode(t.Context(), &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-1-node-1"},
+		Status: v1.NodeStatus{
+			NodeInfo: v1.NodeSystemInfo{SystemUUID: "11833f4c-341f-4bd3-aad7-f7abed000000"},
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 100, vmID)
+	assert.Equal(t, "cluster-1", region)
+	assert.Equal(t, 1, httpmock.GetCallCountInfo()[vm100ConfigCall])
+
+	vmID, region, err = pool.FindVMByUUID(t.Context(), "11833f4c-341f-4bd3-aad7-f7abea000000")
+	assert.Nil(t, err)
+	assert.Equal(t, 103, vmID)
+	assert.Equal(t, "cluster-2", region)
+
+	// LXC container is found by the name only
+	vmID, region, err = pool.FindVMByNode(t.Context(), &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-1-node-5"},
+		Status: v1.NodeStatus{
+			NodeInfo: v1.NodeSystemInfo{SystemUUID: "11833f4c-341f-4bd3-aad7-f7abed000000"},
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 105, vmID)
+	assert.Equal(t, "cluster-1", region)
+
+	guestType, err := pool.GetGuestType(t.Context(), "cluster-1", 105)
+	assert.Nil(t, err)
+	assert.Equal(t, pxpool.GuestTypeContainer, guestType)
+
+	_, err = pool.GetGuestType(t.Context(), "cluster-1", 500)
+	assert.ErrorIs(t, err, pxpool.ErrInstanceNotFound)
+
+	// LXC container is resolved from the inventory
+	calls := httpmock.GetCallCountInfo()["GET =~/cluster/resources"]
+
+	ct, err := pool.GetContainerConfig(t.Context(), "cluster-1", 105)
+	assert.Nil(t, err)
+	assert.Equal(t, "pve-2", ct.Node)
+	assert.Equal(t, calls, httpmock.GetCallCountInfo()["GET =~/cluster/resources"])
+
+	_, err = pool.GetContainerByIDInRegion(t.Context(), "cluster-1", 100)
+	assert.ErrorIs(t, err, pxpool.ErrInstanceNotFound)
+
+	// VM 104 is on the inaccessible Proxmox node
+	_, _, err = pool.FindVMByUUID(t.Context(), "11833f4c-341f-4bd3-aad7-f7abed000004")
+	assert.ErrorIs(t, err, pxpool.ErrNodeInaccessible)
+
+	// Invalidated VM fetches the config again
+	pool.InvalidateVM("cluster-1", 100)
+
+	vmID, _, err = pool.FindVMByUUID(t.Context(), "11833f4c-341f-4bd3-aad7-f7abed000000")
+	assert.Nil(t, err)
+	assert.Equal(t, 100, vmID)
+	assert.Equal(t, 2, httpmock.GetCallCountInfo()[vm100ConfigCall])
+}
+
+func TestInventoryTTL(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	pool, err := pxpool.NewProxmoxPool(newClusterEnv())
+	assert.Nil(t, err)
+
+	pool.SetInventoryTTL(time.Hour)
+
+	vms, err := pool.GetInventoryVMs(t.Context(), "cluster-1")
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(vms))
+	assert.Equal(t, "11833f4c-341f-4bd3-aad7-f7abed000000", vms[0].UUID)
+	assert.Equal(t, "", vms[2].UUID)
+
+	// The recent inventory is shared
+	calls := httpmock.GetCallCountInfo()["GET =~/cluster/resources"]
+
+	_, err = pool.GetRecentInventoryVMs(t.Context(), "cluster-1", time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, calls, httpmock.GetCallCountInfo()["GET =~/cluster/resources"])
+
+	pool.InvalidateRegion("cluster-1")
+
+	vms, err = pool.GetInventoryVMs(t.Context(), "cluster-1")
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(vms))
+	assert.Equal(t, 1, httpmock.GetCallCountInfo()[vm100ConfigCall])
+
+	_, err = pool.GetInventoryVMs(t.Context(), "cluster-3")
+	assert.ErrorIs(t, err, pxpool.ErrRegionNotFound)
+}
+
+func TestInventoryMinRefreshInterval(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	pool, err := pxpool.NewProxmoxPool(newClusterEnv())
+	assert.Nil(t, err)
+
+	pool.SetInventoryTTL(time.Hour)
+
+	const resourcesCall = "GET https://127.0.0.2:8006/api2/json/cluster/resources"
+
+	_, err = pool.GetGuestType(t.Context(), "cluster-2", 103)
+	assert.Nil(t, err)
+
+	calls := httpmock.GetCallCountInfo()[resourcesCall]
+
+	// The misses do not refresh the inventory within the minimum refresh interval
+	for range 3 {
+		_, err = pool.GetGuestType(t.Context(), "cluster-2", 500)
+		assert.ErrorIs(t, err, pxpool.ErrInstanceNotFound)
+	}
+
+	assert.Equal(t, calls, httpmock.GetCallCountInfo()[resourcesCall])
+
+	pool.SetInventoryMinRefreshInterval(0)
+
+	_, err = pool.GetGuestType(t.Context(), "cluster-2", 500)
+	assert.ErrorIs(t, err, pxpool.ErrInstanceNotFound)
+	assert.Equal(t, calls+1, httpmock.GetCallCountInfo()[resourcesCall])
+}
+
+func TestFindVMConcurrent(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	// The second region hangs
+	httpmock.RegisterResponder(http.MethodGet, "https://127.0.0.2:8006/api2/json/cluster/resources",
+		httpmock.NewJsonResponderOrPanic(200, map[string]any{"data": []any{}}).Delay(5*time.Second))
+
+	pool, err := pxpool.NewProxmoxPool(newClusterEnv())
+	assert.Nil(t, err)
+
+	pool.SetRegionTimeout(200 * time.Millisecond)
+
+	vmID, region, err := pool.FindVMByUUID(t.Context(), "11833f4c-341f-4bd3-aad7-f7abed000000")
+	assert.Nil(t, err)
+	assert.Equal(t, 100, vmID)
+	assert.Equal(t, "cluster-1", region)
+
+	for range 3 {
+		_, _, err = pool.FindVMByUUID(t.Context(), "11833f4c-341f-4bd3-aad7-f7abed999999")
+		assert.ErrorIs(t, err, pxpool.ErrNodeInaccessible)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		errs := multierr.Errors(err)
+		assert.Equal(t, 2, len(errs))
+		assert.Contains(t, errs[0].Error(), "region cluster-1: node pve-4")
+		assert.Contains(t, errs[1].Error(), "region cluster-2:")
+	}
+}
+
+func TestFindVMAmbiguous(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	// The second region has the LXC container with the same name
+	httpmock.RegisterResponder(http.MethodGet, "https://127.0.0.2:8006/api2/json/cluster/resources",
+		httpmock.NewJsonResponderOrPanic(200, map[string]any{
+			"data": []map[string]any{
+				{"node": "pve-3", "type": "lxc", "vmid": 205, "name": "cluster-1-node-5", "status": "running"},
+			},
+		}))
+
+	pool, err := pxpool.NewProxmoxPool(newClusterEnv())
+	assert.Nil(t, err)
+
+	_, _, err = pool.FindVMByNode(t.Context(), &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-1-node-5"},
+		Status: v1.NodeStatus{
+			NodeInfo: v1.NodeSystemInfo{SystemUUID: "11833f4c-341f-4bd3-aad7-f7abed000000"},
+		},
+	})
+	assert.ErrorIs(t, err, pxpool.ErrInstanceAmbiguous)
+	assert.Contains(t, err.Error(), "cluster-1, cluster-2")
+
+	// The UUID match is definitive
+	vmID, region, err := pool.FindVMByNode(t.Context(), &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-1-node-1"},
+		Status: v1.NodeStatus{
+			NodeInfo: v1.NodeSystemInfo{SystemUUID: "11833f4c-341f-4bd3-aad7-f7abed000000"},
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 100, vmID)
+	assert.Equal(t, "cluster-1", region)
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmoxpool
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+const (
+	// NameMatchingPrefix matches the VM name which starts with the node name.
+	NameMatchingPrefix = "prefix"
+	// NameMatchingExact matches the VM name which is equal to the node name, case-insensitive.
+	NameMatchingExact = "exact"
+	// NameMatchingDomain strips the domain from the node name (FQDN), and matches the VM name exactly.
+	NameMatchingDomain = "domain"
+	// NameMatchingRegex matches the VM name with the first capture group of the regular expression applied to the node name.
+	NameMatchingRegex = "regex"
+	// NameMatchingTemplate matches the VM name with the Go template rendered from the node name.
+	NameMatchingTemplate = "template"
+)
+
+// ValidNameMatchingStrategies is a list of valid node name matching strategies.
+var ValidNameMatchingStrategies = []string{
+	NameMatchingPrefix,
+	NameMatchingExact,
+	NameMatchingDomain,
+	NameMatchingRegex,
+	NameMatchingTemplate,
+}
+
+// NameMatchingOpts defines how the Kubernetes node name is matched with the VM name.
+type NameMatchingOpts struct {
+	// Strategy is one of prefix, exact, domain, regex or template.
+	// Default is prefix.
+	Strategy string `yaml:"strategy,omitempty"`
+	// Domain is the domain suffix stripped from the node name by the domain strategy.
+	// If empty, everything after the first dot is stripped.
+	Domain string `yaml:"domain,omitempty"`
+	// Regex is the regular expression of the regex strategy, the first capture group is the VM name.
+	Regex string `yaml:"regex,omitempty"`
+	// Template is the Go template of the template strategy, it renders the VM name from {{ .NodeName }}.
+	Template string `yaml:"template,omitempty"`
+}
+
+// NameMatcher reports whether the VM name belongs to the Kubernetes node name.
+type NameMatcher func(vmName, nodeName string) bool
+
+// NewNameMatcher returns the name matcher of the strategy.
+func NewNameMatcher(opts NameMatchingOpts) (NameMatcher, error) {
+	switch opts.Strategy {
+	case "", NameMatchingPrefix:
+		return func(vmName, nodeName string) bool {
+			return strings.HasPrefix(vmName, nodeName)
+		}, nil
+	case NameMatchingExact:
+		return strings.EqualFold, nil
+	case NameMatchingDomain:
+		domain := "." + strings.TrimPrefix(opts.Domain, ".")
+
+		return func(vmName, nodeName string) bool {
+			if opts.Domain == "" {
+				nodeName, _, _ = strings.Cut(nodeName, ".")
+			} else {
+				nodeName = strings.TrimSuffix(nodeName, domain)
+			}
+
+			return strings.EqualFold(vmName, nodeName)
+		}, nil
+	case NameMatchingRegex:
+		re, err := regexp.Compile(opts.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid name matching regex: %w", err)
+		}
+
+		if re.NumSubexp() < 1 {
+			return nil, fmt.Errorf("name matching regex %q has no capture group", opts.Regex)
+		}
+
+		return func(vmName, nodeName string) bool {
+			m := re.FindStringSubmatch(nodeName)
+
+			return len(m) > 1 && strings.EqualFold(vmName, m[1])
+		}, nil
+	case NameMatchingTemplate:
+		tmpl, err := template.New("name").Option("missingkey=error").Funcs(template.FuncMap{
+			"lower":      strings.ToLower,
+			"upper":      strings.ToUpper,
+			"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+			"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+			"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		}).Parse(opts.Template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid name matching template: %w", err)
+		}
+
+		return func(vmName, nodeName string) bool {
+			var name strings.Builder
+			if err := tmpl.Execute(&name, map[string]string{"NodeName": nodeName}); err != nil {
+				return false
+			}
+
+			return strings.EqualFold(vmName, strings.TrimSpace(name.String()))
+		}, nil
+	}
+
+	return nil, fmt.Errorf("invalid name matching strategy %q, valid strategies are %v", opts.Strategy, ValidNameMatchingStrategies)
+}
+
+// SetNameMatcher sets the matcher of the node name and the VM name.
+func (c *ProxmoxPool) SetNameMatcher(matcher NameMatcher) {
+	c.nameMatcher = matcher
+}
+
+// MatchNodeName reports whether the VM name belongs to the Kubernetes node name.
+func (c *ProxmoxPool) MatchNodeName(vmName, nodeName string) bool {
+	if c.nameMatcher == nil {
+		return strings.HasPrefix(vmName, nodeName)
+	}
+
+	return c.nameMatcher(vmName, nodeName)
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmoxpool_test
+
+import (
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+
+	pxpool "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/proxmoxpool"
+	testcluster "github.com/sergelogvinov/proxmox-cloud-controller-manager/test/cluster"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNameMatcher(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		msg      string
+		opts     pxpool.NameMatchingOpts
+		vmName   string
+		nodeName string
+		expected bool
+	}{
+		{msg: "DefaultPrefix", vmName: "worker-1", nodeName: "worker-1", expected: true},
+		{msg: "Prefix", opts: pxpool.NameMatchingOpts{Strategy: "prefix"}, vmName: "worker-1.example.com", nodeName: "worker-1", expected: true},
+		{msg: "PrefixCase", opts: pxpool.NameMatchingOpts{Strategy: "prefix"}, vmName: "Worker-1", nodeName: "worker-1", expected: false},
+		{msg: "Exact", opts: pxpool.NameMatchingOpts{Strategy: "exact"}, vmName: "Worker-1", nodeName: "worker-1", expected: true},
+		{msg: "ExactPrefix", opts: pxpool.NameMatchingOpts{Strategy: "exact"}, vmName: "worker-10", nodeName: "worker-1", expected: false},
+		{msg: "Domain", opts: pxpool.NameMatchingOpts{Strategy: "domain"}, vmName: "worker-1", nodeName: "worker-1.k8s.example.com", expected: true},
+		{msg: "DomainShortName", opts: pxpool.NameMatchingOpts{Strategy: "domain"}, vmName: "worker-1", nodeName: "worker-1", expected: true},
+		{msg: "DomainSuffix", opts: pxpool.NameMatchingOpts{Strategy: "domain", Domain: "example.com"}, vmName: "worker-1.k8s", nodeName: "worker-1.k8s.example.com", expected: true},
+		{msg: "DomainOtherSuffix", opts: pxpool.NameMatchingOpts{Strategy: "domain", Domain: "example.com"}, vmName: "worker-1", nodeName: "worker-1.example.org", expected: false},
+		{msg: "Regex", opts: pxpool.NameMatchingOpts{Strategy: "regex", Regex: `^k8s-(.+)$`}, vmName: "worker-1", nodeName: "k8s-worker-1", expected: true},
+		{msg: "RegexNoMatch", opts: pxpool.NameMatchingOpts{Strategy: "regex", Regex: `^k8s-(.+)$`}, vmName: "worker-1", nodeName: "worker-1", expected: false},
+		{msg: "Template", opts: pxpool.NameMatchingOpts{Strategy: "template", Template: `{{ .NodeName | trimSuffix ".example.com" | upper }}`}, vmName: "WORKER-1", nodeName: "worker-1.example.com", expected: true},
+		{msg: "TemplateReplace", opts: pxpool.NameMatchingOpts{Strategy: "template", Template: `vm-{{ .NodeName | replace "." "-" }}`}, vmName: "vm-worker-1-lab", nodeName: "worker-1.lab", expected: true},
+		{msg: "TemplateNoMatch", opts: pxpool.NameMatchingOpts{Strategy: "template", Template: `vm-{{ .NodeName }}`}, vmName: "worker-1", nodeName: "worker-1", expected: false},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.msg, func(t *testing.T) {
+			t.Parallel()
+
+			matcher, err := pxpool.NewNameMatcher(testCase.opts)
+			assert.Nil(t, err)
+			assert.Equal(t, testCase.expected, matcher(testCase.vmName, testCase.nodeName))
+		})
+	}
+}
+
+func TestNameMatcherInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, opts := range []pxpool.NameMatchingOpts{
+		{Strategy: "suffix"},
+		{Strategy: "regex", Regex: `^k8s-(.+$`},
+		{Strategy: "regex", Regex: `^k8s-.+$`},
+		{Strategy: "template", Template: `{{ .NodeName | unknown }}`},
+	} {
+		_, err := pxpool.NewNameMatcher(opts)
+		assert.NotNil(t, err, opts)
+	}
+}
+
+func TestFindVMByNodeNameMatching(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	pool, err := pxpool.NewProxmoxPool(newClusterEnv())
+	assert.Nil(t, err)
+
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-1-node-1.example.com"},
+		Status: v1.NodeStatus{
+			NodeInfo: v1.NodeSystemInfo{SystemUUID: "11833f4c-341f-4bd3-aad7-f7abed000000"},
+		},
+	}
+
+	_, _, err = pool.FindVMByNode(t.Context(), node)
+	assert.ErrorIs(t, err, pxpool.ErrInstanceNotFound)
+
+	matcher, err := pxpool.NewNameMatcher(pxpool.NameMatchingOpts{Strategy: pxpool.NameMatchingDomain})
+	assert.Nil(t, err)
+
+	pool.SetNameMatcher(matcher)
+
+	vmID, region, err := pool.FindVMByNode(t.Context(), node)
+	assert.Nil(t, err)
+	assert.Equal(t, 100, vmID)
+	assert.Equal(t, "cluster-1", region)
+	assert.True(t, pool.MatchNodeName("cluster-1-node-1", node.Name))
+
+	// LXC container hostname
+	vmID, _, err = pool.FindVMByNode(t.Context(), &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "cluster-1-node-5.example.com"}})
+	assert.Nil(t, err)
+	assert.Equal(t, 105, vmID)
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmoxpool
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	proxmox "github.com/luthermonson/go-proxmox"
+
+	metrics "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/metrics"
+)
+
+// DefaultNodeStatusTTL is the default lifetime of the cached Proxmox node status.
+const DefaultNodeStatusTTL = 10 * time.Minute
+
+// NodeStatus is the status of the Proxmox node, /nodes/{node}/status.
+type NodeStatus struct {
+	// PVEVersion is the version of the pve-manager package, for example pve-manager/8.2.4/faa83925c9641325
+	PVEVersion string `json:"pveversion"`
+	// KVersion is the running kernel, for example Linux 6.8.12-1-pve #1 SMP PREEMPT_DYNAMIC PMX 6.8.12-1
+	KVersion      string          `json:"kversion"`
+	CurrentKernel NodeKernel      `json:"current-kernel"`
+	CPUInfo       proxmox.CPUInfo `json:"cpuinfo"`
+	KSM           proxmox.Ksm     `json:"ksm"`
+
+	// Ceph is true if the node has an available Ceph storage, RBD or CephFS.
+	Ceph bool `json:"-"`
+}
+
+// NodeKernel is the running kernel of the Proxmox node.
+type NodeKernel struct {
+	Release string `json:"release"`
+}
+
+// Version returns the Proxmox VE version of the node, for example 8.2.4
+func (s *NodeStatus) Version() string {
+	parts := strings.Split(s.PVEVersion, "/")
+	if len(parts) < 2 {
+		return ""
+	}
+
+	return parts[1]
+}
+
+// Kernel returns the kernel release of the node, for example 6.8.12-1-pve
+func (s *NodeStatus) Kernel() string {
+	if s.CurrentKernel.Release != "" {
+		return s.CurrentKernel.Release
+	}
+
+	if fields := strings.Fields(s.KVersion); len(fields) > 1 {
+		return fields[1]
+	}
+
+	return ""
+}
+
+type nodeStatusEntry struct {
+	status  *NodeStatus
+	updated time.Time
+}
+
+// nodeStatusCache is the cache of the Proxmox node status, config and Ceph OSD tree, indexed by region and node name.
+type nodeStatusCache struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	entries   map[string]*nodeStatusEntry
+	configs   map[string]*nodeConfigEntry
+	cephTrees map[string]*cephTreeEntry
+}
+
+func newNodeStatusCache() *nodeStatusCache {
+	return &nodeStatusCache{
+		ttl:       DefaultNodeStatusTTL,
+		entries:   map[string]*nodeStatusEntry{},
+		configs:   map[string]*nodeConfigEntry{},
+		cephTrees: map[string]*cephTreeEntry{},
+	}
+}
+
+// SetNodeStatusTTL sets the lifetime of the cached Proxmox node status.
+func (c *ProxmoxPool) SetNodeStatusTTL(ttl time.Duration) {
+	c.nodeStatus.mu.Lock()
+	defer c.nodeStatus.mu.Unlock()
+
+	c.nodeStatus.ttl = ttl
+}
+
+// GetNodeStatus returns the status of the Proxmox node in a given region, the status is cached.
+func (c *ProxmoxPool) GetNodeStatus(ctx context.Context, region string, node string) (*NodeStatus, error) {
+	key := region + "/" + node
+
+	c.nodeStatus.mu.Lock()
+	entry, ok := c.nodeStatus.entries[key]
+	ttl := c.nodeStatus.ttl
+	c.nodeStatus.mu.Unlock()
+
+	if ok && time.Since(entry.updated) < ttl {
+		return entry.status, nil
+	}
+
+	px, err := c.GetProxmoxCluster(region)
+	if err != nil {
+		return nil, err
+	}
+
+	mc := metrics.NewMetricContext("getNodeStatus")
+
+	status := &NodeStatus{}
+	if err := px.Get(ctx, fmt.Sprintf("/nodes/%s/status", url.PathEscape(node)), status); mc.ObserveRequest(err) != nil {
+		return nil, fmt.Errorf("error get status of node %s in region %s: %w", node, region, err)
+	}
+
+	resources, err := (&proxmox.Cluster{}).New(px.Client).Resources(ctx, "storage")
+	if err != nil {
+		return nil, fmt.Errorf("error get cluster resources in region %s: %w", region, err)
+	}
+
+	for _, rs := range resources {
+		if rs.Type == "storage" && rs.Node == node && rs.Status == "available" &&
+			(rs.PluginType == "rbd" || rs.PluginType == "cephfs") {
+			status.Ceph = true
+
+			break
+		}
+	}
+
+	c.nodeStatus.mu.Lock()
+	c.nodeStatus.entries[key] = &nodeStatusEntry{status: status, updated: time.Now()}
+	c.nodeStatus.mu.Unlock()
+
+	return status, nil
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmoxpool_test
+
+import (
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+
+	pxpool "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/proxmoxpool"
+	testcluster "github.com/sergelogvinov/proxmox-cloud-controller-manager/test/cluster"
+)
+
+func TestNodeStatusVersion(t *testing.T) {
+	t.Parallel()
+
+	status := &pxpool.NodeStatus{
+		PVEVersion: "pve-manager/9.0.3/025864202ebb6109",
+		KVersion:   "Linux 6.14.8-2-pve #1 SMP PREEMPT_DYNAMIC PMX 6.14.8-2 (2025-07-22T10:04Z)",
+	}
+
+	assert.Equal(t, "9.0.3", status.Version())
+	assert.Equal(t, "6.14.8-2-pve", status.Kernel())
+
+	assert.Empty(t, (&pxpool.NodeStatus{}).Version())
+	assert.Empty(t, (&pxpool.NodeStatus{}).Kernel())
+}
+
+func TestGetNodeStatus(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	pool, err := pxpool.NewProxmoxPool(newClusterEnv())
+	assert.Nil(t, err)
+
+	status, err := pool.GetNodeStatus(t.Context(), "cluster-1", "pve-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "8.2.4", status.Version())
+	assert.Equal(t, "6.8.12-1-pve", status.Kernel())
+	assert.Equal(t, "AMD EPYC 7543 32-Core Processor", status.CPUInfo.Model)
+	assert.Positive(t, status.KSM.Shared)
+	assert.True(t, status.Ceph)
+
+	calls := httpmock.GetCallCountInfo()["GET =~/nodes/pve-1/status"]
+
+	_, err = pool.GetNodeStatus(t.Context(), "cluster-1", "pve-1")
+	assert.Nil(t, err)
+	assert.Equal(t, calls, httpmock.GetCallCountInfo()["GET =~/nodes/pve-1/status"])
+
+	pool.SetNodeStatusTTL(0)
+
+	_, err = pool.GetNodeStatus(t.Context(), "cluster-1", "pve-1")
+	assert.Nil(t, err)
+	assert.Equal(t, calls+1, httpmock.GetCallCountInfo()["GET =~/nodes/pve-1/status"])
+
+	status, err = pool.GetNodeStatus(t.Context(), "cluster-1", "pve-2")
+	assert.Nil(t, err)
+	assert.False(t, status.Ceph)
+
+	_, err = pool.GetNodeStatus(t.Context(), "cluster-1", "pve-4")
+	assert.NotNil(t, err)
+
+	_, err = pool.GetNodeStatus(t.Context(), "cluster-3", "pve-1")
+	assert.ErrorIs(t, err, pxpool.ErrRegionNotFound)
+}
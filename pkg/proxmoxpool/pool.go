@@ -20,12 +20,12 @@ package proxmoxpool
 import (
 	"context"
 	"crypto/tls"
-	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"slices"
 	"strings"
+	"time"
 
 	proxmox "github.com/luthermonson/go-proxmox"
 	"go.uber.org/multierr"
@@ -38,40 +38,94 @@ import (
 
 // ProxmoxCluster defines a Proxmox cluster configuration.
 type ProxmoxCluster struct {
-	URL             string `yaml:"url"`
-	Insecure        bool   `yaml:"insecure,omitempty"`
-	TokenID         string `yaml:"token_id,omitempty"`
-	TokenIDFile     string `yaml:"token_id_file,omitempty"`
-	TokenSecret     string `yaml:"token_secret,omitempty"`
-	TokenSecretFile string `yaml:"token_secret_file,omitempty"`
-	Username        string `yaml:"username,omitempty"`
-	Password        string `yaml:"password,omitempty"`
-	Region          string `yaml:"region,omitempty"`
+	URL string `yaml:"url,omitempty"`
+	// URLs are the API endpoints of the same Proxmox cluster, the requests fail over between them.
+	URLs            []string `yaml:"urls,omitempty"`
+	Insecure        bool     `yaml:"insecure,omitempty"`
+	TokenID         string   `yaml:"token_id,omitempty"`
+	TokenIDFile     string   `yaml:"token_id_file,omitempty"`
+	TokenSecret     string   `yaml:"token_secret,omitempty"`
+	TokenSecretFile string   `yaml:"token_secret_file,omitempty"`
+	Username        string   `yaml:"username,omitempty"`
+	Password        string   `yaml:"password,omitempty"`
+	Region          string   `yaml:"region,omitempty"`
+	// RegionFromClusterName uses the Proxmox cluster name from /cluster/status as the region.
+	RegionFromClusterName bool `yaml:"region_from_cluster_name,omitempty"`
+}
+
+// Endpoints returns the API endpoints of the Proxmox cluster, url and urls.
+func (c *ProxmoxCluster) Endpoints() []string {
+	urls := make([]string, 0, len(c.URLs)+1)
+	if c.URL != "" {
+		urls = append(urls, c.URL)
+	}
+
+	for _, u := range c.URLs {
+		if !slices.Contains(urls, u) {
+			urls = append(urls, u)
+		}
+	}
+
+	return urls
 }
 
 // ProxmoxPool is a Proxmox client pool of proxmox clusters.
 type ProxmoxPool struct {
-	clients map[string]*goproxmox.APIClient
+	clients    map[string]*goproxmox.APIClient
+	endpoints  map[string]*endpointTransport
+	inventory  *inventory
+	nodeStatus *nodeStatusCache
+	versions   *versionCache
+
+	regionTimeout time.Duration
+	nameMatcher   NameMatcher
+	clusterTag    string
 }
 
+// DefaultRegionTimeout is the default deadline of a VM lookup in a region.
+const DefaultRegionTimeout = 30 * time.Second
+
 // NewProxmoxPool creates a new Proxmox cluster client.
 func NewProxmoxPool(config []*ProxmoxCluster, options ...proxmox.Option) (*ProxmoxPool, error) {
 	clusters := len(config)
 	if clusters > 0 {
 		clients := make(map[string]*goproxmox.APIClient, clusters)
+		endpoints := map[string]*endpointTransport{}
 
 		for _, cfg := range config {
 			opts := []proxmox.Option{proxmox.WithUserAgent("ProxmoxCCM/1.0")}
 			opts = append(opts, options...)
 
+			var httpTr http.RoundTripper
+
 			if cfg.Insecure {
-				httpTr := &http.Transport{
+				httpTr = &http.Transport{
 					TLSClientConfig: &tls.Config{
 						InsecureSkipVerify: true,
 						MinVersion:         tls.VersionTLS12,
 					},
 				}
+			}
 
+			urls := cfg.Endpoints()
+			if len(urls) == 0 {
+				return nil, fmt.Errorf("proxmox url is not defined for region %s", cfg.Region)
+			}
+
+			var endpointTr *endpointTransport
+
+			if len(urls) > 1 {
+				var err error
+
+				endpointTr, err = newEndpointTransport(urls, httpTr)
+				if err != nil {
+					return nil, err
+				}
+
+				httpTr = endpointTr
+			}
+
+			if httpTr != nil {
 				opts = append(opts, proxmox.WithHTTPClient(&http.Client{Transport: httpTr}))
 			}
 
@@ -102,22 +156,52 @@ func NewProxmoxPool(config []*ProxmoxCluster, options ...proxmox.Option) (*Proxm
 				opts = append(opts, proxmox.WithAPIToken(cfg.TokenID, cfg.TokenSecret))
 			}
 
-			pxClient, err := goproxmox.NewAPIClient(cfg.URL, opts...)
+			pxClient, err := goproxmox.NewAPIClient(urls[0], opts...)
 			if err != nil {
 				return nil, err
 			}
 
+			if cfg.RegionFromClusterName {
+				cfg.Region, err = resolveClusterName(pxClient)
+				if err != nil {
+					return nil, fmt.Errorf("failed to get the cluster name of %s: %w", urls[0], err)
+				}
+
+				klog.InfoS("Proxmox region is the cluster name", "url", urls[0], "region", cfg.Region)
+			}
+
+			if _, ok := clients[cfg.Region]; ok {
+				return nil, fmt.Errorf("%w: %s", ErrRegionCollision, cfg.Region)
+			}
+
 			clients[cfg.Region] = pxClient
+
+			if endpointTr != nil {
+				endpointTr.setRegion(cfg.Region)
+				endpoints[cfg.Region] = endpointTr
+			}
 		}
 
-		return &ProxmoxPool{
-			clients: clients,
-		}, nil
+		pool := &ProxmoxPool{
+			clients:       clients,
+			endpoints:     endpoints,
+			nodeStatus:    newNodeStatusCache(),
+			versions:      newVersionCache(),
+			regionTimeout: DefaultRegionTimeout,
+		}
+		pool.inventory = newInventory(pool.GetRegions())
+
+		return pool, nil
 	}
 
 	return nil, ErrClustersNotFound
 }
 
+// SetRegionTimeout sets the deadline of a VM lookup in a region.
+func (c *ProxmoxPool) SetRegionTimeout(timeout time.Duration) {
+	c.regionTimeout = timeout
+}
+
 // GetRegions returns supported regions.
 func (c *ProxmoxPool) GetRegions() []string {
 	regions := make([]string, 0, len(c.clients))
@@ -130,7 +214,10 @@ func (c *ProxmoxPool) GetRegions() []string {
 }
 
 // CheckClusters checks if the Proxmox connection is working.
+// It warns if the region differs from the Proxmox cluster name, or several regions point to the same cluster.
 func (c *ProxmoxPool) CheckClusters(ctx context.Context) error {
+	clusters := map[string]string{}
+
 	for region, pxClient := range c.clients {
 		info, err := pxClient.Version(ctx)
 		if err != nil {
@@ -150,6 +237,23 @@ func (c *ProxmoxPool) CheckClusters(ctx context.Context) error {
 		} else {
 			klog.InfoS("Proxmox cluster has no VMs, or check the account permission", "region", region)
 		}
+
+		name, err := getClusterName(ctx, pxClient)
+		if err != nil {
+			klog.V(4).InfoS("Failed to get the Proxmox cluster name", "region", region, "err", err)
+
+			continue
+		}
+
+		if name != region {
+			klog.Warningf("Region %s differs from the Proxmox cluster name %s", region, name)
+		}
+
+		if r, ok := clusters[name]; ok {
+			klog.Warningf("Regions %s and %s point to the same Proxmox cluster %s", r, region, name)
+		}
+
+		clusters[name] = region
 	}
 
 	return nil
@@ -186,20 +290,54 @@ func (c *ProxmoxPool) DeleteVMByIDInRegion(ctx context.Context, region string, v
 		return err
 	}
 
+	c.InvalidateVM(region, int(vm.VMID))
+
 	return px.DeleteVMByID(ctx, vm.Node, int(vm.VMID))
 }
 
-// GetNodeHAGroups returns a Proxmox node ha-group in a given region for the node.
-func (c *ProxmoxPool) GetNodeHAGroups(ctx context.Context, region string, node string) ([]string, error) {
-	groups := []string{}
-
+// GetMigrationTasks returns the recent VM and LXC container migration tasks in a given region.
+func (c *ProxmoxPool) GetMigrationTasks(ctx context.Context, region string) (proxmox.Tasks, error) {
 	px, err := c.GetProxmoxCluster(region)
 	if err != nil {
 		return nil, err
 	}
 
-	haGroups, err := px.GetHAGroupList(ctx)
+	tasks, err := (&proxmox.Cluster{}).New(px.Client).Tasks(ctx)
 	if err != nil {
+		return nil, fmt.Errorf("error get cluster tasks in region %s: %w", region, err)
+	}
+
+	return slices.DeleteFunc(tasks, func(t *proxmox.Task) bool {
+		return t.Type != "qmigrate" && t.Type != "vzmigrate"
+	}), nil
+}
+
+// GetGuestHAGroups returns the HA groups of the VM or LXC container running on the Proxmox node in a given region.
+// Proxmox VE 9 replaces the HA groups with the HA rules, the node affinity rules of the guest are used instead.
+func (c *ProxmoxPool) GetGuestHAGroups(ctx context.Context, region string, node string, vmID int) ([]HAGroup, error) {
+	major, err := c.GetMajorVersion(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+
+	if major < haRulesMajorVersion {
+		return c.GetNodeHAGroups(ctx, region, node)
+	}
+
+	return c.GetGuestHARules(ctx, region, node, vmID)
+}
+
+// GetNodeHAGroups returns the HA groups of the Proxmox node in a given region, sorted by name.
+func (c *ProxmoxPool) GetNodeHAGroups(ctx context.Context, region string, node string) ([]HAGroup, error) {
+	groups := []HAGroup{}
+
+	px, err := c.GetProxmoxCluster(region)
+	if err != nil {
+		return nil, err
+	}
+
+	haGroups := []*haGroupConfig{}
+	if err := px.Get(ctx, "/cluster/ha/groups", &haGroups); err != nil {
 		return nil, fmt.Errorf("error get ha-groups %v", err)
 	}
 
@@ -208,15 +346,13 @@ func (c *ProxmoxPool) GetNodeHAGroups(ctx context.Context, region string, node s
 			continue
 		}
 
-		for n := range strings.SplitSeq(g.Nodes, ",") {
-			if node == strings.Split(n, ":")[0] {
-				groups = append(groups, g.Group)
-			}
+		if priority, ok := nodePriority(g.Nodes, node); ok {
+			groups = append(groups, HAGroup{Name: g.Group, Priority: priority, Restricted: bool(g.Restricted)})
 		}
 	}
 
 	if len(groups) > 0 {
-		slices.Sort(groups)
+		sortHAGroups(groups)
 
 		return groups, nil
 	}
@@ -224,99 +360,142 @@ func (c *ProxmoxPool) GetNodeHAGroups(ctx context.Context, region string, node s
 	return nil, ErrHAGroupNotFound
 }
 
-// FindVMByNode find a VM by kubernetes node resource in all Proxmox clusters.
+// FindVMByNode find a VM or LXC container by kubernetes node resource in all Proxmox clusters.
+// The VM name is matched by the name matching strategy and the SMBIOS UUID.
+// LXC containers share the SMBIOS UUID of the Proxmox node, so they are matched by the exact name,
+// or by the name matching strategy if only one container matches.
 func (c *ProxmoxPool) FindVMByNode(ctx context.Context, node *v1.Node) (vmID int, region string, err error) {
-	var errs error
+	uuid := strings.ToLower(node.Status.NodeInfo.SystemUUID)
 
-	for region, px := range c.clients {
-		vm, err := px.GetVMByFilter(ctx, func(rs *proxmox.ClusterResource) (bool, error) {
-			if rs.Type != "qemu" {
-				return false, nil
+	return c.findVM(ctx, func(inv *regionInventory) (*InventoryVM, error) {
+		for name, vms := range inv.byName {
+			if !c.MatchNodeName(name, node.Name) {
+				continue
 			}
 
-			if !strings.HasPrefix(rs.Name, node.Name) {
-				return false, nil
+			for _, vm := range vms {
+				if vm.Resource.Type == GuestTypeVM && vm.UUID == uuid {
+					return vm, nil
+				}
 			}
+		}
 
-			if rs.Status == "unknown" {
-				errs = multierr.Append(errs, fmt.Errorf("region %s node %s: %w", region, rs.Node, ErrNodeInaccessible))
-
-				return false, nil //nolint: nilerr
+		for _, vm := range inv.byName[node.Name] {
+			if vm.Resource.Type == GuestTypeContainer {
+				return vm, nil
 			}
+		}
 
-			vm, err := px.GetVMConfig(ctx, int(rs.VMID))
-			if err != nil {
-				return false, err
-			}
+		var containers []*InventoryVM
 
-			if strings.EqualFold(goproxmox.GetVMUUID(vm), node.Status.NodeInfo.SystemUUID) {
-				return true, nil
+		for name, vms := range inv.byName {
+			if !c.MatchNodeName(name, node.Name) {
+				continue
 			}
 
-			return false, nil
-		})
-		if err != nil {
-			if err == goproxmox.ErrVirtualMachineNotFound {
-				continue
+			for _, vm := range vms {
+				if vm.Resource.Type == GuestTypeContainer {
+					containers = append(containers, vm)
+				}
 			}
+		}
 
-			return 0, "", err
+		if len(containers) == 1 {
+			return containers[0], nil
 		}
 
-		if vm.VMID == 0 {
-			continue
+		return nil, inv.inaccessibleErrors(func(vm *InventoryVM) bool {
+			return c.MatchNodeName(vm.Resource.Name, node.Name)
+		})
+	})
+}
+
+// FindVMByUUID find a VM by uuid in all Proxmox clusters.
+func (c *ProxmoxPool) FindVMByUUID(ctx context.Context, uuid string) (vmID int, region string, err error) {
+	uuid = strings.ToLower(uuid)
+
+	return c.findVM(ctx, func(inv *regionInventory) (*InventoryVM, error) {
+		if vm, ok := inv.byUUID[uuid]; ok {
+			return vm, nil
 		}
 
-		return int(vm.VMID), region, nil
-	}
+		return nil, inv.inaccessibleErrors(func(_ *InventoryVM) bool { return true })
+	})
+}
 
-	if errs != nil {
-		return 0, "", errs
+// findVM searches the VM in the inventory of all Proxmox clusters concurrently.
+// Only the SMBIOS UUID match of a VM is definitive, it wins immediately.
+// The other matches, LXC containers by name, wait for all regions, and the match in several regions is ErrInstanceAmbiguous.
+// The errors of the regions are aggregated in the region order.
+// The match without the cluster tag is reported as ErrInstanceUntagged.
+func (c *ProxmoxPool) findVM(ctx context.Context, match func(inv *regionInventory) (*InventoryVM, error)) (vmID int, region string, err error) {
+	type result struct {
+		idx int
+		vm  *InventoryVM
+		err error
 	}
 
-	return 0, "", ErrInstanceNotFound
-}
+	regions := c.GetRegions()
+	slices.Sort(regions)
 
-// FindVMByUUID find a VM by uuid in all Proxmox clusters.
-func (c *ProxmoxPool) FindVMByUUID(ctx context.Context, uuid string) (vmID int, region string, err error) {
-	var errs error
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	for region, px := range c.clients {
-		vm, err := px.GetVMByFilter(ctx, func(rs *proxmox.ClusterResource) (bool, error) {
-			if rs.Type != "qemu" {
-				return false, nil
-			}
+	results := make(chan result, len(regions))
 
-			if rs.Status == "unknown" {
-				errs = multierr.Append(errs, fmt.Errorf("region %s node %s: %w", region, rs.Node, ErrNodeInaccessible))
+	for idx, region := range regions {
+		go func() {
+			rctx, rcancel := context.WithTimeout(ctx, c.regionTimeout)
+			defer rcancel()
 
-				return false, nil //nolint: nilerr
+			vm, err := c.lookupInventory(rctx, region, match)
+			if vm != nil {
+				if err = c.untaggedError(vm.Resource); err != nil {
+					vm = nil
+				}
 			}
 
-			vm, err := px.GetVMConfig(ctx, int(rs.VMID))
 			if err != nil {
-				return false, err
+				err = fmt.Errorf("region %s: %w", region, err)
 			}
 
-			if strings.EqualFold(goproxmox.GetVMUUID(vm), uuid) {
-				return true, nil
-			}
+			results <- result{idx: idx, vm: vm, err: err}
+		}()
+	}
 
-			return false, nil
-		})
-		if err != nil {
-			if errors.Is(err, goproxmox.ErrVirtualMachineNotFound) {
-				continue
-			}
+	vms := make([]*InventoryVM, len(regions))
+	errs := make([]error, len(regions))
 
-			return 0, "", err
+	for range regions {
+		res := <-results
+		if res.vm != nil && res.vm.Resource.Type == GuestTypeVM {
+			return int(res.vm.Resource.VMID), regions[res.idx], nil
 		}
 
-		return int(vm.VMID), region, nil
+		vms[res.idx] = res.vm
+		errs[res.idx] = res.err
+	}
+
+	matched := []string{}
+
+	for idx, vm := range vms {
+		if vm != nil {
+			matched = append(matched, regions[idx])
+		}
+	}
+
+	switch len(matched) {
+	case 0:
+	case 1:
+		idx := slices.Index(regions, matched[0])
+
+		return int(vms[idx].Resource.VMID), matched[0], nil
+	default:
+		return 0, "", fmt.Errorf("%w: %s", ErrInstanceAmbiguous, strings.Join(matched, ", "))
 	}
 
-	if errs != nil {
-		return 0, "", errs
+	if err := multierr.Combine(errs...); err != nil {
+		return 0, "", err
 	}
 
 	return 0, "", ErrInstanceNotFound
@@ -17,12 +17,15 @@ limitations under the License.
 package proxmoxpool_test
 
 import (
+	"net/http"
 	"os"
 	"testing"
 
+	"github.com/jarcoal/httpmock"
 	"github.com/stretchr/testify/assert"
 
 	pxpool "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/proxmoxpool"
+	testcluster "github.com/sergelogvinov/proxmox-cloud-controller-manager/test/cluster"
 )
 
 func newClusterEnv() []*pxpool.ProxmoxCluster {
@@ -117,3 +120,54 @@ func TestCheckClusters(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Contains(t, err.Error(), "failed to initialized proxmox client in region")
 }
+
+func TestRegionFromClusterName(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	cfg := newClusterEnv()
+	cfg[1].Region = ""
+	cfg[1].RegionFromClusterName = true
+
+	pool, err := pxpool.NewProxmoxPool(cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, "cluster-2", cfg[1].Region)
+	assert.ElementsMatch(t, []string{"cluster-1", "cluster-2"}, pool.GetRegions())
+
+	name, err := pool.GetClusterName(t.Context(), "cluster-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "cluster-1", name)
+
+	// Both entries point to the same Proxmox cluster
+	cfg = newClusterEnv()
+	cfg[1].URL = cfg[0].URL
+	cfg[1].Region = ""
+	cfg[1].RegionFromClusterName = true
+
+	_, err = pxpool.NewProxmoxPool(cfg)
+	assert.ErrorIs(t, err, pxpool.ErrRegionCollision)
+
+	// Standalone Proxmox node
+	httpmock.RegisterResponder(http.MethodGet, `=~/cluster/status`,
+		httpmock.NewJsonResponderOrPanic(200, map[string]any{"data": []map[string]any{{"type": "node", "name": "pve-1"}}}))
+
+	cfg = newClusterEnv()
+	cfg[0].Region = ""
+	cfg[0].RegionFromClusterName = true
+
+	_, err = pxpool.NewProxmoxPool(cfg)
+	assert.ErrorIs(t, err, pxpool.ErrClusterNameNotFound)
+
+	// Only the urls list is defined
+	cfg = newClusterEnv()
+	cfg[0].URLs = []string{cfg[0].URL}
+	cfg[0].URL = ""
+	cfg[0].Region = ""
+	cfg[0].RegionFromClusterName = true
+
+	_, err = pxpool.NewProxmoxPool(cfg)
+	assert.ErrorIs(t, err, pxpool.ErrClusterNameNotFound)
+	assert.Contains(t, err.Error(), "failed to get the cluster name of https://127.0.0.1:8006/api2/json")
+}
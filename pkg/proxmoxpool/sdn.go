@@ -0,0 +1,115 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmoxpool
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+
+	proxmox "github.com/luthermonson/go-proxmox"
+)
+
+// GetSDNSubnets returns the SDN subnets of the vnet in a given region.
+func (c *ProxmoxPool) GetSDNSubnets(ctx context.Context, region string, vnet string) ([]*proxmox.VNetSubnet, error) {
+	px, err := c.GetProxmoxCluster(region)
+	if err != nil {
+		return nil, err
+	}
+
+	subnets, err := (&proxmox.Cluster{}).New(px.Client).SDNSubnets(ctx, url.PathEscape(vnet))
+	if err != nil {
+		return nil, fmt.Errorf("error get sdn subnets of vnet %s: %v", vnet, err)
+	}
+
+	return subnets, nil
+}
+
+// CreateSDNSubnet creates the SDN subnet in the vnet in a given region.
+func (c *ProxmoxPool) CreateSDNSubnet(ctx context.Context, region string, vnet string, cidr string) error {
+	px, err := c.GetProxmoxCluster(region)
+	if err != nil {
+		return err
+	}
+
+	data := map[string]string{
+		"type":   "subnet",
+		"subnet": cidr,
+	}
+
+	if err := px.Post(ctx, fmt.Sprintf("/cluster/sdn/vnets/%s/subnets", url.PathEscape(vnet)), data, nil); err != nil {
+		return fmt.Errorf("error create sdn subnet %s in vnet %s: %v", cidr, vnet, err)
+	}
+
+	return nil
+}
+
+// DeleteSDNSubnet deletes the SDN subnet by its ID in a given region.
+func (c *ProxmoxPool) DeleteSDNSubnet(ctx context.Context, region string, vnet string, id string) error {
+	px, err := c.GetProxmoxCluster(region)
+	if err != nil {
+		return err
+	}
+
+	if err := px.Delete(ctx, fmt.Sprintf("/cluster/sdn/vnets/%s/subnets/%s", url.PathEscape(vnet), url.PathEscape(id)), nil); err != nil {
+		return fmt.Errorf("error delete sdn subnet %s in vnet %s: %v", id, vnet, err)
+	}
+
+	return nil
+}
+
+// ApplySDN applies the pending SDN configuration in a given region.
+func (c *ProxmoxPool) ApplySDN(ctx context.Context, region string) error {
+	px, err := c.GetProxmoxCluster(region)
+	if err != nil {
+		return err
+	}
+
+	if _, err := (&proxmox.Cluster{}).New(px.Client).SDNApply(ctx); err != nil {
+		return fmt.Errorf("error apply sdn configuration: %v", err)
+	}
+
+	return nil
+}
+
+// GetSDNIPAMEntries returns the IPAM entries of the SDN zone in a given region.
+func (c *ProxmoxPool) GetSDNIPAMEntries(ctx context.Context, region string, zone string) ([]*proxmox.IPAM, error) {
+	px, err := c.GetProxmoxCluster(region)
+	if err != nil {
+		return nil, err
+	}
+
+	z, err := (&proxmox.Cluster{}).New(px.Client).SDNZone(ctx, url.PathEscape(zone))
+	if err != nil {
+		return nil, fmt.Errorf("error get sdn zone %s: %v", zone, err)
+	}
+
+	ipam := z.IPAM
+	if ipam == "" {
+		ipam = "pve"
+	}
+
+	entries := []*proxmox.IPAM{}
+	if err := px.Get(ctx, fmt.Sprintf("/cluster/sdn/ipams/%s/status", url.PathEscape(ipam)), &entries); err != nil {
+		return nil, fmt.Errorf("error get sdn ipam %s status: %v", ipam, err)
+	}
+
+	return slices.DeleteFunc(entries, func(e *proxmox.IPAM) bool {
+		return e.Zone != zone
+	}), nil
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmoxpool
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	proxmox "github.com/luthermonson/go-proxmox"
+)
+
+// ParseTags returns the tags of the cluster resource.
+// Proxmox stores the tags separated by semicolons, the API also accepts commas and spaces.
+func ParseTags(tags string) []string {
+	return strings.FieldsFunc(tags, func(r rune) bool {
+		return r == ';' || r == ',' || r == ' '
+	})
+}
+
+// SetClusterTag sets the Proxmox tag of the Kubernetes cluster VMs.
+// If the tag is not empty, only the VMs with the tag are discovered.
+func (c *ProxmoxPool) SetClusterTag(tag string) {
+	c.clusterTag = strings.ToLower(tag)
+}
+
+// HasClusterTag reports whether the cluster resource has the Proxmox tag of the Kubernetes cluster.
+// It is always true if the tag is not set.
+func (c *ProxmoxPool) HasClusterTag(rs *proxmox.ClusterResource) bool {
+	if c.clusterTag == "" {
+		return true
+	}
+
+	return slices.ContainsFunc(ParseTags(rs.Tags), func(tag string) bool {
+		return strings.EqualFold(tag, c.clusterTag)
+	})
+}
+
+// CheckClusterTag returns ErrInstanceUntagged if the VM or LXC container does not have the Proxmox tag of the Kubernetes cluster.
+func (c *ProxmoxPool) CheckClusterTag(ctx context.Context, region string, vmID int) error {
+	if c.clusterTag == "" {
+		return nil
+	}
+
+	vm, err := c.lookupInventory(ctx, region, func(inv *regionInventory) (*InventoryVM, error) {
+		return inv.byID[uint64(vmID)], nil //nolint: gosec
+	})
+	if err != nil {
+		return err
+	}
+
+	if vm == nil {
+		return ErrInstanceNotFound
+	}
+
+	return c.untaggedError(vm.Resource)
+}
+
+func (c *ProxmoxPool) untaggedError(rs *proxmox.ClusterResource) error {
+	if c.HasClusterTag(rs) {
+		return nil
+	}
+
+	return fmt.Errorf("%s %d has no tag %s: %w", rs.Type, rs.VMID, c.clusterTag, ErrInstanceUntagged)
+}
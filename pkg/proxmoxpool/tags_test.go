@@ -0,0 +1,84 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmoxpool_test
+
+import (
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	proxmox "github.com/luthermonson/go-proxmox"
+	"github.com/stretchr/testify/assert"
+
+	pxpool "github.com/sergelogvinov/proxmox-cloud-controller-manager/pkg/proxmoxpool"
+	testcluster "github.com/sergelogvinov/proxmox-cloud-controller-manager/test/cluster"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseTags(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []string{"k8s-prod", "db"}, pxpool.ParseTags("k8s-prod;db"))
+	assert.Equal(t, []string{"k8s-prod", "db", "web"}, pxpool.ParseTags("k8s-prod,db web"))
+	assert.Empty(t, pxpool.ParseTags(""))
+}
+
+func TestHasClusterTag(t *testing.T) {
+	t.Parallel()
+
+	pool, err := pxpool.NewProxmoxPool(newClusterEnv())
+	assert.Nil(t, err)
+
+	rs := &proxmox.ClusterResource{Tags: "K8S-Prod;db"}
+	assert.True(t, pool.HasClusterTag(rs))
+
+	pool.SetClusterTag("k8s-prod")
+	assert.True(t, pool.HasClusterTag(rs))
+	assert.False(t, pool.HasClusterTag(&proxmox.ClusterResource{Tags: "k8s-dev"}))
+	assert.False(t, pool.HasClusterTag(&proxmox.ClusterResource{}))
+}
+
+func TestFindVMClusterTag(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset() //nolint: wsl_v5
+
+	testcluster.SetupMockResponders()
+
+	pool, err := pxpool.NewProxmoxPool(newClusterEnv())
+	assert.Nil(t, err)
+
+	pool.SetClusterTag("k8s-kubernetes")
+
+	vmID, region, err := pool.FindVMByNode(t.Context(), &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-1-node-1"},
+		Status: v1.NodeStatus{
+			NodeInfo: v1.NodeSystemInfo{SystemUUID: "11833f4c-341f-4bd3-aad7-f7abed000000"},
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 100, vmID)
+	assert.Equal(t, "cluster-1", region)
+
+	// VM 101 has no cluster tag
+	_, _, err = pool.FindVMByUUID(t.Context(), "11833f4c-341f-4bd3-aad7-f7abed000001")
+	assert.ErrorIs(t, err, pxpool.ErrInstanceUntagged)
+
+	assert.Nil(t, pool.CheckClusterTag(t.Context(), "cluster-1", 105))
+	assert.ErrorIs(t, pool.CheckClusterTag(t.Context(), "cluster-1", 101), pxpool.ErrInstanceUntagged)
+	assert.ErrorIs(t, pool.CheckClusterTag(t.Context(), "cluster-1", 500), pxpool.ErrInstanceNotFound)
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxmoxpool
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// versionTTL is the lifetime of the cached Proxmox VE version, the cluster can be upgraded in place.
+const versionTTL = 10 * time.Minute
+
+type versionEntry struct {
+	major   int
+	updated time.Time
+}
+
+// versionCache is the cache of the Proxmox VE major version, indexed by region.
+type versionCache struct {
+	mu      sync.Mutex
+	entries map[string]*versionEntry
+}
+
+func newVersionCache() *versionCache {
+	return &versionCache{
+		entries: map[string]*versionEntry{},
+	}
+}
+
+// GetMajorVersion returns the Proxmox VE major version in a given region, the version is cached.
+func (c *ProxmoxPool) GetMajorVersion(ctx context.Context, region string) (int, error) {
+	c.versions.mu.Lock()
+	entry, ok := c.versions.entries[region]
+	c.versions.mu.Unlock()
+
+	if ok && time.Since(entry.updated) < versionTTL {
+		return entry.major, nil
+	}
+
+	px, err := c.GetProxmoxCluster(region)
+	if err != nil {
+		return 0, err
+	}
+
+	info, err := px.Version(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error get version in region %s: %w", region, err)
+	}
+
+	major, err := parseMajorVersion(info.Version)
+	if err != nil {
+		return 0, fmt.Errorf("error parse version %q in region %s: %w", info.Version, region, err)
+	}
+
+	c.versions.mu.Lock()
+	c.versions.entries[region] = &versionEntry{major: major, updated: time.Now()}
+	c.versions.mu.Unlock()
+
+	return major, nil
+}
+
+// parseMajorVersion returns the major version of the Proxmox VE version, for example 9 of 9.0.3.
+func parseMajorVersion(version string) (int, error) {
+	major, _, _ := strings.Cut(version, ".")
+
+	return strconv.Atoi(major)
+}
@@ -35,20 +35,80 @@ func SetupMockResponders() {
 			})
 		})
 	httpmock.RegisterResponder(http.MethodGet, `=~/cluster/status`,
-		func(_ *http.Request) (*http.Response, error) {
+		func(req *http.Request) (*http.Response, error) {
+			name := "cluster-1"
+			if req.URL.Hostname() == "127.0.0.2" {
+				name = "cluster-2"
+			}
+
 			return httpmock.NewJsonResponse(200, map[string]any{
-				"data": proxmox.NodeStatuses{{Name: "pve-1"}, {Name: "pve-2"}, {Name: "pve-3"}, {Name: "pve-4"}},
+				"data": proxmox.NodeStatuses{
+					{Type: "cluster", Name: name},
+					{Type: "node", Name: "pve-1", Online: 1},
+					{Type: "node", Name: "pve-2", Online: 1},
+					{Type: "node", Name: "pve-3", Online: 1},
+					{Type: "node", Name: "pve-4"},
+				},
 			})
 		})
 	httpmock.RegisterResponder(http.MethodGet, `=~/cluster/ha/groups`,
 		func(_ *http.Request) (*http.Response, error) {
 			return httpmock.NewJsonResponse(200, map[string]any{
 				"data": []goproxmox.HAGroup{
-					{Group: "rnd", Type: "group", Nodes: "pve-1,pve-2"},
+					{Group: "rnd", Type: "group", Nodes: "pve-1:2,pve-2"},
 					{Group: "dev", Type: "group", Nodes: "pve-4"},
 				},
 			})
 		})
+	httpmock.RegisterResponder(http.MethodGet, `=~/cluster/ha/rules$`,
+		func(_ *http.Request) (*http.Response, error) {
+			return httpmock.NewJsonResponse(200, map[string]any{
+				"data": []map[string]any{
+					{"rule": "rnd", "type": "node-affinity", "nodes": "pve-1:2,pve-2", "resources": "vm:100,vm:101,ct:105"},
+					{"rule": "gpu", "type": "node-affinity", "nodes": "pve-1", "resources": "vm:100", "strict": 1},
+					{"rule": "dev", "type": "node-affinity", "nodes": "pve-4", "resources": "vm:104"},
+					{"rule": "old", "type": "node-affinity", "nodes": "pve-1,pve-2", "resources": "vm:100", "disable": 1},
+					{"rule": "spread", "type": "resource-affinity", "affinity": "negative", "resources": "vm:100,vm:101"},
+				},
+			})
+		})
+	httpmock.RegisterResponder(http.MethodGet, `=~/cluster/ha/status/manager_status$`,
+		func(_ *http.Request) (*http.Response, error) {
+			return httpmock.NewJsonResponse(200, map[string]any{
+				"data": map[string]any{
+					"manager_status": map[string]any{
+						"master_node": "pve-1",
+						"node_status": map[string]string{"pve-1": "online", "pve-2": "online", "pve-4": "maintenance"},
+					},
+					"lrm_status": map[string]any{
+						"pve-1": map[string]any{"mode": "active", "state": "active"},
+						"pve-2": map[string]any{"mode": "maintenance", "state": "active"},
+					},
+				},
+			})
+		})
+	httpmock.RegisterResponder(http.MethodGet, `=~/cluster/mapping/pci/gpu$`,
+		func(_ *http.Request) (*http.Response, error) {
+			return httpmock.NewJsonResponse(200, map[string]any{
+				"data": map[string]any{
+					"id": "gpu",
+					"map": []string{
+						"id=10de:2236,iommugroup=3,node=pve-1,path=0000:02:00.0",
+						"id=10de:1db6,iommugroup=3,node=pve-2,path=0000:02:00.0",
+					},
+				},
+			})
+		})
+	httpmock.RegisterResponder(http.MethodGet, `=~/nodes/pve-1/hardware/pci$`,
+		func(_ *http.Request) (*http.Response, error) {
+			return httpmock.NewJsonResponse(200, map[string]any{
+				"data": []map[string]any{
+					{"id": "0000:00:1f.0", "vendor": "0x8086", "device": "0x7a06"},
+					{"id": "0000:01:00.0", "vendor": "0x10de", "device": "0x1db6"},
+					{"id": "0000:01:00.1", "vendor": "0x10de", "device": "0x10f0"},
+				},
+			})
+		})
 
 	httpmock.RegisterResponder(http.MethodGet, "https://127.0.0.2:8006/api2/json/cluster/resources",
 		func(_ *http.Request) (*http.Response, error) {
@@ -79,6 +139,8 @@ func SetupMockResponders() {
 						MaxCPU: 4,
 						MaxMem: 10 * 1024 * 1024 * 1024,
 						Status: "running",
+						Tags:   "k8s-kubernetes;prod",
+						Pool:   "team-a",
 					},
 					&proxmox.ClusterResource{
 						Node:   "pve-2",
@@ -98,6 +160,16 @@ func SetupMockResponders() {
 						MaxMem: 4 * 1024 * 1024 * 1024,
 						Status: "unknown",
 					},
+					&proxmox.ClusterResource{
+						Node:   "pve-2",
+						Type:   "lxc",
+						VMID:   105,
+						Name:   "cluster-1-node-5",
+						MaxCPU: 2,
+						MaxMem: 2 * 1024 * 1024 * 1024,
+						Status: "running",
+						Tags:   "k8s-kubernetes",
+					},
 
 					&proxmox.ClusterResource{
 						ID:         "storage/smb",
@@ -119,6 +191,16 @@ func SetupMockResponders() {
 						Shared:     1,
 						Status:     "available",
 					},
+					&proxmox.ClusterResource{
+						ID:         "storage/cephfs",
+						Type:       "storage",
+						PluginType: "cephfs",
+						Node:       "pve-1",
+						Storage:    "cephfs",
+						Content:    "backup,iso",
+						Shared:     1,
+						Status:     "available",
+					},
 					&proxmox.ClusterResource{
 						ID:         "storage/zfs",
 						Type:       "storage",
@@ -181,7 +263,13 @@ func SetupMockResponders() {
 	httpmock.RegisterResponder(http.MethodGet, `=~/nodes/pve-1/status`,
 		func(_ *http.Request) (*http.Response, error) {
 			return httpmock.NewJsonResponse(200, map[string]any{
-				"data": proxmox.Node{},
+				"data": map[string]any{
+					"pveversion":     "pve-manager/8.2.4/faa83925c9641325",
+					"kversion":       "Linux 6.8.12-1-pve #1 SMP PREEMPT_DYNAMIC PMX 6.8.12-1 (2024-08-05T16:17Z)",
+					"current-kernel": map[string]any{"release": "6.8.12-1-pve", "sysname": "Linux"},
+					"cpuinfo":        map[string]any{"model": "AMD EPYC 7543 32-Core Processor", "cores": 32, "sockets": 1, "cpus": 64},
+					"ksm":            map[string]any{"shared": 1048576},
+				},
 			})
 		})
 	httpmock.RegisterResponder(http.MethodGet, `=~/nodes/pve-2/status`,
@@ -201,6 +289,46 @@ func SetupMockResponders() {
 			return httpmock.NewBytesResponse(595, []byte{}), nil
 		})
 
+	httpmock.RegisterResponder(http.MethodGet, `=~/nodes/pve-1/config$`,
+		func(_ *http.Request) (*http.Response, error) {
+			return httpmock.NewJsonResponse(200, map[string]any{
+				"data": map[string]any{"description": "Rack A1\nzone: dc-1\n"},
+			})
+		})
+	httpmock.RegisterResponder(http.MethodGet, `=~/nodes/pve-2/config$`,
+		func(_ *http.Request) (*http.Response, error) {
+			return httpmock.NewJsonResponse(200, map[string]any{
+				"data": map[string]any{"description": "GPU host, zone=dc-2"},
+			})
+		})
+	httpmock.RegisterResponder(http.MethodGet, `=~/nodes/pve-[0-9]/ceph/osd$`,
+		func(_ *http.Request) (*http.Response, error) {
+			host := func(name string) map[string]any {
+				return map[string]any{
+					"name": name, "type": "host",
+					"children": []map[string]any{{"name": "osd.0", "type": "osd", "leaf": 1}},
+				}
+			}
+
+			return httpmock.NewJsonResponse(200, map[string]any{
+				"data": map[string]any{
+					"root": map[string]any{
+						"leaf": 0,
+						"children": []map[string]any{
+							{
+								"name": "default", "type": "root",
+								"children": []map[string]any{
+									{"name": "rack-1", "type": "rack", "children": []map[string]any{host("pve-1")}},
+									{"name": "rack-2", "type": "rack", "children": []map[string]any{host("pve-2")}},
+									host("pve-4"),
+								},
+							},
+						},
+					},
+				},
+			})
+		})
+
 	httpmock.RegisterResponder(http.MethodGet, "=~/nodes$",
 		func(_ *http.Request) (*http.Response, error) {
 			return httpmock.NewJsonResponse(200, map[string]any{
@@ -374,12 +502,20 @@ func SetupMockResponders() {
 		func(_ *http.Request) (*http.Response, error) {
 			return httpmock.NewJsonResponse(200, map[string]any{
 				"data": map[string]any{
-					"vmid":    100,
-					"cores":   4,
-					"memory":  "10240",
-					"scsi0":   "local-lvm:vm-100-disk-0,size=10G",
-					"scsi1":   "local-lvm:vm-9999-pvc-123,backup=0,iothread=1,wwn=0x5056432d49443031",
-					"smbios1": "uuid=11833f4c-341f-4bd3-aad7-f7abed000000",
+					"vmid":      100,
+					"cores":     4,
+					"memory":    "10240",
+					"scsi0":     "local-lvm:vm-100-disk-0,size=10G",
+					"scsi1":     "local-lvm:vm-9999-pvc-123,backup=0,iothread=1,wwn=0x5056432d49443031",
+					"smbios1":   "uuid=11833f4c-341f-4bd3-aad7-f7abed000000",
+					"tags":      "k8s-kubernetes;prod;role-gpu",
+					"cpu":       "host,flags=+aes",
+					"machine":   "pc-q35-9.0+pve0",
+					"bios":      "ovmf",
+					"numa":      1,
+					"hugepages": "2",
+					"hostpci0":  "0000:01:00,pcie=1",
+					"hostpci1":  "mapping=gpu,pcie=1",
 				},
 			})
 		},
@@ -413,6 +549,42 @@ func SetupMockResponders() {
 		},
 	)
 
+	httpmock.RegisterResponder(http.MethodGet, `=~/nodes/pve-2/lxc/105/status/current`,
+		func(_ *http.Request) (*http.Response, error) {
+			return httpmock.NewJsonResponse(200, map[string]any{
+				"data": map[string]any{
+					"vmid":   105,
+					"name":   "cluster-1-node-5",
+					"cpus":   2,
+					"maxmem": 2 * 1024 * 1024 * 1024,
+					"status": "running",
+				},
+			})
+		},
+	)
+	httpmock.RegisterResponder(http.MethodGet, `=~/nodes/pve-2/lxc/105/config`,
+		func(_ *http.Request) (*http.Response, error) {
+			return httpmock.NewJsonResponse(200, map[string]any{
+				"data": map[string]any{
+					"hostname": "cluster-1-node-5",
+					"cores":    2,
+					"memory":   2048,
+					"net0":     "name=eth0,bridge=vmbr0,hwaddr=BC:24:11:00:00:05,ip=172.16.0.105/24,gw=172.16.0.1,type=veth",
+				},
+			})
+		},
+	)
+	httpmock.RegisterResponder(http.MethodGet, `=~/nodes/pve-2/lxc/105/interfaces`,
+		func(_ *http.Request) (*http.Response, error) {
+			return httpmock.NewJsonResponse(200, map[string]any{
+				"data": []proxmox.ContainerInterface{
+					{Name: "lo", Inet: "127.0.0.1/8", Inet6: "::1/128"},
+					{Name: "eth0", HWAddr: "bc:24:11:00:00:05", Inet: "172.16.0.105/24", Inet6: "2001:db8::105/64"},
+				},
+			})
+		},
+	)
+
 	httpmock.RegisterResponder(http.MethodGet, `=~/nodes/pve-3/qemu/103/status/current`,
 		func(_ *http.Request) (*http.Response, error) {
 			return httpmock.NewJsonResponse(200, map[string]any{
@@ -0,0 +1,160 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/luthermonson/go-proxmox"
+)
+
+// SDN is a fake Proxmox SDN, it keeps the vnet subnets and the IPAM entries per Proxmox cluster host.
+type SDN struct {
+	mu      sync.Mutex
+	subnets map[string][]*proxmox.VNetSubnet
+	ipam    map[string][]*proxmox.IPAM
+
+	// Applied counts the SDN apply calls per Proxmox cluster host.
+	Applied map[string]int
+}
+
+var sdnSubnetsPath = regexp.MustCompile(`/cluster/sdn/vnets/([^/]+)/subnets(?:/([^/]+))?$`)
+
+// SetupSDNMockResponders sets up the HTTP mock responders for Proxmox SDN API calls.
+func SetupSDNMockResponders(zone string, subnets map[string][]*proxmox.VNetSubnet) *SDN {
+	sdn := &SDN{
+		subnets: map[string][]*proxmox.VNetSubnet{},
+		ipam:    map[string][]*proxmox.IPAM{},
+		Applied: map[string]int{},
+	}
+
+	for host, list := range subnets {
+		sdn.subnets[host] = slices.Clone(list)
+	}
+
+	httpmock.RegisterResponder(http.MethodGet, `=~/cluster/sdn/vnets/[^/]+/subnets$`,
+		func(req *http.Request) (*http.Response, error) {
+			sdn.mu.Lock()
+			defer sdn.mu.Unlock()
+
+			vnet := sdnSubnetsPath.FindStringSubmatch(req.URL.Path)[1]
+			list := []*proxmox.VNetSubnet{}
+
+			for _, subnet := range sdn.subnets[req.URL.Host] {
+				if subnet.VNet == vnet {
+					list = append(list, subnet)
+				}
+			}
+
+			return httpmock.NewJsonResponse(200, map[string]any{"data": list})
+		})
+
+	httpmock.RegisterResponder(http.MethodPost, `=~/cluster/sdn/vnets/[^/]+/subnets$`,
+		func(req *http.Request) (*http.Response, error) {
+			sdn.mu.Lock()
+			defer sdn.mu.Unlock()
+
+			data := map[string]string{}
+			if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+				return httpmock.NewStringResponse(400, err.Error()), nil
+			}
+
+			vnet := sdnSubnetsPath.FindStringSubmatch(req.URL.Path)[1]
+			cidr := data["subnet"]
+
+			sdn.subnets[req.URL.Host] = append(sdn.subnets[req.URL.Host], &proxmox.VNetSubnet{
+				ID:   zone + "-" + strings.ReplaceAll(cidr, "/", "-"),
+				CIDR: cidr,
+				Type: "subnet",
+				Zone: zone,
+				VNet: vnet,
+			})
+
+			return httpmock.NewJsonResponse(200, map[string]any{"data": nil})
+		})
+
+	httpmock.RegisterResponder(http.MethodDelete, `=~/cluster/sdn/vnets/[^/]+/subnets/[^/]+$`,
+		func(req *http.Request) (*http.Response, error) {
+			sdn.mu.Lock()
+			defer sdn.mu.Unlock()
+
+			id := sdnSubnetsPath.FindStringSubmatch(req.URL.Path)[2]
+
+			sdn.subnets[req.URL.Host] = slices.DeleteFunc(sdn.subnets[req.URL.Host], func(subnet *proxmox.VNetSubnet) bool {
+				return subnet.ID == id
+			})
+
+			return httpmock.NewJsonResponse(200, map[string]any{"data": nil})
+		})
+
+	httpmock.RegisterResponder(http.MethodPut, `=~/cluster/sdn/?$`,
+		func(req *http.Request) (*http.Response, error) {
+			sdn.mu.Lock()
+			defer sdn.mu.Unlock()
+
+			sdn.Applied[req.URL.Host]++
+
+			return httpmock.NewJsonResponse(200, map[string]any{"data": "UPID:pve-1:00000001:00000001:00000001:reloadnetworkall::root@pam:"})
+		})
+
+	httpmock.RegisterResponder(http.MethodGet, `=~/cluster/sdn/zones/[^/]+$`,
+		func(_ *http.Request) (*http.Response, error) {
+			return httpmock.NewJsonResponse(200, map[string]any{
+				"data": proxmox.SDNZone{Name: zone, Type: "simple", IPAM: "pve"},
+			})
+		})
+
+	httpmock.RegisterResponder(http.MethodGet, `=~/cluster/sdn/ipams/pve/status$`,
+		func(req *http.Request) (*http.Response, error) {
+			sdn.mu.Lock()
+			defer sdn.mu.Unlock()
+
+			return httpmock.NewJsonResponse(200, map[string]any{"data": sdn.ipam[req.URL.Host]})
+		})
+
+	return sdn
+}
+
+// AddIPAMEntry adds the IPAM entry to the Proxmox cluster host.
+func (s *SDN) AddIPAMEntry(host string, entry *proxmox.IPAM) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ipam[host] = append(s.ipam[host], entry)
+}
+
+// IPAMEntries returns the IPAM entries of the Proxmox cluster host.
+func (s *SDN) IPAMEntries(host string) []*proxmox.IPAM {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.ipam[host])
+}
+
+// Subnets returns the subnets of the Proxmox cluster host.
+func (s *SDN) Subnets(host string) []*proxmox.VNetSubnet {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.subnets[host])
+}